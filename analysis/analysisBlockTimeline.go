@@ -0,0 +1,642 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// BlockTimeline stores the trace statistics of a block, and its metadata from chaindata
+type BlockTimeline struct {
+	BlockID      uint64
+	Hash         string
+	StartTime    time.Time
+	EndTime      time.Time
+	HasEnd       bool
+	Counts       map[string]uint64 // "<category>:<OPType>" -> count
+	Ops          uint64
+	Reads        uint64
+	Writes       uint64
+	Deletes      uint64
+	BytesWritten uint64 // Key and value sizes of writes, key sizes of deletes
+	HasMetadata  bool
+	GasUsed      uint64
+	GasLimit     uint64
+	TxCount      int
+	BlobCount    int
+}
+
+// RunningPearson accumulates the sums needed for the Pearson correlation of two variables
+type RunningPearson struct {
+	N, SumX, SumY, SumXX, SumYY, SumXY float64
+}
+
+// BlockSummary is the part of a block kept in memory to rank the I/O-heavy blocks
+type BlockSummary struct {
+	BlockID      uint64
+	Ops          uint64
+	Writes       uint64
+	BytesWritten uint64
+	GasUsed      uint64
+	TxCount      int
+	BlobCount    int
+	TopSeries    string
+}
+
+// TimelineRecord is a record of blockTimeline-<start>_<end>.{json,csv}. The wall time and the metadata are null
+// (NaN in CSV) when unknown
+type TimelineRecord struct {
+	BlockID      uint64  `json:"block_id"`
+	StartTime    string  `json:"start_time"`
+	WallSeconds  float64 `json:"wall_seconds"`
+	Ops          uint64  `json:"ops"`
+	Reads        uint64  `json:"reads"`
+	Writes       uint64  `json:"writes"`
+	Deletes      uint64  `json:"deletes"`
+	BytesWritten uint64  `json:"bytes_written"`
+	OpsPerSec    float64 `json:"ops_per_sec"`
+	GasUsed      float64 `json:"gas_used"`
+	GasLimit     float64 `json:"gas_limit"`
+	TxCount      float64 `json:"tx_count"`
+	BlobCount    float64 `json:"blob_count"`
+	TopSeries    string  `json:"top_series"`
+	TopSeriesOps uint64  `json:"top_series_ops"`
+}
+
+// BlockOpsRecord is a record of blockTimeline-ops-<start>_<end>.{json,csv}
+type BlockOpsRecord struct {
+	BlockID  uint64 `json:"block_id"`
+	Category string `json:"category"`
+	OpType   string `json:"op_type"`
+	Count    uint64 `json:"count"`
+}
+
+// CorrelationRecord is a record of blockTimeline-summary-<start>_<end>.{json,csv}
+type CorrelationRecord struct {
+	TraceVariable    string  `json:"trace_variable"`
+	MetadataVariable string  `json:"metadata_variable"`
+	Pearson          float64 `json:"pearson"`
+}
+
+// HeavyBlockRecord is a record of blockTimeline-heavy-<start>_<end>.{json,csv}, the top 100 blocks by bytes written
+type HeavyBlockRecord struct {
+	BlockID      uint64 `json:"block_id"`
+	Ops          uint64 `json:"ops"`
+	Writes       uint64 `json:"writes"`
+	BytesWritten uint64 `json:"bytes_written"`
+	GasUsed      uint64 `json:"gas_used"`
+	TxCount      int    `json:"tx_count"`
+	BlobCount    int    `json:"blob_count"`
+	TopSeries    string `json:"top_series"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex = regexp.MustCompile(`OPType: (\w+), (?:key: ([a-fA-F0-9]+), size: (\d+)(?:, value: [a-fA-F0-9]*, size: (\d+))?|prefix: ([a-fA-F0-9]*))`)
+	// The trace logger prints the date and time (in seconds) before the file name
+	blockMarkerRegex = regexp.MustCompile(`(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \S+ Processing block \((start|end)\), ID: (\d+), hash: (0x[0-9a-fA-F]+)`)
+
+	// The trace variables and the block metadata to correlate
+	traceVariables    = []string{"Ops", "Writes", "BytesWritten", "WallSeconds"}
+	metadataVariables = []string{"GasUsed", "TxCount", "BlobCount"}
+)
+
+const timestampLayout = "2006/01/02 15:04:05"
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func (rp *RunningPearson) Add(x, y float64) {
+	rp.N++
+	rp.SumX += x
+	rp.SumY += y
+	rp.SumXX += x * x
+	rp.SumYY += y * y
+	rp.SumXY += x * y
+}
+
+func (rp *RunningPearson) Value() float64 {
+	cov := rp.SumXY - rp.SumX*rp.SumY/rp.N
+	varX := rp.SumXX - rp.SumX*rp.SumX/rp.N
+	varY := rp.SumYY - rp.SumY*rp.SumY/rp.N
+	if rp.N < 2 || varX <= 0 || varY <= 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// WallSeconds returns the wall time between the start and end markers, which have a resolution of one second
+func (bt *BlockTimeline) WallSeconds() (int64, bool) {
+	if !bt.HasEnd {
+		return 0, false
+	}
+	return int64(bt.EndTime.Sub(bt.StartTime).Seconds()), true
+}
+
+// TopSeries returns the "<category>:<OPType>" with the most operations in the block
+func (bt *BlockTimeline) TopSeries() (string, uint64) {
+	var top string
+	var topCount uint64
+	for series, count := range bt.Counts {
+		if count > topCount || (count == topCount && series < top) {
+			top, topCount = series, count
+		}
+	}
+	return top, topCount
+}
+
+func (bt *BlockTimeline) variable(name string) float64 {
+	switch name {
+	case "Ops":
+		return float64(bt.Ops)
+	case "Writes":
+		return float64(bt.Writes)
+	case "BytesWritten":
+		return float64(bt.BytesWritten)
+	case "WallSeconds":
+		wall, _ := bt.WallSeconds()
+		return float64(wall)
+	case "GasUsed":
+		return float64(bt.GasUsed)
+	case "TxCount":
+		return float64(bt.TxCount)
+	case "BlobCount":
+		return float64(bt.BlobCount)
+	}
+	return math.NaN()
+}
+
+// readMetadata reads the gas used, gas limit, transaction count, and blob count of the block from chaindata
+func readMetadata(db ethdb.Database, bt *BlockTimeline) {
+	hash := common.HexToHash(bt.Hash)
+	header := rawdb.ReadHeader(db, hash, bt.BlockID)
+	if header == nil {
+		// The hash in the trace may belong to a side chain block, fall back to the canonical block
+		hash = rawdb.ReadCanonicalHash(db, bt.BlockID)
+		header = rawdb.ReadHeader(db, hash, bt.BlockID)
+		if header == nil {
+			return
+		}
+	}
+	bt.HasMetadata = true
+	bt.GasUsed = header.GasUsed
+	bt.GasLimit = header.GasLimit
+	if body := rawdb.ReadBody(db, hash, bt.BlockID); body != nil {
+		bt.TxCount = len(body.Transactions)
+		for _, tx := range body.Transactions {
+			bt.BlobCount += len(tx.BlobHashes())
+		}
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 4 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <start_block_number> <end_block_number> [chaindata_path] [ancient_path] [print_progress_interval]")
+		return
+	}
+	logFilePath := args[1]
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	chaindataPath := ""
+	if len(args) > 4 {
+		chaindataPath = args[4]
+	}
+	// The freezer is in chaindata/ancient by default
+	ancientPath := filepath.Join(chaindataPath, "ancient")
+	if len(args) > 5 && args[5] != "" {
+		ancientPath = args[5]
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 6 {
+		progressInterval, _ = strconv.ParseUint(args[6], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+
+	var db ethdb.Database
+	if chaindataPath != "" {
+		var err error
+		db, err = rawdb.Open(rawdb.OpenOptions{
+			Type:              "pebble",
+			Directory:         chaindataPath,
+			AncientsDirectory: ancientPath,
+			Namespace:         "eth/db/chaindata/",
+			Cache:             256,
+			Handles:           256,
+			ReadOnly:          true,
+		})
+		if err != nil {
+			fmt.Println("Error opening chaindata:", err)
+			return
+		}
+		defer db.Close()
+	}
+
+	file, err := os.Open(logFilePath)
+	if err != nil {
+		fmt.Println("Error opening file:", logFilePath)
+		return
+	}
+	defer file.Close()
+
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	// In text mode the per-block outputs are CSV with the original headers, the other formats use the records
+	timelinePath := "blockTimeline-" + rangeName + ".csv"
+	opsPath := "blockTimeline-ops-" + rangeName + ".csv"
+	var timelineWriter, opsWriter *bufio.Writer
+	var timelineRecords, opsRecords *RecordWriter
+	if *format != "text" {
+		timelinePath, opsPath = outputPath(timelinePath, *format), outputPath(opsPath, *format)
+		if timelineRecords, err = newRecordWriter(timelinePath, *format, TimelineRecord{}); err != nil {
+			fmt.Println("Error creating output file:", timelinePath)
+			return
+		}
+		defer timelineRecords.Close()
+		if opsRecords, err = newRecordWriter(opsPath, *format, BlockOpsRecord{}); err != nil {
+			fmt.Println("Error creating output file:", opsPath)
+			return
+		}
+		defer opsRecords.Close()
+	} else {
+		timelineFile, err := os.Create(timelinePath)
+		if err != nil {
+			fmt.Println("Error creating output file:", timelinePath)
+			return
+		}
+		defer timelineFile.Close()
+		timelineWriter = bufio.NewWriter(timelineFile)
+		fmt.Fprintln(timelineWriter, "BlockID,StartTime,WallSeconds,Ops,Reads,Writes,Deletes,BytesWritten,OpsPerSec,GasUsed,GasLimit,TxCount,BlobCount,TopSeries,TopSeriesOps")
+
+		opsFile, err := os.Create(opsPath)
+		if err != nil {
+			fmt.Println("Error creating output file:", opsPath)
+			return
+		}
+		defer opsFile.Close()
+		opsWriter = bufio.NewWriter(opsFile)
+		fmt.Fprintln(opsWriter, "BlockID,Category,OPType,Count")
+	}
+
+	correlations := make(map[string]*RunningPearson)
+	var summaries []BlockSummary
+
+	// writeTimeline writes a timeline record, in text mode as a CSV line with empty columns for the unknown values
+	writeTimeline := func(record TimelineRecord) {
+		if timelineRecords != nil {
+			if err := timelineRecords.Write(record); err != nil {
+				log.Fatalf("Cannot write to %s: %v", timelinePath, err)
+			}
+			return
+		}
+		column := func(value float64, layout string) string {
+			if math.IsNaN(value) {
+				return ""
+			}
+			return fmt.Sprintf(layout, value)
+		}
+		fmt.Fprintf(timelineWriter, "%d,%s,%s,%d,%d,%d,%d,%d,%s,%s,%s,%s,%s,%s,%d\n", record.BlockID, record.StartTime, column(record.WallSeconds, "%.0f"),
+			record.Ops, record.Reads, record.Writes, record.Deletes, record.BytesWritten, column(record.OpsPerSec, "%.2f"), column(record.GasUsed, "%.0f"),
+			column(record.GasLimit, "%.0f"), column(record.TxCount, "%.0f"), column(record.BlobCount, "%.0f"), record.TopSeries, record.TopSeriesOps)
+	}
+
+	// The block markers have a resolution of one second, so most blocks have a wall time of 0. The operations per
+	// second of a block are those of the run of consecutive blocks it belongs to, from the start of the first block
+	// to the end of the last one, which is closed once it spans at least one second
+	var pending []TimelineRecord
+	var runOps uint64
+	var runStart time.Time
+	writeRun := func(seconds float64) {
+		for _, record := range pending {
+			if seconds > 0 {
+				record.OpsPerSec = float64(runOps) / seconds
+			}
+			writeTimeline(record)
+		}
+		pending, runOps = pending[:0], 0
+	}
+
+	flushBlock := func(bt *BlockTimeline) {
+		if db != nil {
+			readMetadata(db, bt)
+		}
+		topSeries, topCount := bt.TopSeries()
+		record := TimelineRecord{
+			BlockID:      bt.BlockID,
+			StartTime:    bt.StartTime.Format(time.RFC3339),
+			WallSeconds:  math.NaN(),
+			Ops:          bt.Ops,
+			Reads:        bt.Reads,
+			Writes:       bt.Writes,
+			Deletes:      bt.Deletes,
+			BytesWritten: bt.BytesWritten,
+			OpsPerSec:    math.NaN(),
+			GasUsed:      math.NaN(),
+			GasLimit:     math.NaN(),
+			TxCount:      math.NaN(),
+			BlobCount:    math.NaN(),
+			TopSeries:    topSeries,
+			TopSeriesOps: topCount,
+		}
+		if bt.HasMetadata {
+			record.GasUsed, record.GasLimit = float64(bt.GasUsed), float64(bt.GasLimit)
+			record.TxCount, record.BlobCount = float64(bt.TxCount), float64(bt.BlobCount)
+		}
+		if wall, ok := bt.WallSeconds(); ok {
+			record.WallSeconds = float64(wall)
+			if len(pending) == 0 {
+				runStart = bt.StartTime
+			}
+			pending = append(pending, record)
+			runOps += bt.Ops
+			if seconds := bt.EndTime.Sub(runStart).Seconds(); seconds >= 1 {
+				writeRun(seconds)
+			}
+		} else {
+			// A block without its end marker has no wall time and ends the run, which has no rate if shorter than one second
+			writeRun(0)
+			writeTimeline(record)
+		}
+
+		seriesNames := make([]string, 0, len(bt.Counts))
+		for series := range bt.Counts {
+			seriesNames = append(seriesNames, series)
+		}
+		sort.Strings(seriesNames)
+		for _, series := range seriesNames {
+			parts := strings.SplitN(series, ":", 2)
+			if opsRecords != nil {
+				if err := opsRecords.Write(BlockOpsRecord{BlockID: bt.BlockID, Category: parts[0], OpType: parts[1], Count: bt.Counts[series]}); err != nil {
+					log.Fatalf("Cannot write to %s: %v", opsPath, err)
+				}
+			} else {
+				fmt.Fprintf(opsWriter, "%d,%s,%s,%d\n", bt.BlockID, parts[0], parts[1], bt.Counts[series])
+			}
+		}
+
+		if bt.HasMetadata {
+			_, hasWall := bt.WallSeconds()
+			for _, traceVariable := range traceVariables {
+				if traceVariable == "WallSeconds" && !hasWall {
+					continue
+				}
+				for _, metadataVariable := range metadataVariables {
+					name := traceVariable + "~" + metadataVariable
+					if _, exists := correlations[name]; !exists {
+						correlations[name] = &RunningPearson{}
+					}
+					correlations[name].Add(bt.variable(traceVariable), bt.variable(metadataVariable))
+				}
+			}
+		}
+		summaries = append(summaries, BlockSummary{
+			BlockID:      bt.BlockID,
+			Ops:          bt.Ops,
+			Writes:       bt.Writes,
+			BytesWritten: bt.BytesWritten,
+			GasUsed:      bt.GasUsed,
+			TxCount:      bt.TxCount,
+			BlobCount:    bt.BlobCount,
+			TopSeries:    topSeries,
+		})
+	}
+
+	reader := bufio.NewReader(file)
+	var current *BlockTimeline
+	var lineCount uint64
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 && current != nil {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, current.BlockID, elapsed)
+		}
+
+		if matches := blockMarkerRegex.FindStringSubmatch(line); matches != nil {
+			timestamp, err := time.ParseInLocation(timestampLayout, matches[1], time.Local)
+			if err != nil {
+				fmt.Println("Error parsing timestamp:", err)
+				continue
+			}
+			id, err := strconv.ParseUint(matches[3], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if matches[2] == "end" {
+				if current != nil && current.BlockID == id {
+					current.EndTime = timestamp
+					current.HasEnd = true
+				}
+				continue
+			}
+			if current != nil {
+				flushBlock(current)
+				current = nil
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				current = &BlockTimeline{BlockID: id, Hash: matches[4], StartTime: timestamp, Counts: make(map[string]uint64)}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		opType := matches[1]
+		category := "noPrefix"
+		if matches[2] != "" {
+			category = matchPrefix(matches[2])
+		} else if matches[5] != "" {
+			category = matchPrefix(matches[5])
+		}
+		current.Counts[category+":"+opType]++
+		current.Ops++
+		keySize, _ := strconv.ParseUint(matches[3], 10, 64)
+		valueSize, _ := strconv.ParseUint(matches[4], 10, 64)
+		switch opType {
+		case "Get", "Has":
+			current.Reads++
+		case "Put", "BatchPut", "Update":
+			current.Writes++
+			current.BytesWritten += keySize + valueSize
+		case "Delete", "BatchDelete":
+			current.Deletes++
+			current.BytesWritten += keySize
+		}
+	}
+	if current != nil {
+		flushBlock(current)
+	}
+	writeRun(0)
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].BytesWritten != summaries[j].BytesWritten {
+			return summaries[i].BytesWritten > summaries[j].BytesWritten
+		}
+		return summaries[i].BlockID < summaries[j].BlockID
+	})
+
+	summaryPath := "blockTimeline-summary-" + rangeName + ".txt"
+	if *format != "text" {
+		fmt.Printf("Blocks: %d\n", len(summaries))
+		var correlationRecords []CorrelationRecord
+		for _, traceVariable := range traceVariables {
+			for _, metadataVariable := range metadataVariables {
+				record := CorrelationRecord{TraceVariable: traceVariable, MetadataVariable: metadataVariable, Pearson: math.NaN()}
+				if rp, exists := correlations[traceVariable+"~"+metadataVariable]; exists {
+					record.Pearson = rp.Value()
+				}
+				correlationRecords = append(correlationRecords, record)
+			}
+		}
+		if err := writeRecords(outputPath(summaryPath, *format), *format, correlationRecords); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		var heavyRecords []HeavyBlockRecord
+		for i, summary := range summaries {
+			if i >= 100 {
+				break
+			}
+			heavyRecords = append(heavyRecords, HeavyBlockRecord(summary))
+		}
+		if err := writeRecords(outputPath("blockTimeline-heavy-"+rangeName+".txt", *format), *format, heavyRecords); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	timelineWriter.Flush()
+	opsWriter.Flush()
+
+	summaryFile, err := os.Create(summaryPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", summaryPath)
+		return
+	}
+	defer summaryFile.Close()
+	fmt.Fprintf(summaryFile, "Blocks: %d\n", len(summaries))
+	fmt.Fprintln(summaryFile, "Pearson correlation between the block I/O and the block metadata:")
+	fmt.Fprintf(summaryFile, "Variable %s\n", strings.Join(metadataVariables, " "))
+	for _, traceVariable := range traceVariables {
+		fmt.Fprint(summaryFile, traceVariable)
+		for _, metadataVariable := range metadataVariables {
+			value := math.NaN()
+			if rp, exists := correlations[traceVariable+"~"+metadataVariable]; exists {
+				value = rp.Value()
+			}
+			fmt.Fprintf(summaryFile, " %.6f", value)
+		}
+		fmt.Fprintln(summaryFile)
+	}
+
+	fmt.Fprintln(summaryFile, "Top 100 I/O-heavy blocks by bytes written:")
+	fmt.Fprintln(summaryFile, "BlockID Ops Writes BytesWritten GasUsed TxCount BlobCount TopSeries")
+	for i, summary := range summaries {
+		if i >= 100 {
+			break
+		}
+		fmt.Fprintf(summaryFile, "%d %d %d %d %d %d %d %s\n", summary.BlockID, summary.Ops, summary.Writes, summary.BytesWritten,
+			summary.GasUsed, summary.TxCount, summary.BlobCount, summary.TopSeries)
+	}
+	fmt.Printf("Statistics are stored to: %s, %s, and %s\n", timelinePath, opsPath, summaryPath)
+}
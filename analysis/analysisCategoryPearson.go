@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// PairCorrelation stores the correlation of two time series, where SeriesB is shifted by Lag bins
+type PairCorrelation struct {
+	SeriesA  string
+	SeriesB  string
+	Lag      int
+	Pearson  float64
+	Spearman float64
+}
+
+// CorrelationRecord is a record of categoryPearson-ranked.{json,csv}, the lag matrices are always written as CSV
+type CorrelationRecord struct {
+	SeriesA  string  `json:"series_a"`
+	SeriesB  string  `json:"series_b"`
+	Lag      int     `json:"lag"`
+	Pearson  float64 `json:"pearson"`
+	Spearman float64 `json:"spearman"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), (?:key: ([a-fA-F0-9]+)|prefix: ([a-fA-F0-9]*))`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// buildTimeSeries counts the operations of each category and operation type per bin of blocksPerBin blocks
+func buildTimeSeries(filePath string, progressInterval, startBlockNumber, endBlockNumber, blocksPerBin uint64) map[string][]uint32 {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	binCount := int((endBlockNumber-startBlockNumber)/blocksPerBin) + 1
+	series := make(map[string][]uint32)
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		category := "noPrefix"
+		if matches[2] != "" {
+			category = matchPrefix(matches[2])
+		} else if matches[3] != "" {
+			category = matchPrefix(matches[3])
+		}
+		name := category + ":" + matches[1]
+		if _, exists := series[name]; !exists {
+			series[name] = make([]uint32, binCount)
+		}
+		series[name][(currentBlockID-startBlockNumber)/blocksPerBin]++
+	}
+	return series
+}
+
+// averageRanks converts the values to ranks, tied values get the average of their ranks
+func averageRanks(values []float64) []float64 {
+	indices := make([]int, len(values))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return values[indices[i]] < values[indices[j]]
+	})
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && values[indices[j+1]] == values[indices[i]] {
+			j++
+		}
+		averageRank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[indices[k]] = averageRank
+		}
+		i = j + 1
+	}
+	return ranks
+}
+
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if n < 2 {
+		return math.NaN()
+	}
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+func toFloat(values []uint32) []float64 {
+	result := make([]float64, len(values))
+	for i, v := range values {
+		result[i] = float64(v)
+	}
+	return result
+}
+
+// computeCorrelations returns the correlation matrices of all series for the given lag, where
+// entry [i][j] is the correlation between series i at bin t and series j at bin t+lag
+func computeCorrelations(names []string, series map[string][]uint32, lag int) ([][]float64, [][]float64) {
+	n := len(names)
+	pearsonMatrix := make([][]float64, n)
+	spearmanMatrix := make([][]float64, n)
+	heads := make([][]float64, n) // series[0 : len-lag]
+	tails := make([][]float64, n) // series[lag : len]
+	headRanks := make([][]float64, n)
+	tailRanks := make([][]float64, n)
+	for i, name := range names {
+		values := toFloat(series[name])
+		heads[i] = values[:len(values)-lag]
+		tails[i] = values[lag:]
+		headRanks[i] = averageRanks(heads[i])
+		tailRanks[i] = averageRanks(tails[i])
+	}
+	for i := 0; i < n; i++ {
+		pearsonMatrix[i] = make([]float64, n)
+		spearmanMatrix[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			pearsonMatrix[i][j] = pearson(heads[i], tails[j])
+			spearmanMatrix[i][j] = pearson(headRanks[i], tailRanks[j])
+		}
+	}
+	return pearsonMatrix, spearmanMatrix
+}
+
+func writeMatrixCSV(fileName string, names []string, matrix [][]float64) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "series,%s\n", strings.Join(names, ","))
+	for i, name := range names {
+		fmt.Fprint(writer, name)
+		for j := range names {
+			fmt.Fprintf(writer, ",%.6f", matrix[i][j])
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 6 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <start_block_number> <end_block_number> <blocks_per_bin> <max_lag> [print_progress_interval]")
+		return
+	}
+	logFilePath := args[1]
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	blocksPerBin, _ := strconv.ParseUint(args[4], 10, 64)
+	maxLag, _ := strconv.Atoi(args[5])
+	progressInterval := uint64(10000)
+	if len(args) > 6 {
+		progressInterval, _ = strconv.ParseUint(args[6], 10, 64)
+	}
+	if blocksPerBin == 0 {
+		blocksPerBin = 1
+	}
+	if progressInterval == 0 {
+		progressInterval = 10000
+	}
+	if endBlockNumber < startBlockNumber {
+		fmt.Println("Invalid block range:", startBlockNumber, endBlockNumber)
+		return
+	}
+
+	series := buildTimeSeries(logFilePath, progressInterval, startBlockNumber, endBlockNumber, blocksPerBin)
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	binCount := int((endBlockNumber-startBlockNumber)/blocksPerBin) + 1
+	if maxLag >= binCount-1 {
+		maxLag = binCount - 2
+	}
+	fmt.Printf("Built %d time series with %d bins (%d blocks per bin)\n", len(names), binCount, blocksPerBin)
+
+	var ranked []PairCorrelation
+	for lag := 0; lag <= maxLag; lag++ {
+		fmt.Printf("Computing correlations, lag: %d\n", lag)
+		pearsonMatrix, spearmanMatrix := computeCorrelations(names, series, lag)
+		pearsonFile := fmt.Sprintf("categoryPearson-lag%d.csv", lag)
+		spearmanFile := fmt.Sprintf("categorySpearman-lag%d.csv", lag)
+		if err := writeMatrixCSV(pearsonFile, names, pearsonMatrix); err != nil {
+			fmt.Println("Error:", err)
+		}
+		if err := writeMatrixCSV(spearmanFile, names, spearmanMatrix); err != nil {
+			fmt.Println("Error:", err)
+		}
+
+		for i := range names {
+			for j := range names {
+				// Without lag the matrix is symmetric, and the self-correlation is always 1
+				if i == j || (lag == 0 && j < i) {
+					continue
+				}
+				if math.IsNaN(pearsonMatrix[i][j]) {
+					continue
+				}
+				ranked = append(ranked, PairCorrelation{
+					SeriesA:  names[i],
+					SeriesB:  names[j],
+					Lag:      lag,
+					Pearson:  pearsonMatrix[i][j],
+					Spearman: spearmanMatrix[i][j],
+				})
+			}
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return math.Abs(ranked[i].Pearson) > math.Abs(ranked[j].Pearson)
+	})
+	const rankedFilePath = "categoryPearson-ranked.txt"
+	if *format != "text" {
+		records := make([]CorrelationRecord, 0, len(ranked))
+		for _, pair := range ranked {
+			records = append(records, CorrelationRecord(pair))
+		}
+		if err := writeRecords(outputPath(rankedFilePath, *format), *format, records); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	rankedFile, err := os.Create(rankedFilePath)
+	if err != nil {
+		fmt.Println("Error creating output file:", rankedFilePath)
+		return
+	}
+	defer rankedFile.Close()
+	fmt.Fprintln(rankedFile, "SeriesA\tSeriesB\tLag\tPearson\tSpearman")
+	for _, pair := range ranked {
+		fmt.Fprintf(rankedFile, "%s\t%s\t%d\t%.6f\t%.6f\n", pair.SeriesA, pair.SeriesB, pair.Lag, pair.Pearson, pair.Spearman)
+	}
+	fmt.Printf("Ranked category pairs are stored to: %s\n", rankedFilePath)
+}
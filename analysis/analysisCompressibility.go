@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// ValueSample is a sampled value with its key
+type ValueSample struct {
+	Key   []byte
+	Value []byte
+}
+
+// Reservoir keeps a uniform sample of the values of a category
+type Reservoir struct {
+	Seen    uint64 // Number of values offered
+	Samples []ValueSample
+}
+
+// CompressionStats store the compressed sizes of the sampled values of a category
+type CompressionStats struct {
+	Values       int
+	RawBytes     uint64
+	Snappy       uint64 // Each value compressed individually
+	Zstd         uint64
+	SnappyRandom uint64 // Values grouped into blocks in random order
+	ZstdRandom   uint64
+	SnappySorted uint64 // Values grouped into blocks in key order
+	ZstdSorted   uint64
+}
+
+// CompressionRecord is a record of compressibility-<source>.{json,csv}, the ratios are raw bytes / compressed bytes,
+// and the last record (All) is over all categories
+type CompressionRecord struct {
+	Category           string  `json:"category"`
+	Values             int     `json:"values"`
+	SeenValues         uint64  `json:"seen_values"`
+	RawBytes           uint64  `json:"raw_bytes"`
+	AverageValueSize   float64 `json:"average_value_size"`
+	SnappyIndividual   float64 `json:"snappy_individual"`
+	ZstdIndividual     float64 `json:"zstd_individual"`
+	SnappyRandomBlocks float64 `json:"snappy_random_blocks"`
+	ZstdRandomBlocks   float64 `json:"zstd_random_blocks"`
+	SnappySortedBlocks float64 `json:"snappy_sorted_blocks"`
+	ZstdSortedBlocks   float64 `json:"zstd_sorted_blocks"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	// Only the writes have values
+	valueLineRegex  = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: \d+, value: ([a-fA-F0-9]*), size: \d+`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// offer adds the value to the reservoir, replacing a random sample once the reservoir is full
+func (r *Reservoir) offer(key, value []byte, capacity int, rng *rand.Rand) {
+	r.Seen++
+	if len(r.Samples) < capacity {
+		r.Samples = append(r.Samples, ValueSample{Key: key, Value: value})
+		return
+	}
+	if j := rng.Int63n(int64(r.Seen)); j < int64(capacity) {
+		r.Samples[j] = ValueSample{Key: key, Value: value}
+	}
+}
+
+// sampleTrace samples the written values of each category in the block range of the trace
+func sampleTrace(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64, capacity int, rng *rand.Rand) map[string]*Reservoir {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	reservoirs := make(map[string]*Reservoir)
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := valueLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		switch matches[1] {
+		case "Put", "BatchPut", "Update":
+		default:
+			continue
+		}
+		key, err := hex.DecodeString(matches[2])
+		if err != nil {
+			fmt.Println("Error decoding hex key:", err)
+			continue
+		}
+		value, err := hex.DecodeString(matches[3])
+		if err != nil {
+			fmt.Println("Error decoding hex value:", err)
+			continue
+		}
+		category := matchPrefix(matches[2])
+		if _, exists := reservoirs[category]; !exists {
+			reservoirs[category] = &Reservoir{}
+		}
+		reservoirs[category].offer(key, value, capacity, rng)
+	}
+	return reservoirs
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the prefix, nil if there is none
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// sampleDB samples the values of each category from the KV store with a full scan of its prefix range. The values are
+// split into runs of runLength adjacent keys, and the runs are sampled uniformly (reservoir sampling) until capacity
+// values are kept, so that the sample does not depend on how the keys are spread over the key space (e.g., the block
+// numbers or the trie paths). The keys of a longer prefix in the range are skipped
+func sampleDB(db *pebble.DB, capacity, runLength int, rng *rand.Rand) (map[string]*Reservoir, error) {
+	reservoirs := make(map[string]*Reservoir)
+	slots := (capacity + runLength - 1) / runLength
+	for _, prefixCategory := range hexPrefixes {
+		prefix, err := hex.DecodeString(prefixCategory.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %s: %v", prefixCategory.Prefix, err)
+		}
+		iter, err := db.NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create iterator: %v", err)
+		}
+		reservoir := &Reservoir{}
+		runs := make([][]ValueSample, 0, slots)
+		slot := -1 // Slot of the current run, -1 if the run is not sampled
+		for valid := iter.First(); valid; valid = iter.Next() {
+			if matchPrefix(hex.EncodeToString(iter.Key())) != prefixCategory.Category {
+				continue
+			}
+			if reservoir.Seen%uint64(runLength) == 0 {
+				run := int64(reservoir.Seen / uint64(runLength))
+				slot = -1
+				if len(runs) < slots {
+					runs = append(runs, nil)
+					slot = len(runs) - 1
+				} else if j := rng.Int63n(run + 1); j < int64(slots) {
+					slot = int(j)
+					runs[slot] = runs[slot][:0]
+				}
+			}
+			reservoir.Seen++
+			if slot >= 0 {
+				runs[slot] = append(runs[slot], ValueSample{Key: append([]byte{}, iter.Key()...), Value: append([]byte{}, iter.Value()...)})
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			reservoir.Samples = append(reservoir.Samples, run...)
+		}
+		if len(reservoir.Samples) > capacity {
+			reservoir.Samples = reservoir.Samples[:capacity]
+		}
+		if len(reservoir.Samples) > 0 {
+			fmt.Printf("Sampled %d values of %d in %s\n", len(reservoir.Samples), reservoir.Seen, prefixCategory.Category)
+			reservoirs[prefixCategory.Category] = reservoir
+		}
+	}
+	return reservoirs, nil
+}
+
+// compressBlocks concatenates the values into blocks of at least blockSize bytes (the last block may be smaller), and
+// returns the total compressed sizes of the blocks
+func compressBlocks(samples []ValueSample, blockSize int, encoder *zstd.Encoder) (uint64, uint64) {
+	var snappySize, zstdSize uint64
+	var block []byte
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		snappySize += uint64(len(snappy.Encode(nil, block)))
+		zstdSize += uint64(len(encoder.EncodeAll(block, nil)))
+		block = block[:0]
+	}
+	for _, sample := range samples {
+		block = append(block, sample.Value...)
+		if len(block) >= blockSize {
+			flush()
+		}
+	}
+	flush()
+	return snappySize, zstdSize
+}
+
+// measure compresses the samples individually, and in blocks in random and in key order
+func measure(samples []ValueSample, blockSize int, encoder *zstd.Encoder, rng *rand.Rand) *CompressionStats {
+	stats := &CompressionStats{Values: len(samples)}
+	for _, sample := range samples {
+		stats.RawBytes += uint64(len(sample.Value))
+		stats.Snappy += uint64(len(snappy.Encode(nil, sample.Value)))
+		stats.Zstd += uint64(len(encoder.EncodeAll(sample.Value, nil)))
+	}
+	shuffled := append([]ValueSample{}, samples...)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	stats.SnappyRandom, stats.ZstdRandom = compressBlocks(shuffled, blockSize, encoder)
+	sorted := append([]ValueSample{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+	stats.SnappySorted, stats.ZstdSorted = compressBlocks(sorted, blockSize, encoder)
+	return stats
+}
+
+// ratio returns raw / compressed, or NaN without compressed bytes
+func ratio(raw, compressed uint64) float64 {
+	if compressed == 0 {
+		return math.NaN()
+	}
+	return float64(raw) / float64(compressed)
+}
+
+func newCompressionRecord(category string, stats *CompressionStats) CompressionRecord {
+	record := CompressionRecord{
+		Category:           category,
+		Values:             stats.Values,
+		RawBytes:           stats.RawBytes,
+		SnappyIndividual:   ratio(stats.RawBytes, stats.Snappy),
+		ZstdIndividual:     ratio(stats.RawBytes, stats.Zstd),
+		SnappyRandomBlocks: ratio(stats.RawBytes, stats.SnappyRandom),
+		ZstdRandomBlocks:   ratio(stats.RawBytes, stats.ZstdRandom),
+		SnappySortedBlocks: ratio(stats.RawBytes, stats.SnappySorted),
+		ZstdSortedBlocks:   ratio(stats.RawBytes, stats.ZstdSorted),
+	}
+	if stats.Values > 0 {
+		record.AverageValueSize = float64(stats.RawBytes) / float64(stats.Values)
+	}
+	return record
+}
+
+// compressionRecords measures the samples of each category, sorted by the sampled raw bytes in descending order
+func compressionRecords(reservoirs map[string]*Reservoir, blockSize int, rng *rand.Rand) []CompressionRecord {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		log.Fatalf("Failed to create the zstd encoder: %v", err)
+	}
+	defer encoder.Close()
+
+	var categories []string
+	for category := range reservoirs {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	var records []CompressionRecord
+	total := &CompressionStats{}
+	seen := uint64(0)
+	for _, category := range categories {
+		stats := measure(reservoirs[category].Samples, blockSize, encoder, rng)
+		record := newCompressionRecord(category, stats)
+		record.SeenValues = reservoirs[category].Seen
+		records = append(records, record)
+		seen += record.SeenValues
+		total.Values += stats.Values
+		total.RawBytes += stats.RawBytes
+		total.Snappy += stats.Snappy
+		total.Zstd += stats.Zstd
+		total.SnappyRandom += stats.SnappyRandom
+		total.ZstdRandom += stats.ZstdRandom
+		total.SnappySorted += stats.SnappySorted
+		total.ZstdSorted += stats.ZstdSorted
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].RawBytes > records[j].RawBytes
+	})
+	all := newCompressionRecord("All", total)
+	all.SeenValues = seen
+	return append(records, all)
+}
+
+// underSampled returns the categories with fewer sampled values than the capacity
+func underSampled(records []CompressionRecord, capacity int) []string {
+	var categories []string
+	for _, record := range records {
+		if record.Category != "All" && record.Values < capacity {
+			categories = append(categories, record.Category)
+		}
+	}
+	return categories
+}
+
+// printStats writes the compression ratios of each category, and the gain of grouping the values in key order
+func printStats(outputFile *os.File, records []CompressionRecord, blockSize, capacity int) {
+	for _, record := range records {
+		fmt.Fprintf(outputFile, "Category: %s\n", record.Category)
+		fmt.Fprintf(outputFile, "  Sampled values: %d of %d\n", record.Values, record.SeenValues)
+		if record.Category != "All" && record.Values < capacity {
+			fmt.Fprintf(outputFile, "  Under-sampled: fewer than %d values\n", capacity)
+		}
+		fmt.Fprintf(outputFile, "  Raw bytes: %d, average value size: %.2f\n", record.RawBytes, record.AverageValueSize)
+		fmt.Fprintf(outputFile, "  Individual: snappy: %.3f, zstd: %.3f\n", record.SnappyIndividual, record.ZstdIndividual)
+		fmt.Fprintf(outputFile, "  Blocks of %d B in random order: snappy: %.3f, zstd: %.3f\n", blockSize, record.SnappyRandomBlocks, record.ZstdRandomBlocks)
+		fmt.Fprintf(outputFile, "  Blocks of %d B in key order: snappy: %.3f, zstd: %.3f\n", blockSize, record.SnappySortedBlocks, record.ZstdSortedBlocks)
+		fmt.Fprintf(outputFile, "  Key order gain: snappy: %+.2f%%, zstd: %+.2f%%\n",
+			(record.SnappySortedBlocks/record.SnappyRandomBlocks-1)*100, (record.ZstdSortedBlocks/record.ZstdRandomBlocks-1)*100)
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	capacity := flag.Int("samples", 10000, "maximum number of sampled values of each category")
+	runLength := flag.Int("run", 64, "number of adjacent keys in each sampled run of the KV store")
+	blockSize := flag.Int("block-size", 4096, "minimum uncompressed size of the grouped values")
+	seed := flag.Int64("seed", 1, "random seed of the sampling")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	validMode := len(args) >= 3 && ((args[1] == "db" && len(args) == 3) || (args[1] == "trace" && len(args) >= 5))
+	if !validMode || (*format != "text" && *format != "json" && *format != "csv") || *capacity < 1 || *runLength < 1 || *blockSize < 1 {
+		fmt.Println("Usage: program [--format=json|csv|text] [--samples=N] [--run=N] [--block-size=bytes] [--seed=N] db <pebble_database_path>")
+		fmt.Println("       program [--format=json|csv|text] [--samples=N] [--block-size=bytes] [--seed=N] trace <log_file_path> <start_block_number> <end_block_number> [print_progress_interval]")
+		return
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	var reservoirs map[string]*Reservoir
+	var outputFilePath string
+	if args[1] == "db" {
+		db, err := pebble.Open(args[2], &pebble.Options{ReadOnly: true})
+		if err != nil {
+			log.Fatalf("Cannot open target database, err: %v\n", err)
+		}
+		reservoirs, err = sampleDB(db, *capacity, *runLength, rng)
+		db.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputFilePath = "compressibility-db.txt"
+	} else {
+		startBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+		endBlockNumber, _ := strconv.ParseUint(args[4], 10, 64)
+		progressInterval := uint64(1000)
+		if len(args) > 5 {
+			progressInterval, _ = strconv.ParseUint(args[5], 10, 64)
+		}
+		if progressInterval == 0 {
+			progressInterval = 1000
+		}
+		reservoirs = sampleTrace(args[2], progressInterval, startBlockNumber, endBlockNumber, *capacity, rng)
+		outputFilePath = "compressibility-" + strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10) + ".txt"
+	}
+
+	records := compressionRecords(reservoirs, *blockSize, rng)
+	if categories := underSampled(records, *capacity); len(categories) > 0 {
+		fmt.Printf("Warning: fewer than %d values are sampled in %s\n", *capacity, strings.Join(categories, ", "))
+	}
+	if *format != "text" {
+		if err := writeRecords(outputPath(outputFilePath, *format), *format, records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+	printStats(outputFile, records, *blockSize, *capacity)
+	fmt.Printf("Statistics are stored to: %s\n", outputFilePath)
+}
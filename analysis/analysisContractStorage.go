@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// AccountStats stores the storage I/O of an account, from the snapshot ("o" + account hash + slot hash)
+// and the storage trie ("O" + account hash + path)
+type AccountStats struct {
+	SnapshotReads   uint64
+	SnapshotWrites  uint64
+	SnapshotDeletes uint64
+	TrieReads       uint64
+	TrieWrites      uint64
+	TrieDeletes     uint64
+	ReadBytes       uint64 // The trace only logs the key size of reads
+	WriteBytes      uint64 // Key and value sizes of writes, key sizes of deletes
+}
+
+// Ranking describes how the accounts are ranked in a top-K list
+type Ranking struct {
+	Name  string
+	Value func(*AccountStats) uint64
+}
+
+// TopAccountRecord is a record of contractStorage-<start>_<end>.{json,csv}, with the top accounts of each ranking
+type TopAccountRecord struct {
+	Ranking         string  `json:"ranking"`
+	Rank            int     `json:"rank"`
+	AccountHash     string  `json:"account_hash"`
+	Address         string  `json:"address"`
+	Reads           uint64  `json:"reads"`
+	Writes          uint64  `json:"writes"`
+	Bytes           uint64  `json:"bytes"`
+	Share           float64 `json:"share"`
+	CumulativeShare float64 `json:"cumulative_share"`
+}
+
+// RankingRecord is a record of contractStorage-summary-<start>_<end>.{json,csv}, top_share follows topShares (up
+// to the number of accounts)
+type RankingRecord struct {
+	Ranking  string    `json:"ranking"`
+	Accounts int       `json:"accounts"`
+	Gini     float64   `json:"gini"`
+	TopShare []float64 `json:"top_share"`
+}
+
+// AccountRecord is a record of contractStorage-accounts-<start>_<end>.{json,csv}
+type AccountRecord struct {
+	AccountHash     string `json:"account_hash"`
+	SnapshotReads   uint64 `json:"snapshot_reads"`
+	SnapshotWrites  uint64 `json:"snapshot_writes"`
+	SnapshotDeletes uint64 `json:"snapshot_deletes"`
+	TrieReads       uint64 `json:"trie_reads"`
+	TrieWrites      uint64 `json:"trie_writes"`
+	TrieDeletes     uint64 `json:"trie_deletes"`
+	ReadBytes       uint64 `json:"read_bytes"`
+	WriteBytes      uint64 `json:"write_bytes"`
+}
+
+// preimageReader adapts a pebble database to the ethdb.KeyValueReader used by rawdb
+type preimageReader struct {
+	db *pebble.DB
+}
+
+var (
+	// The value part only exists for writes, the value itself is skipped to avoid capturing large strings
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: (\d+)(?:, value: [a-fA-F0-9]*, size: (\d+))?`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+
+	rankings = []Ranking{
+		{"reads", func(as *AccountStats) uint64 { return as.Reads() }},
+		{"writes", func(as *AccountStats) uint64 { return as.Writes() }},
+		{"bytes", func(as *AccountStats) uint64 { return as.ReadBytes + as.WriteBytes }},
+	}
+	// Numbers of top accounts used to summarize the distribution
+	topShares = []int{1, 10, 100, 1000, 10000}
+)
+
+const (
+	snapshotStoragePrefix = "6f"
+	storageTriePrefix     = "4f"
+	accountHashHexLen     = 64
+)
+
+func (r *preimageReader) Has(key []byte) (bool, error) {
+	_, closer, err := r.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+func (r *preimageReader) Get(key []byte) ([]byte, error) {
+	value, closer, err := r.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return common.CopyBytes(value), nil
+}
+
+func (as *AccountStats) Reads() uint64 {
+	return as.SnapshotReads + as.TrieReads
+}
+
+// Writes includes the deletes, which are also writes to the storage
+func (as *AccountStats) Writes() uint64 {
+	return as.SnapshotWrites + as.SnapshotDeletes + as.TrieWrites + as.TrieDeletes
+}
+
+// powerOfTwoBucket returns the smallest power of two that is not smaller than the count (0 for 0)
+func powerOfTwoBucket(count uint64) uint64 {
+	if count == 0 {
+		return 0
+	}
+	return 1 << bits.Len64(count-1)
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64) map[string]*AccountStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	accountStats := make(map[string]*AccountStats)
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, accounts: %d, elapsed time: %.2fs", lineCount, currentBlockID, len(accountStats), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		opType, hexKey := matches[1], strings.ToLower(matches[2])
+		snapshot := strings.HasPrefix(hexKey, snapshotStoragePrefix)
+		if !snapshot && !strings.HasPrefix(hexKey, storageTriePrefix) {
+			continue
+		}
+		if len(hexKey) < 2+accountHashHexLen {
+			continue
+		}
+		accountHash := hexKey[2 : 2+accountHashHexLen]
+		if _, exists := accountStats[accountHash]; !exists {
+			accountStats[accountHash] = &AccountStats{}
+		}
+		as := accountStats[accountHash]
+		keySize, _ := strconv.ParseUint(matches[3], 10, 64)
+		valueSize, _ := strconv.ParseUint(matches[4], 10, 64)
+
+		switch opType {
+		case "Get", "Has":
+			if snapshot {
+				as.SnapshotReads++
+			} else {
+				as.TrieReads++
+			}
+			as.ReadBytes += keySize
+		case "Put", "BatchPut", "Update":
+			if snapshot {
+				as.SnapshotWrites++
+			} else {
+				as.TrieWrites++
+			}
+			as.WriteBytes += keySize + valueSize
+		case "Delete", "BatchDelete":
+			if snapshot {
+				as.SnapshotDeletes++
+			} else {
+				as.TrieDeletes++
+			}
+			as.WriteBytes += keySize
+		}
+	}
+	return accountStats
+}
+
+// resolveAddresses maps the account hashes back to the addresses through the preimage store, the accounts
+// without a preimage are left out
+func resolveAddresses(chaindataPath string, accountHashes []string) (map[string]string, error) {
+	db, err := pebble.Open(chaindataPath, &pebble.Options{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open target database: %v", err)
+	}
+	defer db.Close()
+
+	reader := &preimageReader{db: db}
+	addresses := make(map[string]string)
+	for _, accountHash := range accountHashes {
+		hashBytes, err := hex.DecodeString(accountHash)
+		if err != nil {
+			continue
+		}
+		// rawdb.ReadPreimage reads the "secure-key-" + hash entry
+		if preimage := rawdb.ReadPreimage(reader, common.BytesToHash(hashBytes)); len(preimage) == common.AddressLength {
+			addresses[accountHash] = common.BytesToAddress(preimage).Hex()
+		}
+	}
+	return addresses, nil
+}
+
+// topAccounts returns the account hashes sorted by the value in descending order
+func topAccounts(accountStats map[string]*AccountStats, value func(*AccountStats) uint64) []string {
+	accountHashes := make([]string, 0, len(accountStats))
+	for accountHash := range accountStats {
+		accountHashes = append(accountHashes, accountHash)
+	}
+	sort.Slice(accountHashes, func(i, j int) bool {
+		vi, vj := value(accountStats[accountHashes[i]]), value(accountStats[accountHashes[j]])
+		if vi != vj {
+			return vi > vj
+		}
+		return accountHashes[i] < accountHashes[j]
+	})
+	return accountHashes
+}
+
+// giniCoefficient returns the Gini coefficient of the values sorted in descending order
+func giniCoefficient(sortedValues []uint64) float64 {
+	n := len(sortedValues)
+	var sum, weighted float64
+	for i, value := range sortedValues {
+		sum += float64(value)
+		// Rank in ascending order, starting from 1
+		weighted += float64(n-i) * float64(value)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return (2*weighted)/(float64(n)*sum) - float64(n+1)/float64(n)
+}
+
+func printStats(outputFile *os.File, accountStats map[string]*AccountStats, topK int, addresses map[string]string, sortedByRanking map[string][]string) {
+	total := &AccountStats{}
+	for _, as := range accountStats {
+		total.SnapshotReads += as.SnapshotReads
+		total.SnapshotWrites += as.SnapshotWrites
+		total.SnapshotDeletes += as.SnapshotDeletes
+		total.TrieReads += as.TrieReads
+		total.TrieWrites += as.TrieWrites
+		total.TrieDeletes += as.TrieDeletes
+		total.ReadBytes += as.ReadBytes
+		total.WriteBytes += as.WriteBytes
+	}
+	fmt.Fprintf(outputFile, "Accounts with storage I/O: %d\n", len(accountStats))
+	fmt.Fprintf(outputFile, "  Snapshot reads: %d, writes: %d, deletes: %d\n", total.SnapshotReads, total.SnapshotWrites, total.SnapshotDeletes)
+	fmt.Fprintf(outputFile, "  Trie reads: %d, writes: %d, deletes: %d\n", total.TrieReads, total.TrieWrites, total.TrieDeletes)
+	fmt.Fprintf(outputFile, "  Read bytes: %d, write bytes: %d\n", total.ReadBytes, total.WriteBytes)
+	fmt.Fprintf(outputFile, "  Accounts mapped to addresses: %d\n", len(addresses))
+
+	for _, ranking := range rankings {
+		accountHashes := sortedByRanking[ranking.Name]
+		values := make([]uint64, len(accountHashes))
+		var sum uint64
+		for i, accountHash := range accountHashes {
+			values[i] = ranking.Value(accountStats[accountHash])
+			sum += values[i]
+		}
+
+		fmt.Fprintf(outputFile, "Distribution of %s across accounts:\n", ranking.Name)
+		fmt.Fprintf(outputFile, "  Gini coefficient: %.6f\n", giniCoefficient(values))
+		for _, top := range topShares {
+			if top > len(values) {
+				break
+			}
+			var topSum uint64
+			for _, value := range values[:top] {
+				topSum += value
+			}
+			fmt.Fprintf(outputFile, "  Share of top %d accounts: %.6f\n", top, float64(topSum)/float64(max(sum, 1)))
+		}
+		histogram := make(map[uint64]uint64)
+		for _, value := range values {
+			histogram[powerOfTwoBucket(value)]++
+		}
+		buckets := make([]uint64, 0, len(histogram))
+		for bucket := range histogram {
+			buckets = append(buckets, bucket)
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			return buckets[i] < buckets[j]
+		})
+		for _, bucket := range buckets {
+			fmt.Fprintf(outputFile, "  Accounts with at most %d %s: %d\n", bucket, ranking.Name, histogram[bucket])
+		}
+
+		fmt.Fprintf(outputFile, "Top %d accounts by %s:\n", topK, ranking.Name)
+		fmt.Fprintln(outputFile, "Rank AccountHash Address Reads Writes Bytes Share CumulativeShare")
+		var cumulative uint64
+		for i, accountHash := range accountHashes {
+			if i >= topK {
+				break
+			}
+			as := accountStats[accountHash]
+			cumulative += values[i]
+			address, exists := addresses[accountHash]
+			if !exists {
+				address = "-"
+			}
+			fmt.Fprintf(outputFile, "%d %s %s %d %d %d %.6f %.6f\n", i+1, accountHash, address, as.Reads(), as.Writes(), as.ReadBytes+as.WriteBytes,
+				float64(values[i])/float64(max(sum, 1)), float64(cumulative)/float64(max(sum, 1)))
+		}
+	}
+}
+
+// printAccounts writes the storage I/O of all accounts, sorted by the number of operations
+func printAccounts(outputFile *os.File, accountStats map[string]*AccountStats) {
+	writer := bufio.NewWriter(outputFile)
+	fmt.Fprintln(writer, "AccountHash SnapshotReads SnapshotWrites SnapshotDeletes TrieReads TrieWrites TrieDeletes ReadBytes WriteBytes")
+	for _, accountHash := range topAccounts(accountStats, func(as *AccountStats) uint64 { return as.Reads() + as.Writes() }) {
+		as := accountStats[accountHash]
+		fmt.Fprintf(writer, "%s %d %d %d %d %d %d %d %d\n", accountHash, as.SnapshotReads, as.SnapshotWrites, as.SnapshotDeletes,
+			as.TrieReads, as.TrieWrites, as.TrieDeletes, as.ReadBytes, as.WriteBytes)
+	}
+	writer.Flush()
+}
+
+// writeStructured writes the top accounts, the ranking summaries, and all accounts as records
+func writeStructured(outputFilePath, accountsPath, format string, accountStats map[string]*AccountStats, topK int, addresses map[string]string, sortedByRanking map[string][]string) error {
+	var topRecords []TopAccountRecord
+	var rankingRecords []RankingRecord
+	for _, ranking := range rankings {
+		accountHashes := sortedByRanking[ranking.Name]
+		values := make([]uint64, len(accountHashes))
+		var sum uint64
+		for i, accountHash := range accountHashes {
+			values[i] = ranking.Value(accountStats[accountHash])
+			sum += values[i]
+		}
+
+		rankingRecord := RankingRecord{Ranking: ranking.Name, Accounts: len(values), Gini: giniCoefficient(values)}
+		for _, top := range topShares {
+			if top > len(values) {
+				break
+			}
+			var topSum uint64
+			for _, value := range values[:top] {
+				topSum += value
+			}
+			rankingRecord.TopShare = append(rankingRecord.TopShare, float64(topSum)/float64(max(sum, 1)))
+		}
+		rankingRecords = append(rankingRecords, rankingRecord)
+
+		var cumulative uint64
+		for i, accountHash := range accountHashes {
+			if i >= topK {
+				break
+			}
+			as := accountStats[accountHash]
+			cumulative += values[i]
+			topRecords = append(topRecords, TopAccountRecord{
+				Ranking:         ranking.Name,
+				Rank:            i + 1,
+				AccountHash:     accountHash,
+				Address:         addresses[accountHash],
+				Reads:           as.Reads(),
+				Writes:          as.Writes(),
+				Bytes:           as.ReadBytes + as.WriteBytes,
+				Share:           float64(values[i]) / float64(max(sum, 1)),
+				CumulativeShare: float64(cumulative) / float64(max(sum, 1)),
+			})
+		}
+	}
+	if err := writeRecords(outputPath(outputFilePath, format), format, topRecords); err != nil {
+		return err
+	}
+	summaryPath := strings.Replace(outputFilePath, "contractStorage-", "contractStorage-summary-", 1)
+	if err := writeRecords(outputPath(summaryPath, format), format, rankingRecords); err != nil {
+		return err
+	}
+
+	var accountRecords []AccountRecord
+	for _, accountHash := range topAccounts(accountStats, func(as *AccountStats) uint64 { return as.Reads() + as.Writes() }) {
+		as := accountStats[accountHash]
+		accountRecords = append(accountRecords, AccountRecord{
+			AccountHash:     accountHash,
+			SnapshotReads:   as.SnapshotReads,
+			SnapshotWrites:  as.SnapshotWrites,
+			SnapshotDeletes: as.SnapshotDeletes,
+			TrieReads:       as.TrieReads,
+			TrieWrites:      as.TrieWrites,
+			TrieDeletes:     as.TrieDeletes,
+			ReadBytes:       as.ReadBytes,
+			WriteBytes:      as.WriteBytes,
+		})
+	}
+	return writeRecords(outputPath(accountsPath, format), format, accountRecords)
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 5 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <start_block_number> <end_block_number> <top_k> [chaindata_path] [print_progress_interval]")
+		return
+	}
+	logFilePath := args[1]
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	topK, _ := strconv.Atoi(args[4])
+	chaindataPath := ""
+	if len(args) > 5 {
+		chaindataPath = args[5]
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 6 {
+		progressInterval, _ = strconv.ParseUint(args[6], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+
+	accountStats := processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber)
+
+	sortedByRanking := make(map[string][]string)
+	topSet := make(map[string]struct{})
+	var topHashes []string
+	for _, ranking := range rankings {
+		sortedByRanking[ranking.Name] = topAccounts(accountStats, ranking.Value)
+		for i, accountHash := range sortedByRanking[ranking.Name] {
+			if i >= topK {
+				break
+			}
+			if _, exists := topSet[accountHash]; !exists {
+				topSet[accountHash] = struct{}{}
+				topHashes = append(topHashes, accountHash)
+			}
+		}
+	}
+
+	// Only the top accounts are mapped, since a lookup is needed for each account
+	addresses := make(map[string]string)
+	if chaindataPath != "" {
+		var err error
+		addresses, err = resolveAddresses(chaindataPath, topHashes)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Mapped %d of %d top accounts to addresses\n", len(addresses), len(topHashes))
+	}
+
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	outPutLogPath := "contractStorage-" + rangeName + ".txt"
+	accountsPath := "contractStorage-accounts-" + rangeName + ".txt"
+	if *format != "text" {
+		if err := writeStructured(outPutLogPath, accountsPath, *format, accountStats, topK, addresses, sortedByRanking); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	printStats(file, accountStats, topK, addresses, sortedByRanking)
+
+	accountsFile, err := os.Create(accountsPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", accountsPath)
+		return
+	}
+	defer accountsFile.Close()
+	printAccounts(accountsFile, accountStats)
+	fmt.Printf("Statistics are stored to: %s and %s\n", outPutLogPath, accountsPath)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// RunStats store the runs of adjacent deleted keys of a category in one grouping of the deletes
+type RunStats struct {
+	Deletes    uint64         // Number of delete operations
+	Tombstones uint64         // Number of distinct deleted keys in each group
+	Groups     uint64         // Number of groups (batches or blocks) with deletes of the category
+	Runs       map[int]uint64 // Number of runs of each length
+}
+
+// DeleteSummaryRecord is a record of deletePattern-<start>_<end>.{json,csv}
+type DeleteSummaryRecord struct {
+	Category           string  `json:"category"`
+	Grouping           string  `json:"grouping"`
+	Deletes            uint64  `json:"deletes"`
+	Tombstones         uint64  `json:"tombstones"`
+	Groups             uint64  `json:"groups"`
+	TombstonesPerGroup float64 `json:"tombstones_per_group"`
+	Runs               uint64  `json:"runs"`
+	AverageRunLength   float64 `json:"average_run_length"`
+	MaxRunLength       int     `json:"max_run_length"`
+}
+
+// RunLengthRecord is a record of deletePattern-runs-<start>_<end>.{json,csv}, the runs up to the run length (power-of-two
+// buckets)
+type RunLengthRecord struct {
+	Category   string `json:"category"`
+	Grouping   string `json:"grouping"`
+	RunLength  int    `json:"run_length"`
+	Runs       uint64 `json:"runs"`
+	Tombstones uint64 `json:"tombstones"`
+}
+
+// RangeDeleteRecord is a record of deletePattern-rangeDelete-<start>_<end>.{json,csv}, the runs of at least the minimum
+// run length replaced by range deletions
+type RangeDeleteRecord struct {
+	Category            string  `json:"category"`
+	Grouping            string  `json:"grouping"`
+	MinRunLength        int     `json:"min_run_length"`
+	RangeDeletions      uint64  `json:"range_deletions"`
+	ReplacedTombstones  uint64  `json:"replaced_tombstones"`
+	ReplacedRatio       float64 `json:"replaced_ratio"`
+	RemainingTombstones uint64  `json:"remaining_tombstones"`
+}
+
+// groupings are the ways the deletes are grouped: the deletes of a batch until its commit (a Delete outside of the
+// batches is a group by itself), and the deletes from the start of a block until the start of the next one
+var groupings = []string{"batch", "block"}
+
+// minRunLengths are the minimum run lengths at which the runs are replaced by range deletions
+var minRunLengths = []int{2, 4, 8, 16, 32, 64, 128, 256, 512, 1024}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+)(?:, key: ([a-fA-F0-9]+))?`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// powerOfTwoBucket returns the smallest power of two that is not smaller than the length
+func powerOfTwoBucket(length int) int {
+	if length <= 1 {
+		return length
+	}
+	return 1 << bits.Len(uint(length-1))
+}
+
+// DeleteGroup is the deleted keys of each category in a batch or a block
+type DeleteGroup map[string][][]byte
+
+// runLengths sorts the distinct keys, and returns the lengths of the runs of keys adjacent in the database, i.e.,
+// without a live key between them, which a range deletion could cover. The database is in its state after the trace,
+// so a key live at the time of the delete but deleted later is missed, and a key written later splits the run
+func runLengths(keys [][]byte, iter *pebble.Iterator) []int {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	unique := keys[:0]
+	for _, key := range keys {
+		if len(unique) == 0 || !bytes.Equal(unique[len(unique)-1], key) {
+			unique = append(unique, key)
+		}
+	}
+
+	var lengths []int
+	length := 0
+	for i, key := range unique {
+		if i > 0 {
+			// The first live key after the previous deleted key
+			if iter.SeekGE(append(append([]byte{}, unique[i-1]...), 0)) && bytes.Compare(iter.Key(), key) < 0 {
+				lengths = append(lengths, length)
+				length = 0
+			}
+		}
+		length++
+	}
+	if length > 0 {
+		lengths = append(lengths, length)
+	}
+	return lengths
+}
+
+func newRunStats() *RunStats {
+	return &RunStats{Runs: make(map[int]uint64)}
+}
+
+// flush adds the runs of the group to the statistics of the grouping
+func (g DeleteGroup) flush(runStats map[string]*RunStats, iter *pebble.Iterator) {
+	for category, keys := range g {
+		if _, exists := runStats[category]; !exists {
+			runStats[category] = newRunStats()
+		}
+		rs := runStats[category]
+		rs.Deletes += uint64(len(keys))
+		rs.Groups++
+		for _, length := range runLengths(keys, iter) {
+			rs.Tombstones += uint64(length)
+			rs.Runs[length]++
+		}
+		delete(g, category)
+	}
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64, iter *pebble.Iterator) map[string]map[string]*RunStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	runStats := make(map[string]map[string]*RunStats)
+	for _, grouping := range groupings {
+		runStats[grouping] = make(map[string]*RunStats)
+	}
+	batch, block := make(DeleteGroup), make(DeleteGroup)
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			block.flush(runStats["block"], iter)
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		switch matches[1] {
+		case "BatchPutCommit":
+			batch.flush(runStats["batch"], iter)
+		case "Delete", "BatchDelete":
+			key, err := hex.DecodeString(matches[2])
+			if err != nil {
+				fmt.Println("Error decoding hex key:", err)
+				continue
+			}
+			category := matchPrefix(strings.ToLower(matches[2]))
+			block[category] = append(block[category], key)
+			if matches[1] == "Delete" {
+				DeleteGroup{category: {key}}.flush(runStats["batch"], iter)
+				continue
+			}
+			batch[category] = append(batch[category], key)
+		}
+	}
+	// The batches not committed in the range are counted as committed at its end
+	batch.flush(runStats["batch"], iter)
+	block.flush(runStats["block"], iter)
+	return runStats
+}
+
+// sortedCategories returns the categories sorted by their deletes in the first grouping in descending order
+func sortedCategories(runStats map[string]map[string]*RunStats) []string {
+	var categories []string
+	for category := range runStats[groupings[0]] {
+		categories = append(categories, category)
+	}
+	deletes := runStats[groupings[0]]
+	sort.Slice(categories, func(i, j int) bool {
+		if deletes[categories[i]].Deletes != deletes[categories[j]].Deletes {
+			return deletes[categories[i]].Deletes > deletes[categories[j]].Deletes
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// sortedLengths returns the run lengths in ascending order
+func (rs *RunStats) sortedLengths() []int {
+	lengths := make([]int, 0, len(rs.Runs))
+	for length := range rs.Runs {
+		lengths = append(lengths, length)
+	}
+	sort.Ints(lengths)
+	return lengths
+}
+
+func summaryRecords(runStats map[string]map[string]*RunStats) []DeleteSummaryRecord {
+	var records []DeleteSummaryRecord
+	for _, category := range sortedCategories(runStats) {
+		for _, grouping := range groupings {
+			rs := runStats[grouping][category]
+			record := DeleteSummaryRecord{Category: category, Grouping: grouping, Deletes: rs.Deletes, Tombstones: rs.Tombstones, Groups: rs.Groups}
+			for length, runs := range rs.Runs {
+				record.Runs += runs
+				record.MaxRunLength = max(record.MaxRunLength, length)
+			}
+			record.TombstonesPerGroup = math.NaN()
+			record.AverageRunLength = math.NaN()
+			if rs.Groups > 0 {
+				record.TombstonesPerGroup = float64(rs.Tombstones) / float64(rs.Groups)
+			}
+			if record.Runs > 0 {
+				record.AverageRunLength = float64(rs.Tombstones) / float64(record.Runs)
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func runLengthRecords(runStats map[string]map[string]*RunStats) []RunLengthRecord {
+	var records []RunLengthRecord
+	for _, category := range sortedCategories(runStats) {
+		for _, grouping := range groupings {
+			rs := runStats[grouping][category]
+			var buckets []int
+			bucketRecords := make(map[int]*RunLengthRecord)
+			for _, length := range rs.sortedLengths() {
+				bucket := powerOfTwoBucket(length)
+				if _, exists := bucketRecords[bucket]; !exists {
+					buckets = append(buckets, bucket)
+					bucketRecords[bucket] = &RunLengthRecord{Category: category, Grouping: grouping, RunLength: bucket}
+				}
+				bucketRecords[bucket].Runs += rs.Runs[length]
+				bucketRecords[bucket].Tombstones += rs.Runs[length] * uint64(length)
+			}
+			for _, bucket := range buckets {
+				records = append(records, *bucketRecords[bucket])
+			}
+		}
+	}
+	return records
+}
+
+func rangeDeleteRecords(runStats map[string]map[string]*RunStats) []RangeDeleteRecord {
+	var records []RangeDeleteRecord
+	for _, category := range sortedCategories(runStats) {
+		for _, grouping := range groupings {
+			rs := runStats[grouping][category]
+			for _, minLength := range minRunLengths {
+				record := RangeDeleteRecord{Category: category, Grouping: grouping, MinRunLength: minLength, ReplacedRatio: math.NaN()}
+				for length, runs := range rs.Runs {
+					if length >= minLength {
+						record.RangeDeletions += runs
+						record.ReplacedTombstones += runs * uint64(length)
+					}
+				}
+				if rs.Tombstones > 0 {
+					record.ReplacedRatio = float64(record.ReplacedTombstones) / float64(rs.Tombstones)
+				}
+				record.RemainingTombstones = rs.Tombstones - record.ReplacedTombstones + record.RangeDeletions
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+func printStats(file *os.File, summaries []DeleteSummaryRecord, runLengths []RunLengthRecord, rangeDeletes []RangeDeleteRecord, adjacency string) {
+	fmt.Fprintf(file, "Adjacency: %s\n\n", adjacency)
+	for _, summary := range summaries {
+		if summary.Grouping == groupings[0] {
+			fmt.Fprintf(file, "Category: %s\n", summary.Category)
+		}
+		fmt.Fprintf(file, "  Grouped by %s:\n", summary.Grouping)
+		fmt.Fprintf(file, "    Deletes: %d, tombstones: %d, groups: %d, tombstones per group: %.2f\n",
+			summary.Deletes, summary.Tombstones, summary.Groups, summary.TombstonesPerGroup)
+		fmt.Fprintf(file, "    Runs: %d, average run length: %.2f, max run length: %d\n", summary.Runs, summary.AverageRunLength, summary.MaxRunLength)
+		fmt.Fprintln(file, "    Runs by length (up to length: runs, tombstones):")
+		for _, record := range runLengths {
+			if record.Category == summary.Category && record.Grouping == summary.Grouping {
+				fmt.Fprintf(file, "      %d: %d, %d\n", record.RunLength, record.Runs, record.Tombstones)
+			}
+		}
+		fmt.Fprintln(file, "    Range deletions (min run length: range deletions, replaced tombstones, remaining tombstones):")
+		for _, record := range rangeDeletes {
+			if record.Category == summary.Category && record.Grouping == summary.Grouping && record.RangeDeletions > 0 {
+				fmt.Fprintf(file, "      %d: %d, %d (%.2f%%), %d\n", record.MinRunLength, record.RangeDeletions,
+					record.ReplacedTombstones, record.ReplacedRatio*100, record.RemainingTombstones)
+			}
+		}
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 5 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <print_progress_interval> <start_block_number> <end_block_number> <chaindata_path>")
+		return
+	}
+	logFilePath := flag.Arg(0)
+	progressInterval, _ := strconv.ParseUint(flag.Arg(1), 10, 64)
+	startBlockNumber, _ := strconv.ParseUint(flag.Arg(2), 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(flag.Arg(3), 10, 64)
+	if progressInterval == 0 {
+		progressInterval = 1000
+	}
+
+	// The live keys of chaindata separate the runs, without it any group of deletes would look like one range
+	db, err := pebble.Open(flag.Arg(4), &pebble.Options{ReadOnly: true})
+	if err != nil {
+		log.Fatalf("Cannot open target database, err: %v\n", err)
+	}
+	defer db.Close()
+	iter, err := db.NewIter(nil)
+	if err != nil {
+		log.Fatalf("Failed to create iterator: %v\n", err)
+	}
+	defer iter.Close()
+	adjacency := "no live key between them in " + flag.Arg(4) + " (its state after the trace)"
+
+	runStats := processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber, iter)
+	summaries := summaryRecords(runStats)
+	runLengths := runLengthRecords(runStats)
+	rangeDeletes := rangeDeleteRecords(runStats)
+
+	blockRange := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	outPutLogPath := "deletePattern-" + blockRange + ".txt"
+	if *format != "text" {
+		if err := writeRecords(outputPath(outPutLogPath, *format), *format, summaries); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("deletePattern-runs-"+blockRange+".txt", *format), *format, runLengths); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("deletePattern-rangeDelete-"+blockRange+".txt", *format), *format, rangeDeletes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	printStats(file, summaries, runLengths, rangeDeletes, adjacency)
+	fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+}
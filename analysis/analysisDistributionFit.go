@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Counts up to exactDiscreteLimit are summed exactly, the tail beyond is approximated by an integral
+	exactDiscreteLimit = 1000
+	// Number of steps of the trapezoidal integration over log(x)
+	integrationSteps = 4000
+)
+
+// FitResult stores the fitted models and the skew of one distribution file
+type FitResult struct {
+	FileName       string
+	Category       string
+	OpType         string
+	KeyCount       int
+	AccessCount    uint64
+	ZipfS          float64 // Zipf exponent of the rank-frequency curve, p(rank) ~ rank^(-s)
+	ZipfKS         float64
+	ZipfLogLH      float64
+	PLAlpha        float64 // Power law with cutoff over the counts, p(x) ~ x^(-alpha) * exp(-lambda * x)
+	PLLambda       float64
+	PLKS           float64
+	PLLogLH        float64
+	LNMu           float64 // Discrete lognormal over the counts, p(x) ~ exp(-(ln(x)-mu)^2 / (2 * sigma^2)) / x
+	LNSigma        float64
+	LNKS           float64
+	LNLogLH        float64
+	TopShare       []float64 // Share of accesses going to the top keys (see topKeyFractions)
+	CountHistogram map[uint64]uint64
+}
+
+var topKeyFractions = []float64{0.001, 0.01, 0.1}
+
+// FitRecord is a record of distribution-fit.{json,csv}, top_share follows topKeyFractions
+type FitRecord struct {
+	Category  string    `json:"category"`
+	OpType    string    `json:"op_type"`
+	Keys      int       `json:"keys"`
+	Accesses  uint64    `json:"accesses"`
+	ZipfS     float64   `json:"zipf_s"`
+	ZipfKS    float64   `json:"zipf_ks"`
+	ZipfLogLH float64   `json:"zipf_loglh"`
+	PLAlpha   float64   `json:"pl_alpha"`
+	PLLambda  float64   `json:"pl_lambda"`
+	PLKS      float64   `json:"pl_ks"`
+	PLLogLH   float64   `json:"pl_loglh"`
+	LNMu      float64   `json:"ln_mu"`
+	LNSigma   float64   `json:"ln_sigma"`
+	LNKS      float64   `json:"ln_ks"`
+	LNLogLH   float64   `json:"ln_loglh"`
+	TopShare  []float64 `json:"top_share"`
+}
+
+// readCounts reads the counts (the last column) of a *_dis.txt file, or the count field of a *_dis.json or
+// *_dis.csv file, sorted in descending order
+func readCounts(fileName string) ([]uint64, error) {
+	if isStructured(fileName) {
+		records, err := readRecords(fileName)
+		if err != nil {
+			return nil, err
+		}
+		var counts []uint64
+		for _, record := range records {
+			if count, parseErr := strconv.ParseUint(record["count"], 10, 64); parseErr == nil && count > 0 {
+				counts = append(counts, count)
+			}
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			return counts[i] > counts[j]
+		})
+		return counts, nil
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	var counts []uint64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading file %s: %v", fileName, err)
+		}
+		parts := strings.Split(strings.TrimSpace(line), "\t")
+		if len(parts) >= 2 {
+			// The header line (ID ... Count) is skipped since the count cannot be parsed
+			if count, parseErr := strconv.ParseUint(parts[len(parts)-1], 10, 64); parseErr == nil && count > 0 {
+				counts = append(counts, count)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i] > counts[j]
+	})
+	return counts, nil
+}
+
+// parseFileName extracts the category and the operation type from the distribution file name,
+// e.g., distribution-<start>_<end>_<category>_<op>_dis.txt or <category>_<op>_with_key_dis.json
+func parseFileName(fileName string) (string, string) {
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	for _, suffix := range []string{"_with_key_dis", "_without_key_dis", "_dis"} {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	parts := strings.Split(name, "_")
+	if len(parts) < 2 {
+		return name, "unknown"
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// logSumExp returns log(sum(exp(values)))
+func logSumExp(values []float64) float64 {
+	maxValue := math.Inf(-1)
+	for _, v := range values {
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+	if math.IsInf(maxValue, -1) {
+		return maxValue
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += math.Exp(v - maxValue)
+	}
+	return maxValue + math.Log(sum)
+}
+
+// logIntegral returns log(integral of exp(logf(x)) over [lower, upper]), integrating over log(x)
+func logIntegral(logf func(float64) float64, lower, upper float64, steps int) float64 {
+	if upper <= lower {
+		return math.Inf(-1)
+	}
+	tLower, tUpper := math.Log(lower), math.Log(upper)
+	dt := (tUpper - tLower) / float64(steps)
+	terms := make([]float64, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		t := tLower + float64(i)*dt
+		weight := dt
+		if i == 0 || i == steps {
+			weight = dt / 2
+		}
+		// dx = x dt
+		terms = append(terms, logf(math.Exp(t))+t+math.Log(weight))
+	}
+	return logSumExp(terms)
+}
+
+// logPartialSum returns log(sum of exp(logf(x)) for x in [1, upper]), the tail beyond exactDiscreteLimit is integrated
+func logPartialSum(logf func(float64) float64, upper float64) float64 {
+	terms := make([]float64, 0, exactDiscreteLimit+1)
+	for x := 1; x <= exactDiscreteLimit && float64(x) <= upper; x++ {
+		terms = append(terms, logf(float64(x)))
+	}
+	if upper > exactDiscreteLimit {
+		terms = append(terms, logIntegral(logf, exactDiscreteLimit+0.5, upper+0.5, integrationSteps))
+	}
+	return logSumExp(terms)
+}
+
+// discreteLogLikelihood returns the log-likelihood of the count histogram under the unnormalized model logf
+func discreteLogLikelihood(logf func(float64) float64, histogram map[uint64]uint64, keyCount int, upper float64) float64 {
+	logZ := logPartialSum(logf, upper)
+	if math.IsNaN(logZ) || math.IsInf(logZ, 0) {
+		return math.Inf(-1)
+	}
+	logLH := -float64(keyCount) * logZ
+	for count, keys := range histogram {
+		logLH += float64(keys) * logf(float64(count))
+	}
+	return logLH
+}
+
+// discreteKS returns the KS statistic between the empirical and the model CDF of the counts
+func discreteKS(logf func(float64) float64, histogram map[uint64]uint64, keyCount int, upper float64) float64 {
+	logZ := logPartialSum(logf, upper)
+	values := make([]uint64, 0, len(histogram))
+	for count := range histogram {
+		values = append(values, count)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] < values[j]
+	})
+
+	ks := 0.0
+	var accumulated uint64
+	for _, value := range values {
+		accumulated += histogram[value]
+		empirical := float64(accumulated) / float64(keyCount)
+		var logPartial float64
+		if value <= exactDiscreteLimit {
+			terms := make([]float64, 0, value)
+			for x := uint64(1); x <= value; x++ {
+				terms = append(terms, logf(float64(x)))
+			}
+			logPartial = logSumExp(terms)
+		} else {
+			logPartial = logPartialSum(logf, float64(value))
+		}
+		model := math.Exp(logPartial - logZ)
+		ks = math.Max(ks, math.Abs(empirical-model))
+	}
+	return ks
+}
+
+// nelderMead minimizes a 2-dimensional function starting from the given point
+func nelderMead(f func([]float64) float64, start []float64, step float64, iterations int) []float64 {
+	simplex := [][]float64{
+		{start[0], start[1]},
+		{start[0] + step, start[1]},
+		{start[0], start[1] + step},
+	}
+	values := make([]float64, 3)
+	for i := range simplex {
+		values[i] = f(simplex[i])
+	}
+	for iter := 0; iter < iterations; iter++ {
+		// Order the vertices: best, middle, worst
+		order := []int{0, 1, 2}
+		sort.Slice(order, func(i, j int) bool {
+			return values[order[i]] < values[order[j]]
+		})
+		best, middle, worst := order[0], order[1], order[2]
+		if math.Abs(values[worst]-values[best]) < 1e-10 {
+			break
+		}
+		centroid := []float64{
+			(simplex[best][0] + simplex[middle][0]) / 2,
+			(simplex[best][1] + simplex[middle][1]) / 2,
+		}
+		point := func(coef float64) []float64 {
+			return []float64{
+				centroid[0] + coef*(simplex[worst][0]-centroid[0]),
+				centroid[1] + coef*(simplex[worst][1]-centroid[1]),
+			}
+		}
+		reflected := point(-1)
+		reflectedValue := f(reflected)
+		switch {
+		case reflectedValue < values[best]:
+			expanded := point(-2)
+			if expandedValue := f(expanded); expandedValue < reflectedValue {
+				simplex[worst], values[worst] = expanded, expandedValue
+			} else {
+				simplex[worst], values[worst] = reflected, reflectedValue
+			}
+		case reflectedValue < values[middle]:
+			simplex[worst], values[worst] = reflected, reflectedValue
+		default:
+			contracted := point(0.5)
+			if contractedValue := f(contracted); contractedValue < values[worst] {
+				simplex[worst], values[worst] = contracted, contractedValue
+			} else {
+				// Shrink towards the best vertex
+				for _, i := range []int{middle, worst} {
+					simplex[i] = []float64{
+						simplex[best][0] + 0.5*(simplex[i][0]-simplex[best][0]),
+						simplex[best][1] + 0.5*(simplex[i][1]-simplex[best][1]),
+					}
+					values[i] = f(simplex[i])
+				}
+			}
+		}
+	}
+	bestIndex := 0
+	for i := range values {
+		if values[i] < values[bestIndex] {
+			bestIndex = i
+		}
+	}
+	return simplex[bestIndex]
+}
+
+// logHarmonic returns log(sum of r^(-s) for r in [1, n]), the tail beyond exactDiscreteLimit uses the closed-form integral
+func logHarmonic(n int, s float64) float64 {
+	sum := 0.0
+	for r := 1; r <= n && r <= exactDiscreteLimit; r++ {
+		sum += math.Pow(float64(r), -s)
+	}
+	if n > exactDiscreteLimit {
+		a, b := float64(exactDiscreteLimit)+0.5, float64(n)+0.5
+		if math.Abs(s-1) < 1e-9 {
+			sum += math.Log(b / a)
+		} else {
+			sum += (math.Pow(b, 1-s) - math.Pow(a, 1-s)) / (1 - s)
+		}
+	}
+	return math.Log(sum)
+}
+
+// fitZipf fits p(rank) ~ rank^(-s) to the counts (sorted in descending order) by maximum likelihood
+func fitZipf(counts []uint64, accessCount uint64) (float64, float64, float64) {
+	// The log-likelihood is -s * sum(c_r * ln(r)) - C * ln(H(N, s))
+	weightedLogRank := 0.0
+	for i, count := range counts {
+		weightedLogRank += float64(count) * math.Log(float64(i+1))
+	}
+	logLH := func(s float64) float64 {
+		return -s*weightedLogRank - float64(accessCount)*logHarmonic(len(counts), s)
+	}
+
+	// Golden-section search for the maximum
+	lower, upper := 0.01, 4.0
+	ratio := (math.Sqrt(5) - 1) / 2
+	x1 := upper - ratio*(upper-lower)
+	x2 := lower + ratio*(upper-lower)
+	f1, f2 := logLH(x1), logLH(x2)
+	for upper-lower > 1e-6 {
+		if f1 > f2 {
+			upper, x2, f2 = x2, x1, f1
+			x1 = upper - ratio*(upper-lower)
+			f1 = logLH(x1)
+		} else {
+			lower, x1, f1 = x1, x2, f2
+			x2 = lower + ratio*(upper-lower)
+			f2 = logLH(x2)
+		}
+	}
+	s := (lower + upper) / 2
+
+	// KS statistic over the ranks, weighted by the accesses
+	total := math.Exp(logHarmonic(len(counts), s))
+	ks := 0.0
+	var accumulated uint64
+	partial := 0.0
+	for i, count := range counts {
+		rank := i + 1
+		accumulated += count
+		// The partial harmonic sum is accumulated as in logHarmonic, beyond exactDiscreteLimit by the integral over
+		// [rank - 0.5, rank + 0.5]
+		if rank <= exactDiscreteLimit {
+			partial += math.Pow(float64(rank), -s)
+		} else if a, b := float64(rank)-0.5, float64(rank)+0.5; math.Abs(s-1) < 1e-9 {
+			partial += math.Log(b / a)
+		} else {
+			partial += (math.Pow(b, 1-s) - math.Pow(a, 1-s)) / (1 - s)
+		}
+		empirical := float64(accumulated) / float64(accessCount)
+		model := partial / total
+		ks = math.Max(ks, math.Abs(empirical-model))
+	}
+	return s, ks, logLH(s)
+}
+
+func fitDistributionFile(fileName string) (*FitResult, error) {
+	counts, err := readCounts(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) < 2 {
+		return nil, fmt.Errorf("not enough keys in %s", fileName)
+	}
+	category, opType := parseFileName(fileName)
+	result := &FitResult{
+		FileName:       fileName,
+		Category:       category,
+		OpType:         opType,
+		KeyCount:       len(counts),
+		CountHistogram: make(map[uint64]uint64),
+	}
+	sumLog, sumLogSquare := 0.0, 0.0
+	for _, count := range counts {
+		result.AccessCount += count
+		result.CountHistogram[count]++
+		sumLog += math.Log(float64(count))
+		sumLogSquare += math.Log(float64(count)) * math.Log(float64(count))
+	}
+
+	// Share of accesses going to the top keys
+	for _, fraction := range topKeyFractions {
+		topKeys := int(math.Ceil(fraction * float64(len(counts))))
+		var topAccesses uint64
+		for _, count := range counts[:topKeys] {
+			topAccesses += count
+		}
+		result.TopShare = append(result.TopShare, float64(topAccesses)/float64(result.AccessCount))
+	}
+
+	fmt.Printf("Fitting Zipf for %s\n", fileName)
+	result.ZipfS, result.ZipfKS, result.ZipfLogLH = fitZipf(counts, result.AccessCount)
+
+	maxCount := float64(counts[0])
+
+	// Power law with cutoff, parameterized by (alpha, log(lambda)) to keep lambda positive
+	fmt.Printf("Fitting power law with cutoff for %s\n", fileName)
+	powerLawLogf := func(params []float64) func(float64) float64 {
+		alpha, lambda := params[0], math.Exp(params[1])
+		return func(x float64) float64 {
+			return -alpha*math.Log(x) - lambda*x
+		}
+	}
+	powerLawUpper := func(params []float64) float64 {
+		return math.Max(maxCount, 50/math.Exp(params[1])) * 2
+	}
+	plParams := nelderMead(func(params []float64) float64 {
+		return -discreteLogLikelihood(powerLawLogf(params), result.CountHistogram, result.KeyCount, powerLawUpper(params))
+	}, []float64{1.5, math.Log(1 / maxCount)}, 0.5, 300)
+	result.PLAlpha, result.PLLambda = plParams[0], math.Exp(plParams[1])
+	result.PLLogLH = discreteLogLikelihood(powerLawLogf(plParams), result.CountHistogram, result.KeyCount, powerLawUpper(plParams))
+	result.PLKS = discreteKS(powerLawLogf(plParams), result.CountHistogram, result.KeyCount, powerLawUpper(plParams))
+
+	// Lognormal, parameterized by (mu, log(sigma)) to keep sigma positive, starting from the moments of log(x)
+	fmt.Printf("Fitting lognormal for %s\n", fileName)
+	lognormalLogf := func(params []float64) func(float64) float64 {
+		mu, sigma := params[0], math.Exp(params[1])
+		return func(x float64) float64 {
+			diff := math.Log(x) - mu
+			return -diff*diff/(2*sigma*sigma) - math.Log(x)
+		}
+	}
+	lognormalUpper := func(params []float64) float64 {
+		return math.Max(maxCount, math.Exp(params[0]+10*math.Exp(params[1]))) * 2
+	}
+	meanLog := sumLog / float64(len(counts))
+	stdLog := math.Sqrt(math.Max(sumLogSquare/float64(len(counts))-meanLog*meanLog, 1e-6))
+	lnParams := nelderMead(func(params []float64) float64 {
+		return -discreteLogLikelihood(lognormalLogf(params), result.CountHistogram, result.KeyCount, lognormalUpper(params))
+	}, []float64{meanLog, math.Log(stdLog)}, 0.5, 300)
+	result.LNMu, result.LNSigma = lnParams[0], math.Exp(lnParams[1])
+	result.LNLogLH = discreteLogLikelihood(lognormalLogf(lnParams), result.CountHistogram, result.KeyCount, lognormalUpper(lnParams))
+	result.LNKS = discreteKS(lognormalLogf(lnParams), result.CountHistogram, result.KeyCount, lognormalUpper(lnParams))
+
+	return result, nil
+}
+
+func printFitResults(outputFile *os.File, results []*FitResult) {
+	fmt.Fprintf(outputFile, "Category\tOPType\tKeys\tAccesses\tZipfS\tZipfKS\tZipfLogLH\tPLAlpha\tPLLambda\tPLKS\tPLLogLH\tLNMu\tLNSigma\tLNKS\tLNLogLH")
+	for _, fraction := range topKeyFractions {
+		fmt.Fprintf(outputFile, "\tTop%g%%", fraction*100)
+	}
+	fmt.Fprintln(outputFile)
+	for _, r := range results {
+		fmt.Fprintf(outputFile, "%s\t%s\t%d\t%d\t%.6f\t%.6f\t%.2f\t%.6f\t%.6e\t%.6f\t%.2f\t%.6f\t%.6f\t%.6f\t%.2f",
+			r.Category, r.OpType, r.KeyCount, r.AccessCount,
+			r.ZipfS, r.ZipfKS, r.ZipfLogLH,
+			r.PLAlpha, r.PLLambda, r.PLKS, r.PLLogLH,
+			r.LNMu, r.LNSigma, r.LNKS, r.LNLogLH)
+		for _, share := range r.TopShare {
+			fmt.Fprintf(outputFile, "\t%.6f", share)
+		}
+		fmt.Fprintln(outputFile)
+	}
+}
+
+// fitRecords converts the fitting results to records
+func fitRecords(results []*FitResult) []FitRecord {
+	records := make([]FitRecord, 0, len(results))
+	for _, r := range results {
+		records = append(records, FitRecord{
+			Category:  r.Category,
+			OpType:    r.OpType,
+			Keys:      r.KeyCount,
+			Accesses:  r.AccessCount,
+			ZipfS:     r.ZipfS,
+			ZipfKS:    r.ZipfKS,
+			ZipfLogLH: r.ZipfLogLH,
+			PLAlpha:   r.PLAlpha,
+			PLLambda:  r.PLLambda,
+			PLKS:      r.PLKS,
+			PLLogLH:   r.PLLogLH,
+			LNMu:      r.LNMu,
+			LNSigma:   r.LNSigma,
+			LNKS:      r.LNKS,
+			LNLogLH:   r.LNLogLH,
+			TopShare:  r.TopShare,
+		})
+	}
+	return records
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 1 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <distribution_files_list_path>")
+		return
+	}
+	logFilePath := flag.Arg(0)
+	fmt.Println("Processing distribution file list:", logFilePath)
+	inputFileList, err := os.Open(logFilePath)
+	if err != nil {
+		fmt.Println("Error opening file list:", logFilePath)
+		return
+	}
+	defer inputFileList.Close()
+
+	var results []*FitResult
+	scanner := bufio.NewScanner(inputFileList)
+	for scanner.Scan() {
+		fileName := strings.TrimSpace(scanner.Text())
+		if fileName == "" {
+			continue
+		}
+		result, err := fitDistributionFile(fileName)
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Category != results[j].Category {
+			return results[i].Category < results[j].Category
+		}
+		return results[i].OpType < results[j].OpType
+	})
+
+	const outputFilePath = "distribution-fit.txt"
+	if *format != "text" {
+		if err := writeRecords(outputPath(outputFilePath, *format), *format, fitRecords(results)); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outputFilePath)
+		return
+	}
+	defer outputFile.Close()
+	printFitResults(outputFile, results)
+	fmt.Printf("Fitting results are stored to: %s\n", outputFilePath)
+}
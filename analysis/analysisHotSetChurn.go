@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Window is the top-K key set of one batch output of countOpDistribution
+type Window struct {
+	StartBlock uint64
+	EndBlock   uint64
+	FilePath   string
+	Ranks      map[string]int // key -> rank (1-based) in the top-K set
+}
+
+// WindowChurn stores the churn metrics of a window compared with the previous and the first windows
+type WindowChurn struct {
+	JaccardPrev   float64
+	JaccardFirst  float64
+	SpearmanPrev  float64
+	KendallPrev   float64
+	SpearmanFirst float64
+	KendallFirst  float64
+	Entered       int // Number of keys entering the top-K set in this window
+	Left          int // Number of keys leaving the top-K set in this window
+}
+
+// ChurnSummary stores the churn summary of a category and operation type
+type ChurnSummary struct {
+	Category        string
+	OpType          string
+	WindowCount     int
+	WindowSize      uint64 // Number of blocks per window (of the first window)
+	MeanJaccardPrev float64
+	MeanSpearman    float64
+	MeanKendall     float64
+	MeanResidence   float64 // Mean number of consecutive windows a key stays in the top-K set
+	MeanReentry     float64 // Mean number of windows between a key leaving and re-entering the top-K set
+	ResidenceCount  int
+	ReentryCount    int
+}
+
+// WindowChurnRecord is a record of hotSetChurn-top<k>_<category>_<op>.{json,csv}
+type WindowChurnRecord struct {
+	WindowStart   uint64  `json:"window_start"`
+	WindowEnd     uint64  `json:"window_end"`
+	JaccardPrev   float64 `json:"jaccard_prev"`
+	JaccardFirst  float64 `json:"jaccard_first"`
+	SpearmanPrev  float64 `json:"spearman_prev"`
+	KendallPrev   float64 `json:"kendall_prev"`
+	SpearmanFirst float64 `json:"spearman_first"`
+	KendallFirst  float64 `json:"kendall_first"`
+	Entered       int     `json:"entered"`
+	Left          int     `json:"left"`
+}
+
+// ChurnSummaryRecord is a record of hotSetChurn-top<k>-summary.{json,csv}
+type ChurnSummaryRecord struct {
+	Category             string  `json:"category"`
+	OpType               string  `json:"op_type"`
+	Windows              int     `json:"windows"`
+	WindowSize           uint64  `json:"window_size"`
+	MeanJaccardPrev      float64 `json:"mean_jaccard_prev"`
+	MeanSpearmanPrev     float64 `json:"mean_spearman_prev"`
+	MeanKendallPrev      float64 `json:"mean_kendall_prev"`
+	MeanResidenceWindows float64 `json:"mean_residence_windows"`
+	MeanResidenceBlocks  float64 `json:"mean_residence_blocks"`
+	MeanReentryWindows   float64 `json:"mean_reentry_windows"`
+	MeanReentryBlocks    float64 `json:"mean_reentry_blocks"`
+}
+
+var distributionFileRegex = regexp.MustCompile(`^distribution-(\d+)_(\d+)_([A-Za-z0-9]+)_([a-z]+)_dis\.(txt|json|csv)$`)
+
+// readTopK reads the first topK keys of a distribution file, which is already sorted by count in descending order
+func readTopK(filePath string, topK int) (map[string]int, error) {
+	if isStructured(filePath) {
+		records, err := readFirstRecords(filePath, topK)
+		if err != nil {
+			return nil, err
+		}
+		ranks := make(map[string]int, topK)
+		for _, record := range records {
+			ranks[record["key"]] = len(ranks) + 1
+		}
+		return ranks, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int, topK)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() && len(ranks) < topK {
+		parts := strings.Split(scanner.Text(), "\t")
+		if len(parts) != 3 {
+			continue
+		}
+		if _, err := strconv.Atoi(parts[0]); err != nil {
+			// Skip the header line
+			continue
+		}
+		ranks[parts[1]] = len(ranks) + 1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file %s: %v", filePath, err)
+	}
+	return ranks, nil
+}
+
+// windowExists returns whether a window starting at startBlock is already in the list
+func windowExists(windows []*Window, startBlock uint64) bool {
+	for _, window := range windows {
+		if window.StartBlock == startBlock {
+			return true
+		}
+	}
+	return false
+}
+
+func jaccard(a, b map[string]int) float64 {
+	intersection := 0
+	for key := range a {
+		if _, exists := b[key]; exists {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 1
+	}
+	return float64(intersection) / float64(union)
+}
+
+// rankVectors returns the ranks of the union of keys in both windows, keys missing from a window share the rank K+1
+func rankVectors(a, b map[string]int, topK int) ([]float64, []float64) {
+	union := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		union[key] = struct{}{}
+	}
+	for key := range b {
+		union[key] = struct{}{}
+	}
+	keys := make([]string, 0, len(union))
+	for key := range union {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	x := make([]float64, 0, len(keys))
+	y := make([]float64, 0, len(keys))
+	for _, key := range keys {
+		rankA, existsA := a[key]
+		if !existsA {
+			rankA = topK + 1
+		}
+		rankB, existsB := b[key]
+		if !existsB {
+			rankB = topK + 1
+		}
+		x = append(x, float64(rankA))
+		y = append(y, float64(rankB))
+	}
+	return x, y
+}
+
+// averageRanks converts the values to ranks, tied values get the average of their ranks
+func averageRanks(values []float64) []float64 {
+	indices := make([]int, len(values))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return values[indices[i]] < values[indices[j]]
+	})
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && values[indices[j+1]] == values[indices[i]] {
+			j++
+		}
+		averageRank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[indices[k]] = averageRank
+		}
+		i = j + 1
+	}
+	return ranks
+}
+
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if n < 2 {
+		return math.NaN()
+	}
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// spearman returns the Spearman rank correlation (Pearson correlation of the tie-averaged ranks)
+func spearman(x, y []float64) float64 {
+	return pearson(averageRanks(x), averageRanks(y))
+}
+
+// kendall returns the Kendall tau-b rank correlation, which accounts for ties. The discordant pairs are counted by
+// merge sort (Knight's algorithm), so it takes O(n log n) instead of comparing all pairs
+func kendall(x, y []float64) float64 {
+	n := len(x)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if x[order[i]] != x[order[j]] {
+			return x[order[i]] < x[order[j]]
+		}
+		return y[order[i]] < y[order[j]]
+	})
+	ys := make([]float64, n)
+	for i, index := range order {
+		ys[i] = y[index]
+	}
+
+	// Pairs tied in x, and tied in both x and y
+	var tiesX, tiesXY float64
+	for i := 0; i < n; {
+		j, k := i, i
+		for j < n && x[order[j]] == x[order[i]] {
+			if ys[j] != ys[k] {
+				tiesXY += tiedPairs(j - k)
+				k = j
+			}
+			j++
+		}
+		tiesXY += tiedPairs(j - k)
+		tiesX += tiedPairs(j - i)
+		i = j
+	}
+	swaps := float64(mergeSortSwaps(ys, make([]float64, n)))
+	// Pairs tied in y, ys is sorted now
+	var tiesY float64
+	for i := 0; i < n; {
+		j := i
+		for j < n && ys[j] == ys[i] {
+			j++
+		}
+		tiesY += tiedPairs(j - i)
+		i = j
+	}
+
+	pairs := tiedPairs(n)
+	denominator := math.Sqrt((pairs - tiesX) * (pairs - tiesY))
+	if denominator == 0 {
+		return math.NaN()
+	}
+	return (pairs - tiesX - tiesY + tiesXY - 2*swaps) / denominator
+}
+
+// tiedPairs returns the number of pairs among count values
+func tiedPairs(count int) float64 {
+	return float64(count) * float64(count-1) / 2
+}
+
+// mergeSortSwaps sorts values in ascending order and returns the number of pairs in strictly descending order
+func mergeSortSwaps(values, buffer []float64) int64 {
+	if len(values) < 2 {
+		return 0
+	}
+	middle := len(values) / 2
+	swaps := mergeSortSwaps(values[:middle], buffer[:middle]) + mergeSortSwaps(values[middle:], buffer[middle:])
+	i, j, k := 0, middle, 0
+	for i < middle && j < len(values) {
+		if values[i] <= values[j] {
+			buffer[k] = values[i]
+			i++
+		} else {
+			buffer[k] = values[j]
+			swaps += int64(middle - i)
+			j++
+		}
+		k++
+	}
+	k += copy(buffer[k:], values[i:middle])
+	copy(buffer[k:], values[j:])
+	copy(values, buffer)
+	return swaps
+}
+
+func analyzeWindows(category, opType string, windows []*Window, topK int) (*ChurnSummary, []WindowChurnRecord) {
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].StartBlock < windows[j].StartBlock
+	})
+	summary := &ChurnSummary{
+		Category:    category,
+		OpType:      opType,
+		WindowCount: len(windows),
+		WindowSize:  windows[0].EndBlock - windows[0].StartBlock,
+	}
+
+	var records []WindowChurnRecord
+	var sumJaccard, sumSpearman, sumKendall float64
+	var spearmanCount, kendallCount int
+	for i, window := range windows {
+		churn := WindowChurn{JaccardPrev: 1, SpearmanPrev: 1, KendallPrev: 1, JaccardFirst: 1, SpearmanFirst: 1, KendallFirst: 1}
+		if i > 0 {
+			prev := windows[i-1]
+			churn.JaccardPrev = jaccard(prev.Ranks, window.Ranks)
+			x, y := rankVectors(prev.Ranks, window.Ranks, topK)
+			churn.SpearmanPrev = spearman(x, y)
+			churn.KendallPrev = kendall(x, y)
+
+			first := windows[0]
+			churn.JaccardFirst = jaccard(first.Ranks, window.Ranks)
+			x, y = rankVectors(first.Ranks, window.Ranks, topK)
+			churn.SpearmanFirst = spearman(x, y)
+			churn.KendallFirst = kendall(x, y)
+
+			for key := range window.Ranks {
+				if _, exists := prev.Ranks[key]; !exists {
+					churn.Entered++
+				}
+			}
+			for key := range prev.Ranks {
+				if _, exists := window.Ranks[key]; !exists {
+					churn.Left++
+				}
+			}
+
+			sumJaccard += churn.JaccardPrev
+			if !math.IsNaN(churn.SpearmanPrev) {
+				sumSpearman += churn.SpearmanPrev
+				spearmanCount++
+			}
+			if !math.IsNaN(churn.KendallPrev) {
+				sumKendall += churn.KendallPrev
+				kendallCount++
+			}
+		} else {
+			churn.Entered = len(window.Ranks)
+		}
+		records = append(records, WindowChurnRecord{
+			WindowStart:   window.StartBlock,
+			WindowEnd:     window.EndBlock,
+			JaccardPrev:   churn.JaccardPrev,
+			JaccardFirst:  churn.JaccardFirst,
+			SpearmanPrev:  churn.SpearmanPrev,
+			KendallPrev:   churn.KendallPrev,
+			SpearmanFirst: churn.SpearmanFirst,
+			KendallFirst:  churn.KendallFirst,
+			Entered:       churn.Entered,
+			Left:          churn.Left,
+		})
+	}
+	if len(windows) > 1 {
+		summary.MeanJaccardPrev = sumJaccard / float64(len(windows)-1)
+	}
+	if spearmanCount > 0 {
+		summary.MeanSpearman = sumSpearman / float64(spearmanCount)
+	}
+	if kendallCount > 0 {
+		summary.MeanKendall = sumKendall / float64(kendallCount)
+	}
+
+	// Residence: runs of consecutive windows in the top-K set; re-entry: gaps between two runs of the same key.
+	// Runs that touch the first or the last window are censored and not counted.
+	lastSeen := make(map[string]int) // key -> index of the last window that contains the key
+	runStart := make(map[string]int) // key -> index of the window where the current run starts
+	var totalResidence, totalReentry int
+	for i, window := range windows {
+		for key := range window.Ranks {
+			last, seen := lastSeen[key]
+			if seen && last == i-1 {
+				lastSeen[key] = i
+				continue
+			}
+			if seen {
+				// The previous run ends at window "last", the key re-enters after a gap
+				if runStart[key] > 0 {
+					totalResidence += last - runStart[key] + 1
+					summary.ResidenceCount++
+				}
+				totalReentry += i - last - 1
+				summary.ReentryCount++
+			}
+			runStart[key] = i
+			lastSeen[key] = i
+		}
+	}
+	for key, last := range lastSeen {
+		if last < len(windows)-1 && runStart[key] > 0 {
+			totalResidence += last - runStart[key] + 1
+			summary.ResidenceCount++
+		}
+	}
+	if summary.ResidenceCount > 0 {
+		summary.MeanResidence = float64(totalResidence) / float64(summary.ResidenceCount)
+	}
+	if summary.ReentryCount > 0 {
+		summary.MeanReentry = float64(totalReentry) / float64(summary.ReentryCount)
+	}
+	return summary, records
+}
+
+func printWindowChurn(outputFile *os.File, records []WindowChurnRecord) {
+	fmt.Fprintln(outputFile, "WindowStart\tWindowEnd\tJaccardPrev\tJaccardFirst\tSpearmanPrev\tKendallPrev\tSpearmanFirst\tKendallFirst\tEntered\tLeft")
+	for _, r := range records {
+		fmt.Fprintf(outputFile, "%d\t%d\t%.6f\t%.6f\t%.6f\t%.6f\t%.6f\t%.6f\t%d\t%d\n",
+			r.WindowStart, r.WindowEnd, r.JaccardPrev, r.JaccardFirst,
+			r.SpearmanPrev, r.KendallPrev, r.SpearmanFirst, r.KendallFirst,
+			r.Entered, r.Left)
+	}
+}
+
+// summaryRecords converts the churn summaries to records
+func summaryRecords(summaries []*ChurnSummary) []ChurnSummaryRecord {
+	records := make([]ChurnSummaryRecord, 0, len(summaries))
+	for _, s := range summaries {
+		records = append(records, ChurnSummaryRecord{
+			Category:             s.Category,
+			OpType:               s.OpType,
+			Windows:              s.WindowCount,
+			WindowSize:           s.WindowSize,
+			MeanJaccardPrev:      s.MeanJaccardPrev,
+			MeanSpearmanPrev:     s.MeanSpearman,
+			MeanKendallPrev:      s.MeanKendall,
+			MeanResidenceWindows: s.MeanResidence,
+			MeanResidenceBlocks:  s.MeanResidence * float64(s.WindowSize),
+			MeanReentryWindows:   s.MeanReentry,
+			MeanReentryBlocks:    s.MeanReentry * float64(s.WindowSize),
+		})
+	}
+	return records
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 2 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <path_to_distribution_results_dir> <top_k>")
+		return
+	}
+	resultsDir := flag.Arg(0)
+	topK, err := strconv.Atoi(flag.Arg(1))
+	if err != nil || topK <= 0 {
+		fmt.Println("Invalid top_k:", flag.Arg(1))
+		return
+	}
+
+	entries, err := os.ReadDir(resultsDir)
+	if err != nil {
+		fmt.Println("Error reading results dir:", err)
+		return
+	}
+
+	// category -> opType -> windows
+	windowMap := make(map[string]map[string][]*Window)
+	for _, entry := range entries {
+		matches := distributionFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		startBlock, _ := strconv.ParseUint(matches[1], 10, 64)
+		endBlock, _ := strconv.ParseUint(matches[2], 10, 64)
+		category, opType := matches[3], matches[4]
+		if _, exists := windowMap[category]; !exists {
+			windowMap[category] = make(map[string][]*Window)
+		}
+		if windowExists(windowMap[category][opType], startBlock) {
+			// The same window is written in several formats
+			continue
+		}
+		windowMap[category][opType] = append(windowMap[category][opType], &Window{
+			StartBlock: startBlock,
+			EndBlock:   endBlock,
+			FilePath:   filepath.Join(resultsDir, entry.Name()),
+		})
+	}
+
+	categories := make([]string, 0, len(windowMap))
+	for category := range windowMap {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var summaries []*ChurnSummary
+	for _, category := range categories {
+		opTypes := make([]string, 0, len(windowMap[category]))
+		for opType := range windowMap[category] {
+			opTypes = append(opTypes, opType)
+		}
+		sort.Strings(opTypes)
+		for _, opType := range opTypes {
+			windows := windowMap[category][opType]
+			fmt.Printf("Processing %s, %s (%d windows)\n", category, opType, len(windows))
+			for _, window := range windows {
+				window.Ranks, err = readTopK(window.FilePath, topK)
+				if err != nil {
+					fmt.Println("Error:", err)
+					window.Ranks = make(map[string]int)
+				}
+			}
+			summary, records := analyzeWindows(category, opType, windows, topK)
+			summaries = append(summaries, summary)
+			outputFilePath := fmt.Sprintf("hotSetChurn-top%d_%s_%s.txt", topK, category, opType)
+			if *format != "text" {
+				if err := writeRecords(outputPath(outputFilePath, *format), *format, records); err != nil {
+					fmt.Println("Error:", err)
+				}
+				continue
+			}
+			outputFile, err := os.Create(outputFilePath)
+			if err != nil {
+				fmt.Println("Error creating output file:", outputFilePath)
+				continue
+			}
+			printWindowChurn(outputFile, records)
+			outputFile.Close()
+		}
+	}
+
+	summaryFilePath := fmt.Sprintf("hotSetChurn-top%d-summary.txt", topK)
+	if *format != "text" {
+		if err := writeRecords(outputPath(summaryFilePath, *format), *format, summaryRecords(summaries)); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	summaryFile, err := os.Create(summaryFilePath)
+	if err != nil {
+		fmt.Println("Error creating output file:", summaryFilePath)
+		return
+	}
+	defer summaryFile.Close()
+	fmt.Fprintln(summaryFile, "Category\tOPType\tWindows\tWindowSize\tMeanJaccardPrev\tMeanSpearmanPrev\tMeanKendallPrev\tMeanResidenceWindows\tMeanResidenceBlocks\tMeanReentryWindows\tMeanReentryBlocks")
+	for _, s := range summaries {
+		fmt.Fprintf(summaryFile, "%s\t%s\t%d\t%d\t%.6f\t%.6f\t%.6f\t%.4f\t%.2f\t%.4f\t%.2f\n",
+			s.Category, s.OpType, s.WindowCount, s.WindowSize, s.MeanJaccardPrev, s.MeanSpearman, s.MeanKendall,
+			s.MeanResidence, s.MeanResidence*float64(s.WindowSize), s.MeanReentry, s.MeanReentry*float64(s.WindowSize))
+	}
+	fmt.Printf("Summary is stored to: %s\n", summaryFilePath)
+}
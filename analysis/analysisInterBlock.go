@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// BlockAccess stores the keys accessed in a block, as interned key ID -> KV size (see readBlocks)
+type BlockAccess struct {
+	BlockID uint64
+	Keys    map[uint32]uint32
+}
+
+// KeyTable interns the keys to integer IDs, so that the blocks in the history only store IDs
+type KeyTable struct {
+	ids        map[string]uint32
+	categories []string
+	keys       []string
+	blockFreq  []uint32 // Number of blocks in which the key is accessed
+}
+
+// OverlapStats accumulates how many keys of a later block were already accessed in the earlier block(s)
+type OverlapStats struct {
+	PrevKeys     uint64 // Keys of the earlier block (per lag), or of the union of the earlier blocks (per window)
+	NextKeys     uint64
+	NextBytes    uint64 // KV sizes of the keys of the later block
+	OverlapKeys  uint64
+	OverlapBytes uint64 // KV sizes of the overlapping keys
+}
+
+// SuccessorRule records that Successor is accessed Lag blocks after Key
+type SuccessorRule struct {
+	Key        uint32
+	Successor  uint32
+	Lag        int
+	Support    uint32
+	Confidence float64
+}
+
+// OverlapRecord is a record of interBlock-<op>-<start>_<end>.{json,csv}, the mode is lag or window, and the averages
+// are per block pair (lag) or per block (window)
+type OverlapRecord struct {
+	Mode       string  `json:"mode"`
+	Value      int     `json:"value"`
+	Category   string  `json:"category"`
+	Count      uint64  `json:"count"`
+	AvgPrev    float64 `json:"avg_prev_keys"`
+	AvgNext    float64 `json:"avg_next_keys"`
+	AvgOverlap float64 `json:"avg_overlap"`
+	Precision  float64 `json:"precision"`
+	Recall     float64 `json:"recall"`
+	ByteRecall float64 `json:"byte_recall"`
+}
+
+// RuleRecord is a record of interBlockRules-<op>-<start>_<end>.{json,csv}
+type RuleRecord struct {
+	Lag               int     `json:"lag"`
+	Key               string  `json:"key"`
+	Category          string  `json:"category"`
+	Successor         string  `json:"successor"`
+	SuccessorCategory string  `json:"successor_category"`
+	Support           uint32  `json:"support"`
+	Confidence        float64 `json:"confidence"`
+}
+
+const allCategory = "All"
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: (\d+)(?:, value: [a-fA-F0-9]*, size: (\d+))?`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func NewKeyTable() *KeyTable {
+	return &KeyTable{ids: make(map[string]uint32)}
+}
+
+func (kt *KeyTable) intern(key string) uint32 {
+	if id, exists := kt.ids[key]; exists {
+		return id
+	}
+	id := uint32(len(kt.keys))
+	kt.ids[key] = id
+	kt.keys = append(kt.keys, key)
+	kt.categories = append(kt.categories, matchPrefix(key))
+	kt.blockFreq = append(kt.blockFreq, 0)
+	return id
+}
+
+// readBlocks scans the log file and calls handleBlock with the keys of opType accessed in each block of the range. The
+// first size field of a read is the key size, and the trace only logs the value size of writes, so the KV size of an
+// access is the key size plus the last written value size of the key, or the average written value size of its
+// category if never written before the access
+func readBlocks(filePath, opType string, progressInterval, startBlockNumber, endBlockNumber uint64, keyTable *KeyTable, handleBlock func(*BlockAccess)) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	valueSizes := make(map[string]uint32)
+	categoryValueBytes := make(map[string]uint64)
+	categoryValueCount := make(map[string]uint64)
+	kvSize := func(key string) uint32 {
+		size := uint32(len(key) / 2)
+		if valueSize, exists := valueSizes[key]; exists {
+			return size + valueSize
+		}
+		category := matchPrefix(key)
+		if categoryValueCount[category] > 0 {
+			size += uint32(categoryValueBytes[category] / categoryValueCount[category])
+		}
+		return size
+	}
+
+	reader := bufio.NewReader(file)
+	var current *BlockAccess
+	var lineCount uint64
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 && current != nil {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, interned keys: %d, elapsed time: %.2fs", lineCount, current.BlockID, len(keyTable.keys), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if current != nil {
+				handleBlock(current)
+				current = nil
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				current = &BlockAccess{BlockID: id, Keys: make(map[uint32]uint32)}
+			}
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		key := strings.ToLower(matches[2])
+		// Track the value sizes of all writes, including the ones before the range
+		if matches[4] != "" {
+			valueSize, _ := strconv.ParseUint(matches[4], 10, 32)
+			category := matchPrefix(key)
+			valueSizes[key] = uint32(valueSize)
+			categoryValueBytes[category] += valueSize
+			categoryValueCount[category]++
+		}
+		if current == nil || matches[1] != opType {
+			continue
+		}
+		current.Keys[keyTable.intern(key)] = kvSize(key)
+	}
+	if current != nil {
+		handleBlock(current)
+	}
+}
+
+func addOverlap(stats map[string]*OverlapStats, category string, prevKeys, nextKeys, nextBytes, overlapKeys, overlapBytes uint64) {
+	for _, name := range []string{category, allCategory} {
+		s, exists := stats[name]
+		if !exists {
+			s = &OverlapStats{}
+			stats[name] = s
+		}
+		s.PrevKeys += prevKeys
+		s.NextKeys += nextKeys
+		s.NextBytes += nextBytes
+		s.OverlapKeys += overlapKeys
+		s.OverlapBytes += overlapBytes
+	}
+}
+
+// trimHistory drops the blocks that are more than maxLag blocks before blockID
+func trimHistory(history []*BlockAccess, blockID uint64, maxLag int) []*BlockAccess {
+	for len(history) > 0 && blockID-history[0].BlockID > uint64(maxLag) {
+		history = history[1:]
+	}
+	return history
+}
+
+// measureOverlap compares each block with the previous maxLag blocks. Per lag, the keys of block N+lag are
+// compared with the keys of block N. Per window, the keys of block N+1 are compared with the union of the keys
+// of blocks N+1-window..N, which is what a prefetcher that keeps the keys of the recent blocks would cover.
+func measureOverlap(filePath, opType string, progressInterval, startBlockNumber, endBlockNumber uint64, maxLag int, keyTable *KeyTable) ([]map[string]*OverlapStats, []uint64, []map[string]*OverlapStats, []uint64) {
+	lagStats := make([]map[string]*OverlapStats, maxLag+1)
+	windowStats := make([]map[string]*OverlapStats, maxLag+1)
+	for i := 1; i <= maxLag; i++ {
+		lagStats[i] = make(map[string]*OverlapStats)
+		windowStats[i] = make(map[string]*OverlapStats)
+	}
+	lagPairs := make([]uint64, maxLag+1)
+	windowBlocks := make([]uint64, maxLag+1)
+	var history []*BlockAccess
+
+	readBlocks(filePath, opType, progressInterval, startBlockNumber, endBlockNumber, keyTable, func(block *BlockAccess) {
+		nextKeys := make(map[string]uint64)
+		nextBytes := make(map[string]uint64)
+		for id, size := range block.Keys {
+			keyTable.blockFreq[id]++
+			nextKeys[keyTable.categories[id]]++
+			nextBytes[keyTable.categories[id]] += uint64(size)
+		}
+
+		history = trimHistory(history, block.BlockID, maxLag)
+		for _, prev := range history {
+			lag := int(block.BlockID - prev.BlockID)
+			lagPairs[lag]++
+			prevKeys := make(map[string]uint64)
+			overlapKeys := make(map[string]uint64)
+			overlapBytes := make(map[string]uint64)
+			for id := range prev.Keys {
+				category := keyTable.categories[id]
+				prevKeys[category]++
+				if size, exists := block.Keys[id]; exists {
+					overlapKeys[category]++
+					overlapBytes[category] += uint64(size)
+				}
+			}
+			for category := range mergeCategories(prevKeys, nextKeys) {
+				addOverlap(lagStats[lag], category, prevKeys[category], nextKeys[category], nextBytes[category], overlapKeys[category], overlapBytes[category])
+			}
+		}
+
+		// Walk the history from the most recent block, growing the union of the window one lag at a time
+		seen := make(map[uint32]struct{})
+		unionKeys := make(map[string]uint64)
+		overlapKeys := make(map[string]uint64)
+		overlapBytes := make(map[string]uint64)
+		index := len(history) - 1
+		for window := 1; window <= maxLag; window++ {
+			// Only count full windows, the first blocks of the range do not have enough history
+			if block.BlockID-startBlockNumber < uint64(window) {
+				break
+			}
+			for ; index >= 0 && block.BlockID-history[index].BlockID <= uint64(window); index-- {
+				for id := range history[index].Keys {
+					if _, exists := seen[id]; exists {
+						continue
+					}
+					seen[id] = struct{}{}
+					category := keyTable.categories[id]
+					unionKeys[category]++
+					if size, exists := block.Keys[id]; exists {
+						overlapKeys[category]++
+						overlapBytes[category] += uint64(size)
+					}
+				}
+			}
+			windowBlocks[window]++
+			for category := range mergeCategories(unionKeys, nextKeys) {
+				addOverlap(windowStats[window], category, unionKeys[category], nextKeys[category], nextBytes[category], overlapKeys[category], overlapBytes[category])
+			}
+		}
+
+		history = append(history, block)
+	})
+	return lagStats, lagPairs, windowStats, windowBlocks
+}
+
+func mergeCategories(a, b map[string]uint64) map[string]struct{} {
+	categories := make(map[string]struct{})
+	for category := range a {
+		categories[category] = struct{}{}
+	}
+	for category := range b {
+		categories[category] = struct{}{}
+	}
+	return categories
+}
+
+// mineSuccessors counts, for each key accessed in block N, the other keys accessed in blocks N+1..N+maxLag.
+// Only keys accessed in at least minSupport blocks are considered, since rarer keys cannot form a rule with
+// enough support, and this keeps the number of counted pairs manageable.
+func mineSuccessors(filePath, opType string, progressInterval, startBlockNumber, endBlockNumber uint64, maxLag int, minSupport uint32, keyTable *KeyTable) []SuccessorRule {
+	counts := make([]map[uint64]uint32, maxLag+1)
+	for i := 1; i <= maxLag; i++ {
+		counts[i] = make(map[uint64]uint32)
+	}
+	var history []*BlockAccess
+
+	readBlocks(filePath, opType, progressInterval, startBlockNumber, endBlockNumber, keyTable, func(block *BlockAccess) {
+		candidates := &BlockAccess{BlockID: block.BlockID, Keys: make(map[uint32]uint32)}
+		for id, size := range block.Keys {
+			if keyTable.blockFreq[id] >= minSupport {
+				candidates.Keys[id] = size
+			}
+		}
+		history = trimHistory(history, block.BlockID, maxLag)
+		for _, prev := range history {
+			lag := int(block.BlockID - prev.BlockID)
+			for key := range prev.Keys {
+				for successor := range candidates.Keys {
+					if key != successor {
+						counts[lag][uint64(key)<<32|uint64(successor)]++
+					}
+				}
+			}
+		}
+		history = append(history, candidates)
+	})
+
+	var rules []SuccessorRule
+	for lag := 1; lag <= maxLag; lag++ {
+		for packed, support := range counts[lag] {
+			if support < minSupport {
+				continue
+			}
+			key := uint32(packed >> 32)
+			rules = append(rules, SuccessorRule{
+				Key:        key,
+				Successor:  uint32(packed),
+				Lag:        lag,
+				Support:    support,
+				Confidence: float64(support) / float64(keyTable.blockFreq[key]),
+			})
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Support != rules[j].Support {
+			return rules[i].Support > rules[j].Support
+		}
+		if rules[i].Confidence != rules[j].Confidence {
+			return rules[i].Confidence > rules[j].Confidence
+		}
+		if rules[i].Lag != rules[j].Lag {
+			return rules[i].Lag < rules[j].Lag
+		}
+		if rules[i].Key != rules[j].Key {
+			return keyTable.keys[rules[i].Key] < keyTable.keys[rules[j].Key]
+		}
+		return keyTable.keys[rules[i].Successor] < keyTable.keys[rules[j].Successor]
+	})
+	return rules
+}
+
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func sortedCategories(stats map[string]*OverlapStats) []string {
+	categories := make([]string, 0, len(stats))
+	for category := range stats {
+		if category != allCategory {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	if _, exists := stats[allCategory]; exists {
+		categories = append([]string{allCategory}, categories...)
+	}
+	return categories
+}
+
+// printOverlap prints one line per category, with precision = overlap / earlier keys and recall = overlap / later keys
+func printOverlap(outputFile *os.File, value int, stats map[string]*OverlapStats, count uint64) {
+	for _, category := range sortedCategories(stats) {
+		s := stats[category]
+		fmt.Fprintf(outputFile, "%d %s %d %.2f %.2f %.2f %.6f %.6f %.6f\n", value, category, count,
+			ratio(s.PrevKeys, count), ratio(s.NextKeys, count), ratio(s.OverlapKeys, count),
+			ratio(s.OverlapKeys, s.PrevKeys), ratio(s.OverlapKeys, s.NextKeys), ratio(s.OverlapBytes, s.NextBytes))
+	}
+}
+
+// overlapRecords returns one record per category, as printOverlap
+func overlapRecords(mode string, value int, stats map[string]*OverlapStats, count uint64) []OverlapRecord {
+	var records []OverlapRecord
+	for _, category := range sortedCategories(stats) {
+		s := stats[category]
+		records = append(records, OverlapRecord{
+			Mode:       mode,
+			Value:      value,
+			Category:   category,
+			Count:      count,
+			AvgPrev:    ratio(s.PrevKeys, count),
+			AvgNext:    ratio(s.NextKeys, count),
+			AvgOverlap: ratio(s.OverlapKeys, count),
+			Precision:  ratio(s.OverlapKeys, s.PrevKeys),
+			Recall:     ratio(s.OverlapKeys, s.NextKeys),
+			ByteRecall: ratio(s.OverlapBytes, s.NextBytes),
+		})
+	}
+	return records
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 6 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <start_block_number> <end_block_number> <max_lag> <min_support> [op_type] [print_progress_interval]")
+		fmt.Println("E.g.: program /path/to/trace 20500000 20510000 8 100 Get 100000")
+		return
+	}
+	logFilePath := args[1]
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	maxLag, _ := strconv.Atoi(args[4])
+	minSupport, _ := strconv.ParseUint(args[5], 10, 32)
+	opType := "Get"
+	if len(args) > 6 {
+		opType = args[6]
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 7 {
+		progressInterval, _ = strconv.ParseUint(args[7], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+	if maxLag < 1 {
+		maxLag = 1
+	}
+	if minSupport == 1 {
+		// Every pair of keys in consecutive blocks would be a rule
+		minSupport = 2
+	}
+	if endBlockNumber < startBlockNumber {
+		fmt.Println("Invalid block range:", startBlockNumber, endBlockNumber)
+		return
+	}
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+
+	keyTable := NewKeyTable()
+	fmt.Println("Measuring the inter-block overlap of", opType, "operations")
+	lagStats, lagPairs, windowStats, windowBlocks := measureOverlap(logFilePath, opType, progressInterval, startBlockNumber, endBlockNumber, maxLag, keyTable)
+
+	outPutLogPath := "interBlock-" + opType + "-" + rangeName + ".txt"
+	rulesPath := "interBlockRules-" + opType + "-" + rangeName + ".txt"
+	if *format != "text" {
+		var records []OverlapRecord
+		for lag := 1; lag <= maxLag; lag++ {
+			records = append(records, overlapRecords("lag", lag, lagStats[lag], lagPairs[lag])...)
+		}
+		for window := 1; window <= maxLag; window++ {
+			records = append(records, overlapRecords("window", window, windowStats[window], windowBlocks[window])...)
+		}
+		if err := writeRecords(outputPath(outPutLogPath, *format), *format, records); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if minSupport == 0 {
+			return
+		}
+		fmt.Println("Mining the successor rules with min support", minSupport)
+		rules := mineSuccessors(logFilePath, opType, progressInterval, startBlockNumber, endBlockNumber, maxLag, uint32(minSupport), keyTable)
+		ruleRecords := make([]RuleRecord, 0, len(rules))
+		for _, rule := range rules {
+			ruleRecords = append(ruleRecords, RuleRecord{
+				Lag:               rule.Lag,
+				Key:               keyTable.keys[rule.Key],
+				Category:          keyTable.categories[rule.Key],
+				Successor:         keyTable.keys[rule.Successor],
+				SuccessorCategory: keyTable.categories[rule.Successor],
+				Support:           rule.Support,
+				Confidence:        rule.Confidence,
+			})
+		}
+		if err := writeRecords(outputPath(rulesPath, *format), *format, ruleRecords); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "Inter-block overlap of %s operations, blocks %d-%d, max lag %d, distinct keys %d\n", opType, startBlockNumber, endBlockNumber, maxLag, len(keyTable.keys))
+	fmt.Fprintln(file, "Per lag (keys of block N+lag that are also accessed in block N):")
+	fmt.Fprintln(file, "Lag Category BlockPairs AvgKeysN AvgKeysN+lag AvgOverlap Precision Recall ByteRecall")
+	for lag := 1; lag <= maxLag; lag++ {
+		printOverlap(file, lag, lagStats[lag], lagPairs[lag])
+	}
+	fmt.Fprintln(file, "Per window (keys of block N+1 that are also accessed in any of blocks N+1-window..N):")
+	fmt.Fprintln(file, "Window Category Blocks AvgWindowKeys AvgKeysN+1 AvgOverlap Precision Recall ByteRecall")
+	for window := 1; window <= maxLag; window++ {
+		printOverlap(file, window, windowStats[window], windowBlocks[window])
+	}
+
+	if minSupport == 0 {
+		fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+		return
+	}
+	fmt.Println("Mining the successor rules with min support", minSupport)
+	rules := mineSuccessors(logFilePath, opType, progressInterval, startBlockNumber, endBlockNumber, maxLag, uint32(minSupport), keyTable)
+
+	rulesFile, err := os.Create(rulesPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", rulesPath)
+		return
+	}
+	defer rulesFile.Close()
+	writer := bufio.NewWriter(rulesFile)
+	fmt.Fprintln(writer, "Lag Key Category Successor SuccessorCategory Support Confidence")
+	ruleCount := make([]uint64, maxLag+1)
+	confidentCount := make([]uint64, maxLag+1)
+	for _, rule := range rules {
+		fmt.Fprintf(writer, "%d %s %s %s %s %d %.6f\n", rule.Lag, keyTable.keys[rule.Key], keyTable.categories[rule.Key],
+			keyTable.keys[rule.Successor], keyTable.categories[rule.Successor], rule.Support, rule.Confidence)
+		ruleCount[rule.Lag]++
+		if rule.Confidence >= 0.5 {
+			confidentCount[rule.Lag]++
+		}
+	}
+	writer.Flush()
+
+	fmt.Fprintf(file, "Successor rules (key in block N -> other key in block N+lag, min support %d):\n", minSupport)
+	fmt.Fprintln(file, "Lag Rules Rules(Confidence>=0.5)")
+	for lag := 1; lag <= maxLag; lag++ {
+		fmt.Fprintf(file, "%d %d %d\n", lag, ruleCount[lag], confidentCount[lag])
+	}
+	fmt.Printf("Statistics are stored to: %s and %s\n", outPutLogPath, rulesPath)
+}
@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 )
 
 var prefixes = [][]byte{
@@ -175,8 +186,21 @@ func HasPrefix(s, prefix []byte) bool {
 	return len(s) >= len(prefix) && Equal(s[0:len(prefix)], prefix)
 }
 
-func matchesPrefix(key []byte) ([]byte, bool) {
+// prefixIndex groups the prefixes by their first byte in the order of prefixes, so that a key is only checked against
+// the prefixes that can match it
+var prefixIndex = func() [256][][]byte {
+	var index [256][][]byte
 	for _, prefix := range prefixes {
+		index[prefix[0]] = append(index[prefix[0]], prefix)
+	}
+	return index
+}()
+
+func matchesPrefix(key []byte) ([]byte, bool) {
+	if len(key) == 0 {
+		return nil, false
+	}
+	for _, prefix := range prefixIndex[key[0]] {
 		if HasPrefix(key, prefix) {
 			return prefix, true
 		}
@@ -200,6 +224,47 @@ type PrefixStats struct {
 	BucketWidth        int         // Width of each bucket
 }
 
+func newPrefixStats(bucketWidth int) *PrefixStats {
+	return &PrefixStats{
+		SizeHistogramKV:    make(map[int]int),
+		SizeHistogramKey:   make(map[int]int),
+		SizeHistogramValue: make(map[int]int),
+		BucketWidth:        bucketWidth,
+	}
+}
+
+// minSize returns the smaller size, where 0 means no size as in update
+func minSize(a, b int) int {
+	if b < a || a == 0 {
+		return b
+	}
+	return a
+}
+
+// merge adds the statistics of another scanner of the same prefix
+func (ps *PrefixStats) merge(other *PrefixStats) {
+	if other.Count == 0 {
+		return
+	}
+	ps.Count += other.Count
+	ps.TotalSize += other.TotalSize
+	ps.MinSizeKey = minSize(ps.MinSizeKey, other.MinSizeKey)
+	ps.MaxSizeKey = max(ps.MaxSizeKey, other.MaxSizeKey)
+	ps.MinSizeValue = minSize(ps.MinSizeValue, other.MinSizeValue)
+	ps.MaxSizeValue = max(ps.MaxSizeValue, other.MaxSizeValue)
+	ps.MinSizeKV = minSize(ps.MinSizeKV, other.MinSizeKV)
+	ps.MaxSizeKV = max(ps.MaxSizeKV, other.MaxSizeKV)
+	for bucket, count := range other.SizeHistogramKey {
+		ps.SizeHistogramKey[bucket] += count
+	}
+	for bucket, count := range other.SizeHistogramValue {
+		ps.SizeHistogramValue[bucket] += count
+	}
+	for bucket, count := range other.SizeHistogramKV {
+		ps.SizeHistogramKV[bucket] += count
+	}
+}
+
 func (ps *PrefixStats) update(sizeValue int, sizeKey int) {
 	ps.Count++
 	ps.TotalSize += (sizeValue + sizeKey)
@@ -230,6 +295,38 @@ func (ps *PrefixStats) update(sizeValue int, sizeKey int) {
 	ps.SizeHistogramKV[bucket]++
 }
 
+// StorageRecord is a record of pebble-database-KV-count.{json,csv}. With sampling, the KV pairs and the total size are
+// extrapolated, and the other fields are of the sampled KV pairs
+type StorageRecord struct {
+	DataType       string  `json:"data_type"`
+	KVPairs        int     `json:"kv_pairs"`
+	SampledKVPairs int     `json:"sampled_kv_pairs"`
+	TotalSize      int     `json:"total_size"`
+	AverageKVSize  float64 `json:"average_kv_size"`
+	MinKeySize     int     `json:"min_key_size"`
+	MaxKeySize     int     `json:"max_key_size"`
+	MinValueSize   int     `json:"min_value_size"`
+	MaxValueSize   int     `json:"max_value_size"`
+	MinKVSize      int     `json:"min_kv_size"`
+	MaxKVSize      int     `json:"max_kv_size"`
+	BucketWidth    int     `json:"bucket_width"`
+}
+
+// SizeCountRecord is a record of <data_type>_{key,value,kv}_histogram.{json,csv}
+type SizeCountRecord struct {
+	Size  int `json:"size"`
+	Count int `json:"count"`
+}
+
+// outputFormat is one of text, json, and csv
+var outputFormat = "text"
+
+// noPrefix is the data type of the keys without a known prefix
+const noPrefix = "noPrefix"
+
+// unattributed is the part of the SSTables not attributed to a data type in the storage overhead
+const unattributed = "unattributed"
+
 func (ps *PrefixStats) averageSize() float64 {
 	if ps.Count == 0 {
 		return 0
@@ -244,6 +341,22 @@ func PrintSortedHistogram(fileName string, histogram map[int]int, bucketWidth in
 	}
 	sort.Ints(buckets)
 
+	if outputFormat != "text" {
+		var records []SizeCountRecord
+		for i, bucket := range buckets {
+			records = append(records, SizeCountRecord{Size: bucket, Count: histogram[bucket]})
+			if i < len(buckets)-1 {
+				for gapBucket := bucket + bucketWidth; gapBucket < buckets[i+1]; gapBucket += bucketWidth {
+					records = append(records, SizeCountRecord{Size: gapBucket})
+				}
+			}
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
 	// Create the output file
 	fmt.Printf("Creating histogram file: %s\n", fileName)
 	outputFile, err := os.Create(fileName)
@@ -270,71 +383,1258 @@ func PrintSortedHistogram(fileName string, histogram map[int]int, bucketWidth in
 	}
 }
 
-func main() {
-	file := os.Args[1]
-	db, err := pebble.Open(file, &pebble.Options{})
-	if err != nil {
-		log.Fatalf("Cannot open target database, err: %v\n", err)
+// scanChunk is a key range [Start, End) of the database scanned by one worker, a nil bound is unbounded
+type scanChunk struct {
+	Stratum   int    // Index of the prefix range containing the chunk
+	Start     []byte // Inclusive lower bound
+	End       []byte // Exclusive upper bound
+	DiskBytes uint64 // Estimated disk usage of the range
+}
+
+// chunkResult is the number of KV pairs and their total size of each data type in a scanned chunk
+type chunkResult struct {
+	Counts map[string]int
+	Sizes  map[string]int
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the prefix, nil if there is none
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
 	}
-	defer db.Close()
+	return nil
+}
 
-	const bucketWidth = 1
-	const outputFilePath = "pebble-database-KV-count.txt"
-	const progressInterval = 1000
+// sortUniqueKeys sorts the keys and removes the duplicated and empty ones
+func sortUniqueKeys(keys [][]byte) [][]byte {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	unique := keys[:0]
+	for _, key := range keys {
+		if len(key) > 0 && (len(unique) == 0 || !bytes.Equal(unique[len(unique)-1], key)) {
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
 
-	prefixStatsMap := make(map[string]*PrefixStats)
+// buildChunks partitions the key space by the prefix ranges (the strata of the sampling), then by the smallest keys of
+// the SSTables in all levels. It returns the chunks in key order and the number of strata
+func buildChunks(db *pebble.DB) ([]*scanChunk, int, error) {
+	var strataCuts [][]byte
+	for _, prefix := range prefixes {
+		strataCuts = append(strataCuts, prefix)
+		if end := prefixUpperBound(prefix); end != nil {
+			strataCuts = append(strataCuts, end)
+		}
+	}
+	strataCuts = sortUniqueKeys(strataCuts)
 
-	outputFile, err := os.Create(outputFilePath)
+	tables, err := db.SSTables()
 	if err != nil {
-		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+		return nil, 0, fmt.Errorf("failed to list the SSTables: %v", err)
 	}
-	defer outputFile.Close()
+	cuts := append([][]byte{}, strataCuts...)
+	// lastKey is greater than all keys in the SSTables, it bounds the disk usage estimation of the last chunk
+	lastKey := []byte{0xff}
+	for _, level := range tables {
+		for _, table := range level {
+			cuts = append(cuts, append([]byte{}, table.Smallest.UserKey...))
+			if bytes.Compare(table.Largest.UserKey, lastKey) >= 0 {
+				lastKey = append(append([]byte{}, table.Largest.UserKey...), 0)
+			}
+		}
+	}
+	cuts = sortUniqueKeys(cuts)
+
+	bounds := append([][]byte{nil}, cuts...)
+	chunks := make([]*scanChunk, 0, len(bounds))
+	stratum := 0
+	for i, start := range bounds {
+		var end []byte
+		if i+1 < len(bounds) {
+			end = bounds[i+1]
+		}
+		for stratum < len(strataCuts) && start != nil && bytes.Compare(start, strataCuts[stratum]) >= 0 {
+			stratum++
+		}
+		estimateStart, estimateEnd := start, end
+		if estimateStart == nil {
+			estimateStart = []byte{}
+		}
+		if estimateEnd == nil {
+			estimateEnd = lastKey
+		}
+		diskBytes := uint64(0)
+		if bytes.Compare(estimateStart, estimateEnd) < 0 {
+			if diskBytes, err = db.EstimateDiskUsage(estimateStart, estimateEnd); err != nil {
+				return nil, 0, fmt.Errorf("failed to estimate the disk usage: %v", err)
+			}
+		}
+		chunks = append(chunks, &scanChunk{Stratum: stratum, Start: start, End: end, DiskBytes: diskBytes})
+	}
+	return chunks, len(strataCuts) + 1, nil
+}
+
+// selectChunks returns the chunks to scan, the largest first so that the workers finish at about the same time. With
+// a sample fraction, a random fraction of the chunks of each stratum is selected, but at least two chunks, so that the
+// variance can be estimated
+func selectChunks(chunks []*scanChunk, sampleFraction float64, rng *rand.Rand) []*scanChunk {
+	var selected []*scanChunk
+	if sampleFraction == 0 {
+		selected = append(selected, chunks...)
+	} else {
+		strata := make(map[int][]*scanChunk)
+		var order []int
+		for _, chunk := range chunks {
+			if _, exists := strata[chunk.Stratum]; !exists {
+				order = append(order, chunk.Stratum)
+			}
+			strata[chunk.Stratum] = append(strata[chunk.Stratum], chunk)
+		}
+		for _, stratum := range order {
+			stratumChunks := strata[stratum]
+			n := min(len(stratumChunks), max(2, int(math.Ceil(sampleFraction*float64(len(stratumChunks))))))
+			for _, i := range rng.Perm(len(stratumChunks))[:n] {
+				selected = append(selected, stratumChunks[i])
+			}
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].DiskBytes > selected[j].DiskBytes
+	})
+	return selected
+}
 
-	iter, err := db.NewIter(nil)
+// scan iterates the KV pairs of the chunk, and adds them to the statistics of the worker
+func (c *scanChunk) scan(db *pebble.DB, prefixStatsMap map[string]*PrefixStats, bucketWidth int, seenPrefixes *sync.Map, scannedPairs *atomic.Int64) (*chunkResult, error) {
+	iter, err := db.NewIter(&pebble.IterOptions{LowerBound: c.Start, UpperBound: c.End})
 	if err != nil {
-		log.Fatalf("Failed to create iterator: %v", err)
+		return nil, fmt.Errorf("failed to create iterator: %v", err)
 	}
 	defer iter.Close()
 
-	currentCount := 0
-	noPrefix := "noPrefix"
-	fmt.Printf("Start processing KV pairs\n")
-
+	result := &chunkResult{Counts: make(map[string]int), Sizes: make(map[string]int)}
+	pending := int64(0)
 	for iter.First(); iter.Valid(); iter.Next() {
-		currentCount++
-		if currentCount%progressInterval == 0 {
-			fmt.Printf("\rProcessed %d KV pairs...", currentCount)
-		}
 		key := iter.Key()
 		valueSize := len(iter.Value())
-		keySize := len(iter.Key())
-		currentPrefix := ""
+		keySize := len(key)
+		currentPrefix := noPrefix
 		if prefix, matched := matchesPrefix(key); matched {
 			currentPrefix = string(prefix)
-		} else {
-			currentPrefix = noPrefix
-		}
-		if _, exists := prefixStatsMap[currentPrefix]; !exists {
-			fmt.Printf("Locate New prefix: %s\n", currentPrefix)
-			prefixStatsMap[currentPrefix] = &PrefixStats{
-				SizeHistogramKV:    make(map[int]int),
-				SizeHistogramKey:   make(map[int]int),
-				SizeHistogramValue: make(map[int]int),
-				BucketWidth:        bucketWidth,
+		}
+		stats, exists := prefixStatsMap[currentPrefix]
+		if !exists {
+			if _, loaded := seenPrefixes.LoadOrStore(currentPrefix, true); !loaded {
+				fmt.Printf("\nLocate New prefix: %s\n", currentPrefix)
+			}
+			stats = newPrefixStats(bucketWidth)
+			prefixStatsMap[currentPrefix] = stats
+		}
+		stats.update(valueSize, keySize)
+		result.Counts[currentPrefix]++
+		result.Sizes[currentPrefix] += valueSize + keySize
+		if pending++; pending == 1000 {
+			scannedPairs.Add(pending)
+			pending = 0
+		}
+	}
+	scannedPairs.Add(pending)
+	return result, iter.Error()
+}
+
+// restartIntervals are the restart intervals of the data blocks, at which the prefix compression savings are estimated
+var restartIntervals = []int{2, 4, 8, 16, 32, 64, 128}
+
+// keyLayoutGroups compare the clustering of the keys prefixed by the block number with the keys made of a hash
+var keyLayoutGroups = []struct {
+	Name     string
+	Prefixes []string
+}{
+	{"block-number-keyed (h, b, r)", []string{"h", "b", "r"}},
+	{"hash-keyed (l, c)", []string{"l", "c"}},
+}
+
+// maxEntropyPositions is the number of leading key bytes whose entropy is measured
+const maxEntropyPositions = 80
+
+// KeyLayoutStats store the prefix sharing of the adjacent keys of a data type and the byte entropy of its keys
+type KeyLayoutStats struct {
+	PrefixLen       int                           // Length of the prefix of the data type
+	Keys            int                           // Number of keys
+	KeyBytes        int                           // Total size of the keys
+	Pairs           int                           // Number of adjacent key pairs in sorted order
+	SharedBytes     int                           // Total common prefix length of the adjacent pairs
+	SharedHistogram map[int]int                   // Histogram of the common prefix lengths
+	SavedBytes      []int                         // Key bytes saved by the prefix compression at each restart interval
+	ByteCounts      [maxEntropyPositions][256]int // Occurrences of each byte value at each key position
+}
+
+// chunkKeys are the first and the last key of each data type in a scanned chunk, to pair the keys across chunks
+type chunkKeys struct {
+	First map[string][]byte
+	Last  map[string][]byte
+}
+
+func newKeyLayoutStats(prefixLen int) *KeyLayoutStats {
+	return &KeyLayoutStats{
+		PrefixLen:       prefixLen,
+		SharedHistogram: make(map[int]int),
+		SavedBytes:      make([]int, len(restartIntervals)),
+	}
+}
+
+// commonPrefixLength returns the length of the common prefix of two keys
+func commonPrefixLength(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// addPair counts an adjacent key pair with the common prefix length
+func (ks *KeyLayoutStats) addPair(shared int) {
+	ks.Pairs++
+	ks.SharedBytes += shared
+	ks.SharedHistogram[shared]++
+}
+
+// add counts the key at the index of the data type in its chunk, where prev is the previous key of the data type (nil
+// for the first one). A key is a restart point of the data block if its index is a multiple of the restart interval,
+// the other keys only store the suffix after the prefix shared with the previous key
+func (ks *KeyLayoutStats) add(key, prev []byte, index int) {
+	ks.Keys++
+	ks.KeyBytes += len(key)
+	for i := 0; i < len(key) && i < maxEntropyPositions; i++ {
+		ks.ByteCounts[i][key[i]]++
+	}
+	if prev == nil {
+		return
+	}
+	shared := commonPrefixLength(prev, key)
+	ks.addPair(shared)
+	for i, interval := range restartIntervals {
+		if index%interval != 0 {
+			ks.SavedBytes[i] += shared
+		}
+	}
+}
+
+// merge adds the statistics of another scanner of the same data type
+func (ks *KeyLayoutStats) merge(other *KeyLayoutStats) {
+	ks.Keys += other.Keys
+	ks.KeyBytes += other.KeyBytes
+	ks.Pairs += other.Pairs
+	ks.SharedBytes += other.SharedBytes
+	for shared, count := range other.SharedHistogram {
+		ks.SharedHistogram[shared] += count
+	}
+	for i := range ks.SavedBytes {
+		ks.SavedBytes[i] += other.SavedBytes[i]
+	}
+	for i := range ks.ByteCounts {
+		for b, count := range other.ByteCounts[i] {
+			ks.ByteCounts[i][b] += count
+		}
+	}
+}
+
+// entropy returns the Shannon entropy (in bits) of the byte at the key position, and the number of keys long enough
+func (ks *KeyLayoutStats) entropy(position int) (float64, int) {
+	total := 0
+	for _, count := range ks.ByteCounts[position] {
+		total += count
+	}
+	entropy := 0.0
+	for _, count := range ks.ByteCounts[position] {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy, total
+}
+
+// randomSharedBytes is the expected total common prefix length of the adjacent pairs, if the keys after the prefix
+// were uniformly random. The gap between two adjacent keys of n random keys is about exponential with the mean 1/n, so
+// a pair shares the first k bytes with the probability 1 - (1 - exp(-x)) / x, where x = n / 256^k. The scale is the
+// number of keys in the database for each scanned key, as the sampled chunks are dense ranges of the key space
+func (ks *KeyLayoutStats) randomSharedBytes(scale float64) float64 {
+	if ks.Keys < 2 {
+		return 0
+	}
+	expected := 0.0
+	for x := float64(ks.Keys) * scale / 256; x > 1e-9; x /= 256 {
+		expected += 1 - (1-math.Exp(-x))/x
+	}
+	return float64(ks.Pairs) * expected
+}
+
+// scanLayout iterates the keys of the chunk, and adds them to the key layout statistics of the worker. The restart
+// points of each data type are counted from the start of the chunk, which starts at the smallest key of an SSTable
+func (c *scanChunk) scanLayout(db *pebble.DB, layoutStatsMap map[string]*KeyLayoutStats, scannedPairs *atomic.Int64) (*chunkKeys, error) {
+	iter, err := db.NewIter(&pebble.IterOptions{LowerBound: c.Start, UpperBound: c.End})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %v", err)
+	}
+	defer iter.Close()
+
+	keys := &chunkKeys{First: make(map[string][]byte), Last: make(map[string][]byte)}
+	indexes := make(map[string]int)
+	pending := int64(0)
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		currentPrefix, prefixLen := noPrefix, 0
+		if prefix, matched := matchesPrefix(key); matched {
+			currentPrefix, prefixLen = string(prefix), len(prefix)
+		}
+		stats, exists := layoutStatsMap[currentPrefix]
+		if !exists {
+			stats = newKeyLayoutStats(prefixLen)
+			layoutStatsMap[currentPrefix] = stats
+		}
+		prev := keys.Last[currentPrefix]
+		stats.add(key, prev, indexes[currentPrefix])
+		indexes[currentPrefix]++
+		if prev == nil {
+			keys.First[currentPrefix] = append([]byte{}, key...)
+		}
+		keys.Last[currentPrefix] = append(prev[:0], key...)
+		if pending++; pending == 1000 {
+			scannedPairs.Add(pending)
+			pending = 0
+		}
+	}
+	scannedPairs.Add(pending)
+	return keys, iter.Error()
+}
+
+// KeyLayoutRecord is a record of pebble-database-key-layout.{json,csv}, the last records are the groups of
+// keyLayoutGroups
+type KeyLayoutRecord struct {
+	DataType                 string  `json:"data_type"`
+	Keys                     int     `json:"keys"`
+	KeyBytes                 int     `json:"key_bytes"`
+	AverageKeySize           float64 `json:"average_key_size"`
+	Pairs                    int     `json:"pairs"`
+	AverageSharedBytes       float64 `json:"average_shared_bytes"`
+	AverageSharedSuffixBytes float64 `json:"average_shared_suffix_bytes"`
+	RandomSharedSuffixBytes  float64 `json:"random_shared_suffix_bytes"`
+	Clustering               float64 `json:"clustering"`
+	AverageEntropy           float64 `json:"average_entropy"`
+}
+
+// PrefixCompressionRecord is a record of pebble-database-prefix-compression.{json,csv}
+type PrefixCompressionRecord struct {
+	DataType        string  `json:"data_type"`
+	RestartInterval int     `json:"restart_interval"`
+	SavedBytes      int     `json:"saved_bytes"`
+	SavedRatio      float64 `json:"saved_ratio"`
+}
+
+// KeyEntropyRecord is a record of pebble-database-key-entropy.{json,csv}
+type KeyEntropyRecord struct {
+	DataType string  `json:"data_type"`
+	Position int     `json:"position"`
+	Keys     int     `json:"keys"`
+	Entropy  float64 `json:"entropy"`
+}
+
+// newKeyLayoutRecord summarizes the statistics, where randomShared is the expected common prefix bytes of random keys
+func newKeyLayoutRecord(dataType string, stats *KeyLayoutStats, randomShared float64) KeyLayoutRecord {
+	record := KeyLayoutRecord{
+		DataType:                 dataType,
+		Keys:                     stats.Keys,
+		KeyBytes:                 stats.KeyBytes,
+		AverageKeySize:           safeRatio(float64(stats.KeyBytes), float64(stats.Keys)),
+		Pairs:                    stats.Pairs,
+		AverageSharedBytes:       safeRatio(float64(stats.SharedBytes), float64(stats.Pairs)),
+		AverageSharedSuffixBytes: math.NaN(),
+		RandomSharedSuffixBytes:  safeRatio(randomShared, float64(stats.Pairs)),
+		Clustering:               math.NaN(),
+	}
+	if stats.Pairs > 0 {
+		sharedSuffix := float64(stats.SharedBytes - stats.Pairs*stats.PrefixLen)
+		record.AverageSharedSuffixBytes = sharedSuffix / float64(stats.Pairs)
+		record.Clustering = safeRatio(sharedSuffix, randomShared)
+	}
+	entropySum, keys := 0.0, 0
+	for position := stats.PrefixLen; position < maxEntropyPositions; position++ {
+		entropy, count := stats.entropy(position)
+		entropySum += entropy * float64(count)
+		keys += count
+	}
+	record.AverageEntropy = safeRatio(entropySum, float64(keys))
+	return record
+}
+
+// printKeyLayout writes the prefix sharing, the prefix compression savings, and the key entropy of each data type,
+// and the histograms of the common prefix lengths. The scale is the number of keys for each scanned key
+func printKeyLayout(outputFilePath string, layoutStatsMap map[string]*KeyLayoutStats, scale float64) {
+	var dataTypes []string
+	for dataType := range layoutStatsMap {
+		dataTypes = append(dataTypes, dataType)
+	}
+	sort.Strings(dataTypes)
+
+	var records []KeyLayoutRecord
+	var compressionRecords []PrefixCompressionRecord
+	var entropyRecords []KeyEntropyRecord
+	for _, dataType := range dataTypes {
+		stats := layoutStatsMap[dataType]
+		records = append(records, newKeyLayoutRecord(dataType, stats, stats.randomSharedBytes(scale)))
+		for i, interval := range restartIntervals {
+			compressionRecords = append(compressionRecords, PrefixCompressionRecord{
+				DataType:        dataType,
+				RestartInterval: interval,
+				SavedBytes:      stats.SavedBytes[i],
+				SavedRatio:      safeRatio(float64(stats.SavedBytes[i]), float64(stats.KeyBytes)),
+			})
+		}
+		for position := 0; position < maxEntropyPositions; position++ {
+			entropy, keys := stats.entropy(position)
+			if keys == 0 {
+				break
+			}
+			entropyRecords = append(entropyRecords, KeyEntropyRecord{DataType: dataType, Position: position, Keys: keys, Entropy: entropy})
+		}
+		PrintSortedHistogram(fmt.Sprintf("%s_shared_prefix_histogram.txt", dataType), stats.SharedHistogram, 1)
+	}
+	for _, group := range keyLayoutGroups {
+		stats := newKeyLayoutStats(1)
+		randomShared := 0.0
+		for _, dataType := range group.Prefixes {
+			if member, exists := layoutStatsMap[dataType]; exists {
+				stats.merge(member)
+				randomShared += member.randomSharedBytes(scale)
+			}
+		}
+		records = append(records, newKeyLayoutRecord(group.Name, stats, randomShared))
+	}
+
+	if outputFormat != "text" {
+		if err := writeRecords(outputPath(outputFilePath, outputFormat), outputFormat, records); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("pebble-database-prefix-compression.txt", outputFormat), outputFormat, compressionRecords); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("pebble-database-key-entropy.txt", outputFormat), outputFormat, entropyRecords); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+	for _, record := range records {
+		fmt.Fprintf(outputFile, "DataType: %s\n", record.DataType)
+		fmt.Fprintf(outputFile, "  Keys: %d, key bytes: %d, average key size: %.2f\n", record.Keys, record.KeyBytes, record.AverageKeySize)
+		fmt.Fprintf(outputFile, "  Adjacent pairs: %d, average shared prefix: %.2f B\n", record.Pairs, record.AverageSharedBytes)
+		fmt.Fprintf(outputFile, "  Average shared bytes after the prefix: %.2f (random keys: %.2f, clustering: %.2f)\n",
+			record.AverageSharedSuffixBytes, record.RandomSharedSuffixBytes, record.Clustering)
+		fmt.Fprintf(outputFile, "  Average entropy after the prefix: %.2f bits/byte\n", record.AverageEntropy)
+		if _, exists := layoutStatsMap[record.DataType]; !exists {
+			fmt.Fprintln(outputFile)
+			continue
+		}
+		fmt.Fprintln(outputFile, "  Prefix compression savings (restart interval: saved bytes, share of key bytes):")
+		for _, cr := range compressionRecords {
+			if cr.DataType == record.DataType {
+				fmt.Fprintf(outputFile, "    %d: %d (%.2f%%)\n", cr.RestartInterval, cr.SavedBytes, cr.SavedRatio*100)
+			}
+		}
+		fmt.Fprintln(outputFile, "  Key byte entropy (position: bits):")
+		for _, er := range entropyRecords {
+			if er.DataType == record.DataType {
+				fmt.Fprintf(outputFile, "    %d: %.2f\n", er.Position, er.Entropy)
+			}
+		}
+		fmt.Fprintln(outputFile)
+	}
+	fmt.Printf("Key layout statistics are stored to: %s\n", outputFilePath)
+}
+
+// SpanStats is the SSTable footprint of a key range, where the properties of each table are weighted by the share of
+// the table in the range
+type SpanStats struct {
+	Tables          int     // Number of tables overlapping the range
+	PhysicalBytes   float64 // Bytes on disk
+	DataBytes       float64 // Bytes of the data and value blocks, the rest are index, filter, and other metadata
+	RawKeyBytes     float64 // Uncompressed bytes of all entries, including the obsolete versions and tombstones
+	RawValueBytes   float64
+	Entries         float64
+	Tombstones      float64 // Point deletions
+	RangeTombstones float64
+}
+
+// addTable adds the share of the table with spanBytes bytes in the range
+func (s *SpanStats) addTable(table pebble.SSTableInfo, spanBytes uint64) {
+	if table.Size == 0 || table.Properties == nil {
+		return
+	}
+	weight := float64(spanBytes) / float64(table.Size)
+	props := table.Properties
+	s.Tables++
+	s.PhysicalBytes += float64(spanBytes)
+	s.DataBytes += weight * float64(props.DataSize+props.ValueBlocksSize)
+	s.RawKeyBytes += weight * float64(props.RawKeySize)
+	s.RawValueBytes += weight * float64(props.RawValueSize)
+	s.Entries += weight * float64(props.NumEntries)
+	s.Tombstones += weight * float64(props.NumDeletions)
+	s.RangeTombstones += weight * float64(props.NumRangeDeletions)
+}
+
+// subtract removes the footprint of a nested range, the results are clamped at 0
+func (s *SpanStats) subtract(other SpanStats) {
+	s.PhysicalBytes = max(s.PhysicalBytes-other.PhysicalBytes, 0)
+	s.DataBytes = max(s.DataBytes-other.DataBytes, 0)
+	s.RawKeyBytes = max(s.RawKeyBytes-other.RawKeyBytes, 0)
+	s.RawValueBytes = max(s.RawValueBytes-other.RawValueBytes, 0)
+	s.Entries = max(s.Entries-other.Entries, 0)
+	s.Tombstones = max(s.Tombstones-other.Tombstones, 0)
+	s.RangeTombstones = max(s.RangeTombstones-other.RangeTombstones, 0)
+}
+
+// spanStats returns the SSTable footprint of [start, end)
+func spanStats(db *pebble.DB, start, end []byte) (SpanStats, error) {
+	var stats SpanStats
+	levels, err := db.SSTables(pebble.WithProperties(), pebble.WithKeyRangeFilter(start, end), pebble.WithApproximateSpanBytes())
+	if err != nil {
+		return stats, fmt.Errorf("failed to list the SSTables of %q: %v", start, err)
+	}
+	for _, level := range levels {
+		for _, table := range level {
+			spanBytes, err := strconv.ParseUint(table.Properties.UserProperties["approximate-span-bytes"], 10, 64)
+			if err != nil {
+				continue
+			}
+			stats.addTable(table, spanBytes)
+		}
+	}
+	return stats, nil
+}
+
+// categorySpanStats attributes the SSTables to the data types with KV pairs. A key belongs to the first matching
+// prefix, so the range of a prefix excludes the ranges of the longer prefixes listed before it. The rest of the
+// SSTables is unattributed: the keys without a known prefix (noPrefix), which have no prefix range, and the overhead of
+// the estimation by whole data blocks. The empty ranges are skipped, since they would still be charged a data block
+func categorySpanStats(db *pebble.DB, dataTypes map[string]float64) (map[string]SpanStats, SpanStats, SpanStats, error) {
+	var total, rest SpanStats
+	levels, err := db.SSTables(pebble.WithProperties())
+	if err != nil {
+		return nil, rest, total, fmt.Errorf("failed to list the SSTables: %v", err)
+	}
+	for _, level := range levels {
+		for _, table := range level {
+			total.addTable(table, table.Size)
+		}
+	}
+
+	rangeStats := make(map[string]SpanStats)
+	categories := make(map[string]SpanStats)
+	for i, prefix := range prefixes {
+		if dataTypes[string(prefix)] == 0 {
+			continue
+		}
+		stats, err := spanStats(db, prefix, prefixUpperBound(prefix))
+		if err != nil {
+			return nil, rest, total, err
+		}
+		rangeStats[string(prefix)] = stats
+		for _, earlier := range prefixes[:i] {
+			if nested, exists := rangeStats[string(earlier)]; exists && HasPrefix(earlier, prefix) {
+				stats.subtract(nested)
+			}
+		}
+		categories[string(prefix)] = stats
+	}
+
+	rest = total
+	for _, stats := range categories {
+		rest.subtract(stats)
+	}
+	return categories, rest, total, nil
+}
+
+// FreezerTable is the size of a table of the ancient freezer
+type FreezerTable struct {
+	Freezer string
+	Table   string
+	Items   uint64 // Number of items in the freezer
+	Size    uint64
+}
+
+// freezerTables lists the tables of the chain and state freezers, as in rawdb
+var freezerTables = map[string]map[string]bool{
+	rawdb.ChainFreezerName: {
+		rawdb.ChainFreezerHeaderTable:     false,
+		rawdb.ChainFreezerHashTable:       true,
+		rawdb.ChainFreezerBodiesTable:     false,
+		rawdb.ChainFreezerReceiptTable:    false,
+		rawdb.ChainFreezerDifficultyTable: true,
+	},
+	rawdb.MerkleStateFreezerName: {
+		"history.meta":  true,
+		"account.index": false,
+		"storage.index": false,
+		"account.data":  false,
+		"storage.data":  false,
+	},
+}
+
+// readFreezerTables opens the freezers under the ancient directory read-only and returns the sizes of their tables.
+// The chain freezer may also be in the ancient directory itself (the legacy layout)
+func readFreezerTables(ancientPath string) ([]FreezerTable, error) {
+	if _, err := os.Stat(ancientPath); err != nil {
+		return nil, fmt.Errorf("no ancient directory at %s", ancientPath)
+	}
+	var freezerNames []string
+	for name := range freezerTables {
+		freezerNames = append(freezerNames, name)
+	}
+	sort.Strings(freezerNames)
+
+	var sizes []FreezerTable
+	for _, name := range freezerNames {
+		dir := filepath.Join(ancientPath, name)
+		if _, err := os.Stat(dir); err != nil {
+			if name != rawdb.ChainFreezerName {
+				continue
 			}
+			dir = ancientPath
 		}
-		prefixStatsMap[currentPrefix].update(valueSize, keySize)
+		freezer, err := rawdb.NewFreezer(dir, "", true, 2*1000*1000*1000, freezerTables[name])
+		if err != nil {
+			fmt.Printf("Cannot open the %s freezer at %s: %v\n", name, dir, err)
+			continue
+		}
+		items, _ := freezer.Ancients()
+		var tables []string
+		for table := range freezerTables[name] {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			size, err := freezer.AncientSize(table)
+			if err != nil {
+				freezer.Close()
+				return nil, fmt.Errorf("failed to read the size of %s/%s: %v", name, table, err)
+			}
+			sizes = append(sizes, FreezerTable{Freezer: name, Table: table, Items: items, Size: size})
+		}
+		freezer.Close()
 	}
+	return sizes, nil
+}
 
-	if err := iter.Error(); err != nil {
+// directorySize returns the sizes of the SSTables, the WAL, and the other files (e.g., MANIFEST) in the directory
+func directorySize(path string) (uint64, uint64, uint64, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var sstSize, walSize, otherSize uint64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".sst":
+			sstSize += uint64(info.Size())
+		case ".log":
+			walSize += uint64(info.Size())
+		default:
+			otherSize += uint64(info.Size())
+		}
+	}
+	return sstSize, walSize, otherSize, nil
+}
+
+// StorageOverheadRecord is a record of pebble-database-storage.{json,csv}, the physical values are estimated from
+// the SSTable properties, and the last record (All) is the whole database
+type StorageOverheadRecord struct {
+	DataType           string  `json:"data_type"`
+	KVPairs            float64 `json:"kv_pairs"`
+	LogicalBytes       float64 `json:"logical_bytes"`
+	PhysicalBytes      float64 `json:"physical_bytes"`
+	DataBlockBytes     float64 `json:"data_block_bytes"`
+	MetadataBytes      float64 `json:"metadata_bytes"`
+	RawBytes           float64 `json:"raw_bytes"`
+	CompressionRatio   float64 `json:"compression_ratio"`
+	Entries            float64 `json:"entries"`
+	Tombstones         float64 `json:"tombstones"`
+	RangeTombstones    float64 `json:"range_tombstones"`
+	SpaceAmplification float64 `json:"space_amplification"`
+}
+
+// FreezerRecord is a record of pebble-database-freezer.{json,csv}
+type FreezerRecord struct {
+	Freezer string `json:"freezer"`
+	Table   string `json:"table"`
+	Items   uint64 `json:"items"`
+	Size    uint64 `json:"size"`
+}
+
+// safeRatio returns a / b, or NaN if b is 0
+func safeRatio(a, b float64) float64 {
+	if b == 0 {
+		return math.NaN()
+	}
+	return a / b
+}
+
+func newStorageOverheadRecord(dataType string, kvPairs, logicalBytes float64, stats SpanStats) StorageOverheadRecord {
+	rawBytes := stats.RawKeyBytes + stats.RawValueBytes
+	spaceAmplification := safeRatio(stats.PhysicalBytes, logicalBytes)
+	if stats.Tables == 0 {
+		// The keys without a known prefix have no range, their SSTables are in the unattributed part
+		spaceAmplification = math.NaN()
+	}
+	return StorageOverheadRecord{
+		DataType:           dataType,
+		KVPairs:            kvPairs,
+		LogicalBytes:       logicalBytes,
+		PhysicalBytes:      stats.PhysicalBytes,
+		DataBlockBytes:     stats.DataBytes,
+		MetadataBytes:      max(stats.PhysicalBytes-stats.DataBytes, 0),
+		RawBytes:           rawBytes,
+		CompressionRatio:   safeRatio(rawBytes, stats.DataBytes),
+		Entries:            stats.Entries,
+		Tombstones:         stats.Tombstones,
+		RangeTombstones:    stats.RangeTombstones,
+		SpaceAmplification: spaceAmplification,
+	}
+}
+
+// printStorage writes the logical and physical size of each data type, the space amplification, and the sizes of
+// the freezer tables. The logical sizes are the scanned (or, when sampling, extrapolated) key and value sizes
+func printStorage(db *pebble.DB, dbPath, ancientPath, outputFilePath, freezerFilePath string, kvPairs, logicalBytes map[string]float64) {
+	categories, rest, total, err := categorySpanStats(db, kvPairs)
+	if err != nil {
 		log.Fatal(err)
 	}
+	var dataTypes []string
+	for dataType := range logicalBytes {
+		dataTypes = append(dataTypes, dataType)
+	}
+	sort.Strings(dataTypes)
+	var records []StorageOverheadRecord
+	totalPairs, totalLogical := 0.0, 0.0
+	for _, dataType := range dataTypes {
+		records = append(records, newStorageOverheadRecord(dataType, kvPairs[dataType], logicalBytes[dataType], categories[dataType]))
+		totalPairs += kvPairs[dataType]
+		totalLogical += logicalBytes[dataType]
+	}
+	if rest.PhysicalBytes > 0 {
+		records = append(records, newStorageOverheadRecord(unattributed, 0, 0, rest))
+	}
+	records = append(records, newStorageOverheadRecord("All", totalPairs, totalLogical, total))
 
-	fmt.Printf("\rProcessed %d KV pairs... Done!\n", currentCount)
+	freezer, err := readFreezerTables(ancientPath)
+	if err != nil {
+		fmt.Println(err)
+	}
+	freezerSize := uint64(0)
+	var freezerRecords []FreezerRecord
+	for _, table := range freezer {
+		freezerSize += table.Size
+		freezerRecords = append(freezerRecords, FreezerRecord(table))
+	}
+	sstSize, walSize, otherSize, err := directorySize(dbPath)
+	if err != nil {
+		log.Fatalf("Cannot read the database directory %s: %v", dbPath, err)
+	}
 
+	if outputFormat != "text" {
+		if err := writeRecords(outputPath(outputFilePath, outputFormat), outputFormat, records); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath(freezerFilePath, outputFormat), outputFormat, freezerRecords); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+	all := records[len(records)-1]
+	pebbleSize := sstSize + walSize + otherSize
+	fmt.Fprintf(outputFile, "Pebble directory size: %d (SSTables: %d, WAL: %d, other: %d)\n", pebbleSize, sstSize, walSize, otherSize)
+	fmt.Fprintf(outputFile, "SSTables: %d, physical size: %.0f, data blocks: %.0f, index/filter/metadata: %.0f\n", total.Tables, all.PhysicalBytes, all.DataBlockBytes, all.MetadataBytes)
+	fmt.Fprintf(outputFile, "Raw size: %.0f (keys: %.0f, values: %.0f), compression ratio: %.2f\n", all.RawBytes, total.RawKeyBytes, total.RawValueBytes, all.CompressionRatio)
+	fmt.Fprintf(outputFile, "Entries: %.0f, point tombstones: %.0f, range tombstones: %.0f, live KV pairs: %.0f\n", all.Entries, all.Tombstones, all.RangeTombstones, all.KVPairs)
+	fmt.Fprintf(outputFile, "Logical size: %.0f, space amplification: %.2f (directory: %.2f)\n", all.LogicalBytes, all.SpaceAmplification, safeRatio(float64(pebbleSize), all.LogicalBytes))
+	fmt.Fprintf(outputFile, "Freezer size: %d, total on-disk size: %d\n", freezerSize, pebbleSize+freezerSize)
+	fmt.Fprintln(outputFile)
+
+	for _, record := range records[:len(records)-1] {
+		if record.DataType == unattributed {
+			fmt.Fprintln(outputFile, "Unattributed (the keys without a known prefix and the overhead of the block-granular estimation):")
+			fmt.Fprintf(outputFile, "  Physical size: %.0f (data blocks: %.0f, index/filter/metadata: %.0f)\n", record.PhysicalBytes, record.DataBlockBytes, record.MetadataBytes)
+			fmt.Fprintf(outputFile, "  Raw size: %.0f, entries: %.0f, point tombstones: %.0f, range tombstones: %.0f\n", record.RawBytes, record.Entries, record.Tombstones, record.RangeTombstones)
+			fmt.Fprintln(outputFile)
+			continue
+		}
+		fmt.Fprintf(outputFile, "DataType: %s\n", record.DataType)
+		fmt.Fprintf(outputFile, "  Logical size: %.0f (KV pairs: %.0f)\n", record.LogicalBytes, record.KVPairs)
+		if record.DataType == noPrefix {
+			fmt.Fprintln(outputFile, "  Physical size: counted as unattributed")
+			fmt.Fprintln(outputFile)
+			continue
+		}
+		fmt.Fprintf(outputFile, "  Physical size: %.0f (data blocks: %.0f, index/filter/metadata: %.0f)\n", record.PhysicalBytes, record.DataBlockBytes, record.MetadataBytes)
+		fmt.Fprintf(outputFile, "  Raw size: %.0f, compression ratio: %.2f\n", record.RawBytes, record.CompressionRatio)
+		fmt.Fprintf(outputFile, "  Entries: %.0f, point tombstones: %.0f, range tombstones: %.0f\n", record.Entries, record.Tombstones, record.RangeTombstones)
+		fmt.Fprintf(outputFile, "  Space amplification: %.2f\n", record.SpaceAmplification)
+		fmt.Fprintln(outputFile)
+	}
+
+	for i, table := range freezer {
+		if i == 0 || freezer[i-1].Freezer != table.Freezer {
+			fmt.Fprintf(outputFile, "Freezer: %s (items: %d)\n", table.Freezer, table.Items)
+		}
+		fmt.Fprintf(outputFile, "  %s: %d\n", table.Table, table.Size)
+	}
+	fmt.Printf("Storage overhead is stored to: %s\n", outputFilePath)
+}
+
+// KVEstimate is the extrapolated number of KV pairs and total size of a data type, with the half widths of their 95%
+// confidence intervals
+type KVEstimate struct {
+	DataType     string
+	SampledPairs int
+	Count        float64
+	CountError   float64
+	Size         float64
+	SizeError    float64
+}
+
+// estimateStats extrapolates the sampled chunks to each stratum with the ratio estimator, where the auxiliary variable
+// is the estimated disk usage of the chunks (or the number of chunks, if the disk usage of the stratum is unknown).
+// The estimates and variances of the strata are summed up
+func estimateStats(chunks []*scanChunk, selected []*scanChunk, results []*chunkResult) []KVEstimate {
+	type stratumTotal struct {
+		chunks    int
+		diskBytes float64
+		sampled   []int // Indexes of the sampled chunks
+	}
+	strata := make(map[int]*stratumTotal)
+	for _, chunk := range chunks {
+		if _, exists := strata[chunk.Stratum]; !exists {
+			strata[chunk.Stratum] = &stratumTotal{}
+		}
+		strata[chunk.Stratum].chunks++
+		strata[chunk.Stratum].diskBytes += float64(chunk.DiskBytes)
+	}
+	for i, chunk := range selected {
+		strata[chunk.Stratum].sampled = append(strata[chunk.Stratum].sampled, i)
+	}
+
+	estimates := make(map[string]*KVEstimate)
+	countVariances := make(map[string]float64)
+	sizeVariances := make(map[string]float64)
+	for _, stratum := range strata {
+		n := len(stratum.sampled)
+		if n == 0 {
+			continue
+		}
+		x := make([]float64, n)
+		sampledX := 0.0
+		for i, index := range stratum.sampled {
+			x[i] = float64(selected[index].DiskBytes)
+			sampledX += x[i]
+		}
+		totalX := stratum.diskBytes
+		if sampledX == 0 {
+			for i := range x {
+				x[i] = 1
+			}
+			sampledX, totalX = float64(n), float64(stratum.chunks)
+		}
+		dataTypes := make(map[string]bool)
+		for _, index := range stratum.sampled {
+			for dataType := range results[index].Counts {
+				dataTypes[dataType] = true
+			}
+		}
+		// ratio returns the estimate of the stratum total and its variance
+		ratio := func(y []float64) (float64, float64) {
+			sampledY := 0.0
+			for _, value := range y {
+				sampledY += value
+			}
+			r := sampledY / sampledX
+			if n < 2 || n == stratum.chunks {
+				return r * totalX, 0
+			}
+			residuals := 0.0
+			for i := range y {
+				residuals += (y[i] - r*x[i]) * (y[i] - r*x[i])
+			}
+			N := float64(stratum.chunks)
+			return r * totalX, N * N * (1 - float64(n)/N) / float64(n) * residuals / float64(n-1)
+		}
+		for dataType := range dataTypes {
+			counts, sizes := make([]float64, n), make([]float64, n)
+			estimate, exists := estimates[dataType]
+			if !exists {
+				estimate = &KVEstimate{DataType: dataType}
+				estimates[dataType] = estimate
+			}
+			for i, index := range stratum.sampled {
+				counts[i] = float64(results[index].Counts[dataType])
+				sizes[i] = float64(results[index].Sizes[dataType])
+				estimate.SampledPairs += results[index].Counts[dataType]
+			}
+			count, countVariance := ratio(counts)
+			size, sizeVariance := ratio(sizes)
+			estimate.Count += count
+			estimate.Size += size
+			countVariances[dataType] += countVariance
+			sizeVariances[dataType] += sizeVariance
+		}
+	}
+
+	var sortedEstimates []KVEstimate
+	for dataType, estimate := range estimates {
+		estimate.CountError = 1.96 * math.Sqrt(countVariances[dataType])
+		estimate.SizeError = 1.96 * math.Sqrt(sizeVariances[dataType])
+		sortedEstimates = append(sortedEstimates, *estimate)
+	}
+	sort.Slice(sortedEstimates, func(i, j int) bool {
+		return sortedEstimates[i].DataType < sortedEstimates[j].DataType
+	})
+	return sortedEstimates
+}
+
+// EstimateRecord is a record of pebble-database-KV-estimate.{json,csv}, the lower bounds are at least the sampled values
+type EstimateRecord struct {
+	DataType       string  `json:"data_type"`
+	SampledKVPairs int     `json:"sampled_kv_pairs"`
+	KVPairs        float64 `json:"kv_pairs"`
+	KVPairsLow     float64 `json:"kv_pairs_ci_low"`
+	KVPairsHigh    float64 `json:"kv_pairs_ci_high"`
+	TotalSize      float64 `json:"total_size"`
+	TotalSizeLow   float64 `json:"total_size_ci_low"`
+	TotalSizeHigh  float64 `json:"total_size_ci_high"`
+	AverageKVSize  float64 `json:"average_kv_size"`
+}
+
+// printEstimates writes the extrapolated statistics of the sampling mode
+func printEstimates(outputFilePath string, estimates []KVEstimate, sampledStats map[string]*PrefixStats, sampledChunks, chunks int, sampledBytes, totalBytes uint64) {
+	var records []EstimateRecord
+	for _, estimate := range estimates {
+		sampledSize := 0
+		if stats, exists := sampledStats[estimate.DataType]; exists {
+			sampledSize = stats.TotalSize
+		}
+		record := EstimateRecord{
+			DataType:       estimate.DataType,
+			SampledKVPairs: estimate.SampledPairs,
+			KVPairs:        estimate.Count,
+			KVPairsLow:     max(estimate.Count-estimate.CountError, float64(estimate.SampledPairs)),
+			KVPairsHigh:    estimate.Count + estimate.CountError,
+			TotalSize:      estimate.Size,
+			TotalSizeLow:   max(estimate.Size-estimate.SizeError, float64(sampledSize)),
+			TotalSizeHigh:  estimate.Size + estimate.SizeError,
+		}
+		if estimate.Count > 0 {
+			record.AverageKVSize = estimate.Size / estimate.Count
+		}
+		records = append(records, record)
+	}
+
+	if outputFormat != "text" {
+		if err := writeRecords(outputPath(outputFilePath, outputFormat), outputFormat, records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+	sampledShare := 0.0
+	if totalBytes > 0 {
+		sampledShare = float64(sampledBytes) / float64(totalBytes) * 100
+	}
+	fmt.Fprintf(outputFile, "Sampled chunks: %d of %d (%.2f%% of the estimated disk usage)\n\n", sampledChunks, chunks, sampledShare)
+	for _, record := range records {
+		fmt.Fprintf(outputFile, "DataType: %s\n", record.DataType)
+		fmt.Fprintf(outputFile, "  Sampled KV pair number: %d\n", record.SampledKVPairs)
+		fmt.Fprintf(outputFile, "  Estimated KV pair number: %.0f (95%% CI: %.0f - %.0f)\n", record.KVPairs, record.KVPairsLow, record.KVPairsHigh)
+		fmt.Fprintf(outputFile, "  Estimated total size: %.0f (95%% CI: %.0f - %.0f)\n", record.TotalSize, record.TotalSizeLow, record.TotalSizeHigh)
+		fmt.Fprintf(outputFile, "  Estimated average KV size: %.2f\n", record.AverageKVSize)
+		fmt.Fprintln(outputFile)
+	}
+	fmt.Printf("Estimates are stored to: %s\n", outputFilePath)
+}
+
+// runChunks scans the selected chunks (by their index) with the workers, and prints the progress and the ETA
+func runChunks(selected []*scanChunk, workers int, selectedBytes uint64, scannedPairs *atomic.Int64, scan func(worker, i int) error) {
+	var scannedChunks atomic.Int64
+	var scannedBytes atomic.Uint64
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobs {
+				if err := scan(worker, i); err != nil {
+					log.Fatal(err)
+				}
+				scannedChunks.Add(1)
+				scannedBytes.Add(selected[i].DiskBytes)
+			}
+		}(w)
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// The scanned fraction is weighted by the disk usage, or by the chunks if it is unknown
+				fraction := float64(scannedChunks.Load()) / float64(len(selected))
+				if selectedBytes > 0 {
+					fraction = float64(scannedBytes.Load()) / float64(selectedBytes)
+				}
+				elapsed := time.Since(start)
+				fmt.Printf("\rProcessed %d KV pairs, chunks: %d/%d, scanned: %.2f%%, elapsed time: %.2fs, ETA: %s",
+					scannedPairs.Load(), scannedChunks.Load(), len(selected), fraction*100, elapsed.Seconds(), formatETA(elapsed, fraction))
+			}
+		}
+	}()
+	for i := range selected {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	close(done)
+}
+
+// formatETA estimates the remaining time from the scanned fraction
+func formatETA(elapsed time.Duration, fraction float64) string {
+	if fraction <= 0 {
+		return "unknown"
+	}
+	if fraction >= 1 {
+		return "0s"
+	}
+	return (time.Duration(float64(elapsed) * (1 - fraction) / fraction)).Round(time.Second).String()
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of concurrent scanners")
+	sampleFraction := flag.Float64("sample", 0, "fraction of the chunks of each prefix range to scan, from which the KV pairs and sizes are extrapolated (0 scans the whole database)")
+	seed := flag.Int64("seed", 1, "random seed of the chunk sampling")
+	ancient := flag.String("ancient", "", "path of the ancient freezer (<pebble_database_path>/ancient by default)")
+	mode := flag.String("mode", "size", "analysis mode: size (KV sizes and storage overhead) or layout (key prefix sharing and entropy)")
+	flag.Parse()
+	if flag.NArg() < 1 || (*format != "text" && *format != "json" && *format != "csv") || *workers < 1 || *sampleFraction < 0 || *sampleFraction >= 1 || (*mode != "size" && *mode != "layout") {
+		fmt.Println("Usage: program [--format=json|csv|text] [--mode=size|layout] [--workers=N] [--sample=fraction] [--seed=N] [--ancient=path] <pebble_database_path>")
+		return
+	}
+	outputFormat = *format
+	file := flag.Arg(0)
+	db, err := pebble.Open(file, &pebble.Options{ReadOnly: true})
+	if err != nil {
+		log.Fatalf("Cannot open target database, err: %v\n", err)
+	}
+	defer db.Close()
+
+	const bucketWidth = 1
+	const outputFilePath = "pebble-database-KV-count.txt"
+	const estimateFilePath = "pebble-database-KV-estimate.txt"
+	const storageFilePath = "pebble-database-storage.txt"
+	const freezerFilePath = "pebble-database-freezer.txt"
+	const layoutFilePath = "pebble-database-key-layout.txt"
+	ancientPath := *ancient
+	if ancientPath == "" {
+		ancientPath = filepath.Join(file, "ancient")
+	}
+
+	chunks, strata, err := buildChunks(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	selected := selectChunks(chunks, *sampleFraction, rand.New(rand.NewSource(*seed)))
+	totalBytes, selectedBytes := uint64(0), uint64(0)
+	for _, chunk := range chunks {
+		totalBytes += chunk.DiskBytes
+	}
+	for _, chunk := range selected {
+		selectedBytes += chunk.DiskBytes
+	}
+	fmt.Printf("Partitioned %d prefix ranges into %d chunks (%.2f GiB on disk), scanning %d chunks (%.2f GiB) with %d workers\n",
+		strata, len(chunks), float64(totalBytes)/1024/1024/1024, len(selected), float64(selectedBytes)/1024/1024/1024, *workers)
+	fmt.Printf("Start processing KV pairs\n")
+
+	var scannedPairs atomic.Int64
+	if *mode == "layout" {
+		layoutStats := make([]map[string]*KeyLayoutStats, *workers)
+		for w := range layoutStats {
+			layoutStats[w] = make(map[string]*KeyLayoutStats)
+		}
+		results := make(map[*scanChunk]*chunkKeys)
+		var resultsMutex sync.Mutex
+		runChunks(selected, *workers, selectedBytes, &scannedPairs, func(worker, i int) error {
+			keys, err := selected[i].scanLayout(db, layoutStats[worker], &scannedPairs)
+			resultsMutex.Lock()
+			results[selected[i]] = keys
+			resultsMutex.Unlock()
+			return err
+		})
+		fmt.Printf("\rProcessed %d KV pairs... Done!\n", scannedPairs.Load())
+
+		layoutStatsMap := make(map[string]*KeyLayoutStats)
+		for _, stats := range layoutStats {
+			for prefix, ks := range stats {
+				if _, exists := layoutStatsMap[prefix]; !exists {
+					layoutStatsMap[prefix] = newKeyLayoutStats(ks.PrefixLen)
+				}
+				layoutStatsMap[prefix].merge(ks)
+			}
+		}
+		// Pair the last key of each data type in a chunk with its first key in the next scanned chunk, the keys of
+		// the chunks skipped by the sampling break the pairs
+		last := make(map[string][]byte)
+		for _, chunk := range chunks {
+			keys, scanned := results[chunk]
+			if !scanned {
+				last = make(map[string][]byte)
+				continue
+			}
+			for prefix, first := range keys.First {
+				if prev, exists := last[prefix]; exists {
+					layoutStatsMap[prefix].addPair(commonPrefixLength(prev, first))
+				}
+			}
+			for prefix, key := range keys.Last {
+				last[prefix] = key
+			}
+		}
+		scale := 1.0
+		if *sampleFraction > 0 && selectedBytes > 0 {
+			scale = float64(totalBytes) / float64(selectedBytes)
+		}
+		printKeyLayout(layoutFilePath, layoutStatsMap, scale)
+		return
+	}
+
+	var seenPrefixes sync.Map
+	results := make([]*chunkResult, len(selected))
+	workerStats := make([]map[string]*PrefixStats, *workers)
+	for w := range workerStats {
+		workerStats[w] = make(map[string]*PrefixStats)
+	}
+	runChunks(selected, *workers, selectedBytes, &scannedPairs, func(worker, i int) error {
+		result, err := selected[i].scan(db, workerStats[worker], bucketWidth, &seenPrefixes, &scannedPairs)
+		results[i] = result
+		return err
+	})
+
+	prefixStatsMap := make(map[string]*PrefixStats)
+	for _, stats := range workerStats {
+		for prefix, ps := range stats {
+			if _, exists := prefixStatsMap[prefix]; !exists {
+				prefixStatsMap[prefix] = newPrefixStats(bucketWidth)
+			}
+			prefixStatsMap[prefix].merge(ps)
+		}
+	}
+
+	fmt.Printf("\rProcessed %d KV pairs... Done!\n", scannedPairs.Load())
+
+	kvPairs, logicalBytes := make(map[string]float64), make(map[string]float64)
+	if *sampleFraction > 0 {
+		// The statistics below are of the sampled KV pairs only
+		estimates := estimateStats(chunks, selected, results)
+		printEstimates(estimateFilePath, estimates, prefixStatsMap, len(selected), len(chunks), selectedBytes, totalBytes)
+		for _, estimate := range estimates {
+			kvPairs[estimate.DataType], logicalBytes[estimate.DataType] = estimate.Count, estimate.Size
+		}
+	} else {
+		for prefix, stats := range prefixStatsMap {
+			kvPairs[prefix], logicalBytes[prefix] = float64(stats.Count), float64(stats.TotalSize)
+		}
+	}
+	printStorage(db, file, ancientPath, storageFilePath, freezerFilePath, kvPairs, logicalBytes)
+
+	if outputFormat != "text" {
+		var records []StorageRecord
+		for prefix, stats := range prefixStatsMap {
+			records = append(records, StorageRecord{
+				DataType:       prefix,
+				KVPairs:        int(math.Round(kvPairs[prefix])),
+				SampledKVPairs: stats.Count,
+				TotalSize:      int(math.Round(logicalBytes[prefix])),
+				AverageKVSize:  stats.averageSize(),
+				MinKeySize:     stats.MinSizeKey,
+				MaxKeySize:     stats.MaxSizeKey,
+				MinValueSize:   stats.MinSizeValue,
+				MaxValueSize:   stats.MaxSizeValue,
+				MinKVSize:      stats.MinSizeKV,
+				MaxKVSize:      stats.MaxSizeKV,
+				BucketWidth:    stats.BucketWidth,
+			})
+			PrintSortedHistogram(fmt.Sprintf("%s_key_histogram.txt", prefix), stats.SizeHistogramKey, stats.BucketWidth)
+			PrintSortedHistogram(fmt.Sprintf("%s_value_histogram.txt", prefix), stats.SizeHistogramValue, stats.BucketWidth)
+			PrintSortedHistogram(fmt.Sprintf("%s_kv_histogram.txt", prefix), stats.SizeHistogramKV, stats.BucketWidth)
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].DataType < records[j].DataType
+		})
+		if err := writeRecords(outputPath(outputFilePath, outputFormat), outputFormat, records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+
+	if *sampleFraction > 0 {
+		fmt.Fprintf(outputFile, "Sampled %d of %d chunks: the KV pair numbers are extrapolated (see %s), the sizes and distributions are of the sampled KV pairs\n\n",
+			len(selected), len(chunks), estimateFilePath)
+	}
 	for prefix, stats := range prefixStatsMap {
 		fmt.Fprintf(outputFile, "DataType: %s\n", prefix)
-		fmt.Fprintf(outputFile, "  KV pair number: %d\n", stats.Count)
+		fmt.Fprintf(outputFile, "  KV pair number: %.0f\n", kvPairs[prefix])
+		if *sampleFraction > 0 {
+			fmt.Fprintf(outputFile, "  Sampled KV pair number: %d\n", stats.Count)
+		}
 		fmt.Fprintf(outputFile, "  Average KV size: %.2f\n", stats.averageSize())
 		fmt.Fprintf(outputFile, "  Min size for keys: %d\n", stats.MinSizeKey)
 		fmt.Fprintf(outputFile, "  Max size for keys: %d\n", stats.MaxSizeKey)
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// KeyState tracks the write history of a single key that is still alive in the trace
+type KeyState struct {
+	CreateBlock    uint64 // Block ID of the create (first write) event
+	LastWriteBlock uint64 // Block ID of the latest create or update event
+	UpdateCount    int    // Number of updates since the create event
+	CreatedInTrace bool   // Whether the create event is observed in the trace
+}
+
+// LifetimeStats store the lifetime statistics of a category
+type LifetimeStats struct {
+	CreateCount                int         // Number of create events, including the rewrites of deleted keys
+	UpdateCount                int         // Number of update events
+	DeleteCount                int         // Number of delete events
+	DeleteOfTraceCreatedCount  int         // Number of deletes whose key is created in the trace
+	DeleteOfUnknownCreateCount int         // Number of deletes whose key is created before the trace starts
+	TotalLifetime              uint64      // Sum of lifetimes (in blocks) of deleted keys created in the trace
+	TotalOverwriteInterval     uint64      // Sum of overwrite intervals (in blocks), from an update to the next one
+	OverwriteIntervalCount     int         // Number of overwrite intervals
+	TotalFirstUpdateInterval   uint64      // Sum of intervals (in blocks) from a create in the trace to the first update
+	FirstUpdateIntervalCount   int         // Number of first update intervals
+	TotalUpdatesBeforeDelete   int         // Sum of update counts of deleted keys created in the trace
+	LifetimeHistogram          map[int]int // Histogram of lifetimes, key is the lifetime in blocks and value is the count
+	OverwriteIntervalHistogram map[int]int // Histogram of overwrite intervals, key is the interval in blocks and value is the count
+	FirstUpdateHistogram       map[int]int // Histogram of first update intervals, key is the interval in blocks and value is the count
+	UpdatesBeforeDeleteHist    map[int]int // Histogram of update counts before deletion, key is the update count and value is the count
+}
+
+// LifetimeRecord is a record of keyLifetime-<start>_<end>.{json,csv}, the averages are in blocks
+type LifetimeRecord struct {
+	Category                   string  `json:"category"`
+	CreateCount                int     `json:"create_count"`
+	UpdateCount                int     `json:"update_count"`
+	DeleteCount                int     `json:"delete_count"`
+	DeleteCreatedInTrace       int     `json:"delete_created_in_trace"`
+	DeleteCreatedBeforeTrace   int     `json:"delete_created_before_trace"`
+	AliveKeys                  int     `json:"alive_keys"`
+	AverageLifetime            float64 `json:"average_lifetime"`
+	AverageOverwriteInterval   float64 `json:"average_overwrite_interval"`
+	AverageFirstUpdateInterval float64 `json:"average_first_update_interval"`
+	AverageUpdatesBeforeDelete float64 `json:"average_updates_before_delete"`
+}
+
+// HistogramRecord is a record of the lifetime-<start>_<end>_<category>_*_histogram.{json,csv} files
+type HistogramRecord struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+var (
+	outputFormat  = "text" // One of text, json, and csv
+	lifetimeStats = make(map[string]*LifetimeStats)
+	keyStates     = make(map[string]*KeyState)
+	hexPrefixes   = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+)`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+// deletedKeys are the keys deleted in the trace and not written since. filterUpdate never forgets a key, so their next
+// write is an Update, which creates the key again
+var deletedKeys = make(map[string]struct{})
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func getLifetimeStats(category string) *LifetimeStats {
+	if _, exists := lifetimeStats[category]; !exists {
+		lifetimeStats[category] = &LifetimeStats{
+			LifetimeHistogram:          make(map[int]int),
+			OverwriteIntervalHistogram: make(map[int]int),
+			FirstUpdateHistogram:       make(map[int]int),
+			UpdatesBeforeDeleteHist:    make(map[int]int),
+		}
+	}
+	return lifetimeStats[category]
+}
+
+// processOperation updates the key state and the category statistics for a single write or delete
+func processOperation(opType, hexKey string, blockID uint64) {
+	switch opType {
+	case "Put", "BatchPut", "Update", "Delete", "BatchDelete":
+	default:
+		return
+	}
+	rawKey, err := hex.DecodeString(hexKey)
+	if err != nil {
+		fmt.Println("Error decoding hex key:", err)
+		return
+	}
+	key := string(rawKey)
+	category := matchPrefix(hexKey)
+	ls := getLifetimeStats(category)
+
+	switch opType {
+	case "Put", "BatchPut":
+		// filterUpdate keeps the first write of a key as Put/BatchPut, so this is a create event
+		createKey(ls, key, blockID)
+	case "Update":
+		state, exists := keyStates[key]
+		if !exists {
+			if _, deleted := deletedKeys[key]; deleted {
+				// The key is written again after its delete in the trace
+				createKey(ls, key, blockID)
+				return
+			}
+			// The key is created before the trace starts
+			ls.UpdateCount++
+			keyStates[key] = &KeyState{
+				LastWriteBlock: blockID,
+				UpdateCount:    1,
+			}
+			return
+		}
+		ls.UpdateCount++
+		interval := blockID - state.LastWriteBlock
+		if state.UpdateCount == 0 {
+			// The first update after a create in the trace is not an overwrite of an update
+			ls.TotalFirstUpdateInterval += interval
+			ls.FirstUpdateIntervalCount++
+			ls.FirstUpdateHistogram[int(interval)]++
+		} else {
+			ls.TotalOverwriteInterval += interval
+			ls.OverwriteIntervalCount++
+			ls.OverwriteIntervalHistogram[int(interval)]++
+		}
+		state.LastWriteBlock = blockID
+		state.UpdateCount++
+	case "Delete", "BatchDelete":
+		ls.DeleteCount++
+		deletedKeys[key] = struct{}{}
+		state, exists := keyStates[key]
+		if !exists || !state.CreatedInTrace {
+			ls.DeleteOfUnknownCreateCount++
+			delete(keyStates, key)
+			return
+		}
+		lifetime := blockID - state.CreateBlock
+		ls.DeleteOfTraceCreatedCount++
+		ls.TotalLifetime += lifetime
+		ls.LifetimeHistogram[int(lifetime)]++
+		ls.TotalUpdatesBeforeDelete += state.UpdateCount
+		ls.UpdatesBeforeDeleteHist[state.UpdateCount]++
+		delete(keyStates, key)
+	}
+}
+
+// createKey starts the lifetime of a key created in the trace
+func createKey(ls *LifetimeStats, key string, blockID uint64) {
+	ls.CreateCount++
+	delete(deletedKeys, key)
+	keyStates[key] = &KeyState{
+		CreateBlock:    blockID,
+		LastWriteBlock: blockID,
+		CreatedInTrace: true,
+	}
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var currentBlockID uint64
+	var lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			return
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, tracked keys: %d, elapsed time: %.2fs", lineCount, currentBlockID, len(keyStates), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		processOperation(matches[1], matches[2], currentBlockID)
+	}
+}
+
+// PrintSortedHistogram writes the histogram in ascending order of the bucket, without filling the gaps
+func PrintSortedHistogram(fileName, columnName string, histogram map[int]int) {
+	buckets := make([]int, 0, len(histogram))
+	for bucket := range histogram {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	if outputFormat != "text" {
+		records := make([]HistogramRecord, 0, len(buckets))
+		for _, bucket := range buckets {
+			records = append(records, HistogramRecord{Value: bucket, Count: histogram[bucket]})
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	outputFile, err := os.Create(fileName)
+	if err != nil {
+		fmt.Printf("Cannot open the output file %s: %v\n", fileName, err)
+		return
+	}
+	defer outputFile.Close()
+	fmt.Fprintf(outputFile, "%s\tcount\n", columnName)
+	for _, bucket := range buckets {
+		fmt.Fprintf(outputFile, "%d\t%d\n", bucket, histogram[bucket])
+	}
+}
+
+func average(total uint64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}
+
+// lifetimeRecords returns the lifetime statistics of the categories in ascending order
+func lifetimeRecords() []LifetimeRecord {
+	// Keys that are still alive at the end of the trace, counted per category
+	aliveCount := make(map[string]int)
+	for key := range keyStates {
+		aliveCount[matchPrefix(hex.EncodeToString([]byte(key)))]++
+	}
+
+	categories := make([]string, 0, len(lifetimeStats))
+	for category := range lifetimeStats {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	records := make([]LifetimeRecord, 0, len(categories))
+	for _, category := range categories {
+		ls := lifetimeStats[category]
+		records = append(records, LifetimeRecord{
+			Category:                   category,
+			CreateCount:                ls.CreateCount,
+			UpdateCount:                ls.UpdateCount,
+			DeleteCount:                ls.DeleteCount,
+			DeleteCreatedInTrace:       ls.DeleteOfTraceCreatedCount,
+			DeleteCreatedBeforeTrace:   ls.DeleteOfUnknownCreateCount,
+			AliveKeys:                  aliveCount[category],
+			AverageLifetime:            average(ls.TotalLifetime, ls.DeleteOfTraceCreatedCount),
+			AverageOverwriteInterval:   average(ls.TotalOverwriteInterval, ls.OverwriteIntervalCount),
+			AverageFirstUpdateInterval: average(ls.TotalFirstUpdateInterval, ls.FirstUpdateIntervalCount),
+			AverageUpdatesBeforeDelete: average(uint64(ls.TotalUpdatesBeforeDelete), ls.DeleteOfTraceCreatedCount),
+		})
+	}
+	return records
+}
+
+func printStats(outputFile *os.File, records []LifetimeRecord) {
+	fmt.Fprintln(outputFile, "Key lifetime of KV operations:")
+	for _, r := range records {
+		fmt.Fprintf(outputFile, "Category: %s\n", r.Category)
+		fmt.Fprintf(outputFile, "  Create count: %d\n", r.CreateCount)
+		fmt.Fprintf(outputFile, "  Update count: %d\n", r.UpdateCount)
+		fmt.Fprintf(outputFile, "  Delete count: %d\n", r.DeleteCount)
+		fmt.Fprintf(outputFile, "  Delete count (created in trace): %d\n", r.DeleteCreatedInTrace)
+		fmt.Fprintf(outputFile, "  Delete count (created before trace): %d\n", r.DeleteCreatedBeforeTrace)
+		fmt.Fprintf(outputFile, "  Alive keys at the end of trace: %d\n", r.AliveKeys)
+		fmt.Fprintf(outputFile, "  Average lifetime (blocks): %.2f\n", r.AverageLifetime)
+		fmt.Fprintf(outputFile, "  Average overwrite interval (blocks): %.2f\n", r.AverageOverwriteInterval)
+		fmt.Fprintf(outputFile, "  Average first update interval (blocks): %.2f\n", r.AverageFirstUpdateInterval)
+		fmt.Fprintf(outputFile, "  Average update count before deletion: %.2f\n", r.AverageUpdatesBeforeDelete)
+	}
+}
+
+// printHistograms writes the histograms of the categories
+func printHistograms(filePrefix string) {
+	for category, ls := range lifetimeStats {
+		if len(ls.LifetimeHistogram) > 0 {
+			PrintSortedHistogram(filePrefix+category+"_lifetime_histogram.txt", "blocks", ls.LifetimeHistogram)
+		}
+		if len(ls.OverwriteIntervalHistogram) > 0 {
+			PrintSortedHistogram(filePrefix+category+"_overwrite_interval_histogram.txt", "blocks", ls.OverwriteIntervalHistogram)
+		}
+		if len(ls.FirstUpdateHistogram) > 0 {
+			PrintSortedHistogram(filePrefix+category+"_first_update_interval_histogram.txt", "blocks", ls.FirstUpdateHistogram)
+		}
+		if len(ls.UpdatesBeforeDeleteHist) > 0 {
+			PrintSortedHistogram(filePrefix+category+"_update_count_histogram.txt", "updates", ls.UpdatesBeforeDeleteHist)
+		}
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 4 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <update_enhanced_log_file_path> <print_progress_interval> <start_block_number> <end_block_number>")
+		return
+	}
+	outputFormat = *format
+	logFilePath := flag.Arg(0)
+	progressInterval, _ := strconv.ParseUint(flag.Arg(1), 10, 64)
+	startBlockNumber, _ := strconv.ParseUint(flag.Arg(2), 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(flag.Arg(3), 10, 64)
+	if progressInterval == 0 {
+		progressInterval = 1000
+	}
+
+	processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber)
+
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	outPutLogPath := "keyLifetime-" + rangeName + ".txt"
+	printHistograms("lifetime-" + rangeName + "_")
+	if outputFormat != "text" {
+		if err := writeRecords(outputPath(outPutLogPath, outputFormat), outputFormat, lifetimeRecords()); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	printStats(file, lifetimeRecords())
+	fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+}
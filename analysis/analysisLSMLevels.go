@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/cockroachdb/pebble"
+)
+
+var prefixes = [][]byte{
+	// databaseVersionKey tracks the current database version.
+	[]byte("DatabaseVersion"),
+
+	// headHeaderKey tracks the latest known header's hash.
+	[]byte("LastHeader"),
+
+	// headBlockKey tracks the latest known full block's hash.
+	[]byte("LastBlock"),
+
+	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
+	[]byte("LastFast"),
+
+	// headFinalizedBlockKey tracks the latest known finalized block hash.
+	[]byte("LastFinalized"),
+
+	// persistentStateIDKey tracks the id of latest stored state (for path-based only).
+	[]byte("LastStateID"),
+
+	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
+	[]byte("LastPivot"),
+
+	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
+	[]byte("TrieSync"),
+
+	// snapshotDisabledKey flags that the snapshot should not be maintained due to initial sync.
+	[]byte("SnapshotDisabled"),
+
+	// SnapshotRootKey tracks the hash of the last snapshot.
+	[]byte("SnapshotRoot"),
+
+	// snapshotJournalKey tracks the in-memory diff layers across restarts.
+	[]byte("SnapshotJournal"),
+
+	// snapshotGeneratorKey tracks the snapshot generation marker across restarts.
+	[]byte("SnapshotGenerator"),
+
+	// snapshotRecoveryKey tracks the snapshot recovery marker across restarts.
+	[]byte("SnapshotRecovery"),
+
+	// snapshotSyncStatusKey tracks the snapshot sync status across restarts.
+	[]byte("SnapshotSyncStatus"),
+
+	// skeletonSyncStatusKey tracks the skeleton sync status across restarts.
+	[]byte("SkeletonSyncStatus"),
+
+	// trieJournalKey tracks the in-memory trie node layers across restarts.
+	[]byte("TrieJournal"),
+
+	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
+	[]byte("TransactionIndexTail"),
+
+	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
+	[]byte("FastTransactionLookupLimit"),
+
+	// badBlockKey tracks the list of bad blocks seen by local.
+	[]byte("InvalidBlock"),
+
+	// uncleanShutdownKey tracks the list of local crashes.
+	[]byte("unclean-shutdown"), // config prefix for the db
+
+	// transitionStatusKey tracks the eth2 transition status.
+	[]byte("eth2-transition"),
+
+	// snapSyncStatusFlagKey flags that status of snap sync.
+	[]byte("SnapSyncStatus"),
+
+	// headerPrefix is used for header data storage.
+	[]byte("h"),
+
+	// headerTDSuffix is used for total difficulty storage.
+	[]byte("t"),
+
+	// headerHashSuffix is used for header hash storage.
+	[]byte("n"),
+
+	// headerNumberPrefix is used for header number storage.
+	[]byte("H"),
+
+	// blockBodyPrefix is used for block body storage.
+	[]byte("b"),
+
+	// blockReceiptsPrefix is used for block receipts storage.
+	[]byte("r"),
+
+	// txLookupPrefix is used for transaction lookup.
+	[]byte("l"),
+
+	// bloomBitsPrefix is used for bloom filter bits storage.
+	[]byte("B"),
+
+	// SnapshotAccountPrefix is used for snapshot account storage.
+	[]byte("a"),
+
+	// SnapshotStoragePrefix is used for snapshot storage trie values.
+	[]byte("o"),
+
+	// CodePrefix is used for account code storage.
+	[]byte("c"),
+
+	// skeletonHeaderPrefix is used for skeleton header storage.
+	[]byte("S"),
+
+	// TrieNodeAccountPrefix is used for trie node account storage.
+	[]byte("A"),
+
+	// TrieNodeStoragePrefix is used for trie node storage paths.
+	[]byte("O"),
+
+	// stateIDPrefix is used for state ID storage.
+	[]byte("L"),
+
+	// VerklePrefix is used for Verkle trie data storage.
+	[]byte("v"),
+
+	// PreimagePrefix is used for preimage data.
+	[]byte("secure-key-"),
+
+	// configPrefix is used for database configuration.
+	[]byte("ethereum-config-"),
+
+	// genesisPrefix is used for genesis state storage.
+	[]byte("ethereum-genesis-"),
+
+	// BloomBitsIndexPrefix is used for chain indexer progress tracking.
+	[]byte("iB"),
+
+	// ChtPrefix is used for CHT root storage.
+	[]byte("chtRootV2-"),
+
+	// ChtTablePrefix is used for CHT table storage.
+	[]byte("cht-"),
+
+	// ChtIndexTablePrefix is used for CHT index table storage.
+	[]byte("chtIndexV2-"),
+
+	// BloomTriePrefix is used for bloom trie root storage.
+	[]byte("bltRoot-"),
+
+	// BloomTrieTablePrefix is used for bloom trie table storage.
+	[]byte("blt-"),
+
+	// BloomTrieIndexPrefix is used for bloom trie index storage.
+	[]byte("bltIndex-"),
+
+	// CliqueSnapshotPrefix is used for clique consensus snapshot storage.
+	[]byte("clique-"),
+
+	// BestUpdateKey is used for LightClientUpdate storage.
+	[]byte("update-"),
+
+	// FixedCommitteeRootKey is used for fixed committee root hash storage.
+	[]byte("fixedRoot-"),
+
+	// SyncCommitteeKey is used for serialized committee storage.
+	[]byte("committee-"),
+}
+
+// noPrefix is the data type of the keys without a known prefix
+const noPrefix = "noPrefix"
+
+func Equal(a, b []byte) bool {
+	return string(a) == string(b)
+}
+
+func HasPrefix(s, prefix []byte) bool {
+	return len(s) >= len(prefix) && Equal(s[0:len(prefix)], prefix)
+}
+
+func matchesPrefix(key []byte) ([]byte, bool) {
+	for _, prefix := range prefixes {
+		if HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return nil, false
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the prefix, nil if there is none
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// categoryRange is a key range [Start, End) whose keys all belong to the same data type
+type categoryRange struct {
+	Category string
+	Start    []byte
+	End      []byte
+}
+
+// categoryRanges splits the key space at the bounds of all prefixes. No prefix starts or ends within a range, so the
+// first matching prefix of its start is the data type of the whole range. The adjacent ranges of the same data type
+// are merged, and lastKey bounds the last range
+func categoryRanges(lastKey []byte) []categoryRange {
+	var cuts [][]byte
+	for _, prefix := range prefixes {
+		cuts = append(cuts, prefix)
+		if end := prefixUpperBound(prefix); end != nil {
+			cuts = append(cuts, end)
+		}
+	}
+	sort.Slice(cuts, func(i, j int) bool {
+		return bytes.Compare(cuts[i], cuts[j]) < 0
+	})
+
+	ranges := []categoryRange{{Category: noPrefix, Start: []byte{}}}
+	for _, cut := range cuts {
+		if bytes.Compare(cut, lastKey) >= 0 {
+			break
+		}
+		category := noPrefix
+		if prefix, matched := matchesPrefix(cut); matched {
+			category = string(prefix)
+		}
+		last := &ranges[len(ranges)-1]
+		if bytes.Equal(cut, last.Start) || category == last.Category {
+			continue
+		}
+		last.End = cut
+		ranges = append(ranges, categoryRange{Category: category, Start: cut})
+	}
+	ranges[len(ranges)-1].End = lastKey
+	return ranges
+}
+
+// tableKey identifies an SSTable
+type tableKey struct {
+	Level   int
+	FileNum uint64
+}
+
+// LevelCategory is the SSTable footprint of a data type in a level
+type LevelCategory struct {
+	Files int    // SSTables overlapping the data type
+	Bytes uint64 // Estimated bytes of the data type
+}
+
+// analysis is the placement of the data types in the LSM levels
+type analysis struct {
+	levelFiles      []int
+	levelBytes      []uint64
+	matrix          map[string][]LevelCategory     // Data type -> level -> footprint
+	tableSizes      map[tableKey]uint64            // Size of each SSTable
+	tableCategories map[tableKey]map[string]uint64 // Estimated bytes of each data type in each SSTable
+}
+
+func newAnalysis(levels [][]pebble.SSTableInfo) *analysis {
+	a := &analysis{
+		levelFiles:      make([]int, len(levels)),
+		levelBytes:      make([]uint64, len(levels)),
+		matrix:          make(map[string][]LevelCategory),
+		tableSizes:      make(map[tableKey]uint64),
+		tableCategories: make(map[tableKey]map[string]uint64),
+	}
+	for level, tables := range levels {
+		for _, table := range tables {
+			a.levelFiles[level]++
+			a.levelBytes[level] += table.Size
+			a.tableSizes[tableKey{Level: level, FileNum: uint64(table.FileNum)}] = table.Size
+		}
+	}
+	return a
+}
+
+// attribute estimates the bytes of each SSTable in each category range, i.e., the whole table if it is contained in
+// the range, or the overlapping data blocks otherwise. The ranges without live keys are skipped, since a table
+// overlapping them would still be attributed a data block
+func (a *analysis) attribute(db *pebble.DB, ranges []categoryRange) error {
+	for i, r := range ranges {
+		if i%10 == 0 {
+			fmt.Printf("\rAttributing key ranges: %d/%d", i, len(ranges))
+		}
+		iter, err := db.NewIter(&pebble.IterOptions{LowerBound: r.Start, UpperBound: r.End})
+		if err != nil {
+			return fmt.Errorf("failed to create iterator: %v", err)
+		}
+		empty := !iter.First()
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		if empty {
+			continue
+		}
+		levels, err := db.SSTables(pebble.WithProperties(), pebble.WithKeyRangeFilter(r.Start, r.End), pebble.WithApproximateSpanBytes())
+		if err != nil {
+			return fmt.Errorf("failed to list the SSTables of %q: %v", r.Start, err)
+		}
+		for level, tables := range levels {
+			for _, table := range tables {
+				spanBytes, err := strconv.ParseUint(table.Properties.UserProperties["approximate-span-bytes"], 10, 64)
+				if err != nil || spanBytes == 0 {
+					continue
+				}
+				key := tableKey{Level: level, FileNum: uint64(table.FileNum)}
+				if _, exists := a.tableCategories[key]; !exists {
+					a.tableCategories[key] = make(map[string]uint64)
+				}
+				a.tableCategories[key][r.Category] += spanBytes
+			}
+		}
+	}
+	fmt.Printf("\rAttributing key ranges: %d/%d\n", len(ranges), len(ranges))
+
+	for key, categories := range a.tableCategories {
+		for category, spanBytes := range categories {
+			if _, exists := a.matrix[category]; !exists {
+				a.matrix[category] = make([]LevelCategory, len(a.levelFiles))
+			}
+			a.matrix[category][key.Level].Files++
+			a.matrix[category][key.Level].Bytes += spanBytes
+		}
+	}
+	return nil
+}
+
+// categories returns the data types sorted by their bytes in descending order
+func (a *analysis) categories() []string {
+	totals := make(map[string]uint64)
+	var categories []string
+	for category, levels := range a.matrix {
+		categories = append(categories, category)
+		for _, lc := range levels {
+			totals[category] += lc.Bytes
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if totals[categories[i]] != totals[categories[j]] {
+			return totals[categories[i]] > totals[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// MixRecord is a record of lsmLevels-mixing.{json,csv}, the SSTables of a level mixing the given number of data types
+type MixRecord struct {
+	Level      int    `json:"level"`
+	Categories int    `json:"categories"`
+	Files      int    `json:"files"`
+	Bytes      uint64 `json:"bytes"`
+}
+
+// mixRecords counts the SSTables of each level by the number of data types they mix
+func (a *analysis) mixRecords() []MixRecord {
+	type mixKey struct {
+		level      int
+		categories int
+	}
+	mixes := make(map[mixKey]*MixRecord)
+	for key, size := range a.tableSizes {
+		mk := mixKey{level: key.Level, categories: len(a.tableCategories[key])}
+		if _, exists := mixes[mk]; !exists {
+			mixes[mk] = &MixRecord{Level: mk.level, Categories: mk.categories}
+		}
+		mixes[mk].Files++
+		mixes[mk].Bytes += size
+	}
+	var records []MixRecord
+	for _, record := range mixes {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Level != records[j].Level {
+			return records[i].Level < records[j].Level
+		}
+		return records[i].Categories < records[j].Categories
+	})
+	return records
+}
+
+// LevelCategoryRecord is a record of lsmLevels.{json,csv}, the shares are of the bytes of the level and of the data type
+type LevelCategoryRecord struct {
+	Level           int     `json:"level"`
+	Category        string  `json:"category"`
+	Files           int     `json:"files"`
+	Bytes           uint64  `json:"bytes"`
+	ShareOfLevel    float64 `json:"share_of_level"`
+	ShareOfCategory float64 `json:"share_of_category"`
+}
+
+func (a *analysis) levelCategoryRecords() []LevelCategoryRecord {
+	var records []LevelCategoryRecord
+	for _, category := range a.categories() {
+		total := uint64(0)
+		for _, lc := range a.matrix[category] {
+			total += lc.Bytes
+		}
+		for level, lc := range a.matrix[category] {
+			if lc.Files == 0 {
+				continue
+			}
+			record := LevelCategoryRecord{Level: level, Category: category, Files: lc.Files, Bytes: lc.Bytes, ShareOfLevel: math.NaN(), ShareOfCategory: math.NaN()}
+			if a.levelBytes[level] > 0 {
+				record.ShareOfLevel = float64(lc.Bytes) / float64(a.levelBytes[level])
+			}
+			if total > 0 {
+				record.ShareOfCategory = float64(lc.Bytes) / float64(total)
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// writeMatrixCSV writes a category x level matrix, with the total of each category in the last column
+func (a *analysis) writeMatrixCSV(fileName string, value func(LevelCategory) uint64) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprint(writer, "category")
+	for level := range a.levelFiles {
+		fmt.Fprintf(writer, ",L%d", level)
+	}
+	fmt.Fprintln(writer, ",total")
+	for _, category := range a.categories() {
+		fmt.Fprint(writer, category)
+		total := uint64(0)
+		for _, lc := range a.matrix[category] {
+			fmt.Fprintf(writer, ",%d", value(lc))
+			total += value(lc)
+		}
+		fmt.Fprintf(writer, ",%d\n", total)
+	}
+	return writer.Flush()
+}
+
+// printSummary writes the levels, the levels of each data type, and the mixing of the SSTables
+func (a *analysis) printSummary(outputFilePath string) error {
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+
+	totalBytes := uint64(0)
+	for _, bytes := range a.levelBytes {
+		totalBytes += bytes
+	}
+	for level := range a.levelFiles {
+		share := 0.0
+		if totalBytes > 0 {
+			share = float64(a.levelBytes[level]) / float64(totalBytes) * 100
+		}
+		fmt.Fprintf(outputFile, "Level L%d: files: %d, bytes: %d (%.2f%%)\n", level, a.levelFiles[level], a.levelBytes[level], share)
+	}
+	fmt.Fprintln(outputFile)
+
+	records := a.levelCategoryRecords()
+	for i, record := range records {
+		if i == 0 || records[i-1].Category != record.Category {
+			fmt.Fprintf(outputFile, "Category: %s\n", record.Category)
+		}
+		fmt.Fprintf(outputFile, "  L%d: files: %d, bytes: %d, share of the category: %.2f%%, share of the level: %.2f%%\n",
+			record.Level, record.Files, record.Bytes, record.ShareOfCategory*100, record.ShareOfLevel*100)
+	}
+	fmt.Fprintln(outputFile)
+
+	fmt.Fprintln(outputFile, "Categories per SSTable:")
+	for _, record := range a.mixRecords() {
+		fmt.Fprintf(outputFile, "  L%d: %d categories: files: %d, bytes: %d\n", record.Level, record.Categories, record.Files, record.Bytes)
+	}
+	return nil
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 1 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <pebble_database_path>")
+		return
+	}
+	db, err := pebble.Open(flag.Arg(0), &pebble.Options{ReadOnly: true})
+	if err != nil {
+		log.Fatalf("Cannot open target database, err: %v\n", err)
+	}
+	defer db.Close()
+
+	const outputFilePath = "lsmLevels.txt"
+	const mixingFilePath = "lsmLevels-mixing.txt"
+
+	levels, err := db.SSTables()
+	if err != nil {
+		log.Fatalf("Failed to list the SSTables: %v", err)
+	}
+	// lastKey is greater than all keys in the SSTables
+	lastKey := []byte{0xff}
+	for _, tables := range levels {
+		for _, table := range tables {
+			if bytes.Compare(table.Largest.UserKey, lastKey) >= 0 {
+				lastKey = append(append([]byte{}, table.Largest.UserKey...), 0)
+			}
+		}
+	}
+	a := newAnalysis(levels)
+	if err := a.attribute(db, categoryRanges(lastKey)); err != nil {
+		log.Fatal(err)
+	}
+
+	// The matrices are always written as CSV
+	if err := a.writeMatrixCSV("lsmLevels-bytes.csv", func(lc LevelCategory) uint64 { return lc.Bytes }); err != nil {
+		log.Fatal(err)
+	}
+	if err := a.writeMatrixCSV("lsmLevels-files.csv", func(lc LevelCategory) uint64 { return uint64(lc.Files) }); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Matrices are stored to: lsmLevels-bytes.csv, lsmLevels-files.csv")
+
+	if *format != "text" {
+		if err := writeRecords(outputPath(outputFilePath, *format), *format, a.levelCategoryRecords()); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath(mixingFilePath, *format), *format, a.mixRecords()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := a.printSummary(outputFilePath); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Statistics are stored to: %s\n", outputFilePath)
+}
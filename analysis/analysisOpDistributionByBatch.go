@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -31,6 +33,37 @@ type OperationStats struct {
 	OpTypeCount map[string]int
 }
 
+// OpCountRecord is a record of countKVDist-<start>_<end>.{json,csv}
+type OpCountRecord struct {
+	Category string `json:"category"`
+	OpType   string `json:"op_type"`
+	Count    int    `json:"count"`
+}
+
+// KeyCountRecord is a record of distribution-<start>_<end>_<category>_<op>_dis.{json,csv}
+type KeyCountRecord struct {
+	ID    int    `json:"id"`
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// TopKRecord is a record of stream-<start>_<end>_<category>_<op>_topk.{json,csv}, the real count is in [count-error, count]
+type TopKRecord struct {
+	ID    int    `json:"id"`
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+	Error uint64 `json:"error"`
+}
+
+// HeavyHitterRecord is a record of countKVDist-heavyHitters-<start>_<end>.{json,csv}
+type HeavyHitterRecord struct {
+	Category string `json:"category"`
+	OpType   string `json:"op_type"`
+	Total    uint64 `json:"total"`
+	MaxError uint64 `json:"max_error"`
+	Capacity int    `json:"capacity"`
+}
+
 type OperationDistribution struct {
 	GetOpDistributionCount            map[string]int
 	UpdateOpDistributionCount         map[string]int
@@ -42,6 +75,7 @@ type OperationDistribution struct {
 var (
 	stats          = make(map[string]*OperationStats)
 	opDistribution = make(map[string]*OperationDistribution)
+	outputFormat   = "text" // One of text, json, and csv
 	hexPrefixes    = []PrefixCategory{
 		{"7365637572652d6b65792d", "PreimagePrefix"},
 		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
@@ -225,12 +259,14 @@ func processLogFile(filePath string, progressInterval uint64, startBlockNumber,
 		var lineCount uint64
 		start := time.Now()
 		lineCount = 0
+		reachedEOF := false
 
 		for {
 			line, err := reader.ReadString('\n') // Read until newline
 			if err != nil {
 				if err == io.EOF {
 					fmt.Println("End of file reached")
+					reachedEOF = true
 					break
 				}
 				fmt.Println("Error reading file:", err)
@@ -299,18 +335,25 @@ func processLogFile(filePath string, progressInterval uint64, startBlockNumber,
 		outPutLogPath := "countKVDist-" + strconv.FormatUint(currentStartBlockNumber, 10) + "_" + strconv.FormatUint(currentEndBlockNumber, 10) + ".txt"
 		filePrefix := "distribution-" + strconv.FormatUint(currentStartBlockNumber, 10) + "_" + strconv.FormatUint(currentEndBlockNumber, 10) + "_"
 		fmt.Printf("\rProcessed a total of %d lines, write results into %s.\n", lineCount, outPutLogPath)
-		file, err := os.Create(outPutLogPath)
-		if err != nil {
-			fmt.Println("Error creating output file:", outPutLogPath)
-			return
+		if outputFormat == "text" {
+			file, err := os.Create(outPutLogPath)
+			if err != nil {
+				fmt.Println("Error creating output file:", outPutLogPath)
+				return
+			}
+			printStats(file, filePrefix)
+			file.Close()
+		} else {
+			printStructuredStats(outputPath(outPutLogPath, outputFormat), filePrefix)
 		}
-		defer file.Close()
-		printStats(file, filePrefix)
 		currentEndBlockNumber += stepSize
 		currentStartBlockNumber += stepSize
 		// Reset stats
 		stats = make(map[string]*OperationStats)
 		opDistribution = make(map[string]*OperationDistribution)
+		if reachedEOF || currentStartBlockNumber > endBlockNumber {
+			break
+		}
 	}
 }
 
@@ -334,6 +377,16 @@ func printDistributionStats(opMap map[string]int, category string, opType OPType
 	})
 
 	fileName := filePrefix + category + "_" + toString(opType) + "_dis.txt"
+	if outputFormat != "text" {
+		records := make([]KeyCountRecord, len(sortedOps))
+		for id, entry := range sortedOps {
+			records[id] = KeyCountRecord{ID: id + 1, Key: entry.Key, Count: entry.Count}
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		return
+	}
 	file, err := os.Create(fileName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating output file: %s\n", fileName)
@@ -347,6 +400,32 @@ func printDistributionStats(opMap map[string]int, category string, opType OPType
 	}
 }
 
+// opCountRecords returns the operation counts sorted by category and operation type
+func opCountRecords() []OpCountRecord {
+	var records []OpCountRecord
+	for category, opStats := range stats {
+		for opType, count := range opStats.OpTypeCount {
+			records = append(records, OpCountRecord{Category: category, OpType: opType, Count: count})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Category != records[j].Category {
+			return records[i].Category < records[j].Category
+		}
+		return records[i].OpType < records[j].OpType
+	})
+	return records
+}
+
+// printStructuredStats writes the operation counts in the output format, and the distributions
+func printStructuredStats(outputFilePath string, filePrefix string) {
+	if err := writeRecords(outputFilePath, outputFormat, opCountRecords()); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	printDistributions(filePrefix)
+}
+
 func printStats(outputFile *os.File, filePrefix string) {
 	fmt.Fprintln(outputFile, "Count of KV operations:")
 	for category, opStats := range stats {
@@ -356,6 +435,10 @@ func printStats(outputFile *os.File, filePrefix string) {
 		}
 	}
 	fmt.Fprintln(outputFile, "\n\nDistribution of KV operations:")
+	printDistributions(filePrefix)
+}
+
+func printDistributions(filePrefix string) {
 	for category, opDist := range opDistribution {
 		fmt.Println("Category:", category)
 		if len(opDist.GetOpDistributionCount) > 1 {
@@ -381,15 +464,305 @@ func printStats(outputFile *os.File, filePrefix string) {
 	}
 }
 
+// SpaceSavingEntry is a monitored key of the Space-Saving sketch
+type SpaceSavingEntry struct {
+	Key   string
+	Count uint64 // Estimated count (never smaller than the real count)
+	Error uint64 // Maximum overestimation of the count
+	index int    // Index of the entry in the min-heap
+}
+
+// SpaceSavingHeap is a min-heap of the monitored keys ordered by the estimated count
+type SpaceSavingHeap []*SpaceSavingEntry
+
+func (h SpaceSavingHeap) Len() int           { return len(h) }
+func (h SpaceSavingHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h SpaceSavingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *SpaceSavingHeap) Push(x any) {
+	entry := x.(*SpaceSavingEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *SpaceSavingHeap) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
+
+// SpaceSaving tracks the heavy hitters of a stream with at most Capacity monitored keys.
+// Every estimated count overestimates the real count by at most Total/Capacity.
+type SpaceSaving struct {
+	Capacity int
+	Total    uint64 // Number of items seen in the stream
+	entries  map[string]*SpaceSavingEntry
+	minHeap  SpaceSavingHeap
+}
+
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	return &SpaceSaving{
+		Capacity: capacity,
+		entries:  make(map[string]*SpaceSavingEntry, capacity),
+		minHeap:  make(SpaceSavingHeap, 0, capacity),
+	}
+}
+
+// Add counts one occurrence of the key
+func (ss *SpaceSaving) Add(key string) {
+	ss.Total++
+	if entry, exists := ss.entries[key]; exists {
+		entry.Count++
+		heap.Fix(&ss.minHeap, entry.index)
+		return
+	}
+	if len(ss.minHeap) < ss.Capacity {
+		entry := &SpaceSavingEntry{Key: key, Count: 1}
+		heap.Push(&ss.minHeap, entry)
+		ss.entries[key] = entry
+		return
+	}
+	// Replace the key with the minimum count, and inherit its count as the error
+	entry := ss.minHeap[0]
+	delete(ss.entries, entry.Key)
+	entry.Key = key
+	entry.Error = entry.Count
+	entry.Count++
+	ss.entries[key] = entry
+	heap.Fix(&ss.minHeap, 0)
+}
+
+// ErrorBound returns the maximum overestimation of any reported count
+func (ss *SpaceSaving) ErrorBound() uint64 {
+	if len(ss.minHeap) < ss.Capacity {
+		return 0
+	}
+	return ss.minHeap[0].Count
+}
+
+// TopK returns the (at most) k monitored keys with the largest estimated counts
+func (ss *SpaceSaving) TopK(k int) []SpaceSavingEntry {
+	sorted := make([]SpaceSavingEntry, 0, len(ss.minHeap))
+	for _, entry := range ss.minHeap {
+		sorted = append(sorted, *entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	if len(sorted) > k {
+		sorted = sorted[:k]
+	}
+	return sorted
+}
+
+// processLogFileStreaming counts the operations of the whole block range in one pass, keeping
+// only the heavy hitters of each category and operation type in a Space-Saving sketch
+func processLogFileStreaming(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64, capacity, topK int) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	sketches := make(map[string]map[OPType]*SpaceSaving)
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	blockRegex := regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			return
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		opType, category, key, parsed := parseLogLine(line)
+		if !parsed {
+			continue
+		}
+		if _, exists := stats[category]; !exists {
+			stats[category] = &OperationStats{OpTypeCount: make(map[string]int)}
+		}
+		stats[category].OpTypeCount[opType]++
+
+		var op OPType
+		switch opType {
+		case "Get":
+			op = GET
+		case "BatchPut":
+			op = BATCHED_PUT
+		case "Put":
+			op = PUT
+		case "BatchDelete":
+			op = DELETE
+		case "NewIterator":
+			op = SCAN
+		default:
+			continue
+		}
+		if _, exists := sketches[category]; !exists {
+			sketches[category] = make(map[OPType]*SpaceSaving)
+		}
+		if _, exists := sketches[category][op]; !exists {
+			sketches[category][op] = NewSpaceSaving(capacity)
+		}
+		sketches[category][op].Add(key)
+	}
+
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	outPutLogPath := "countKVDist-" + rangeName + ".txt"
+	if outputFormat != "text" {
+		if err := writeRecords(outputPath(outPutLogPath, outputFormat), outputFormat, opCountRecords()); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		var heavyHitters []HeavyHitterRecord
+		for category, opSketches := range sketches {
+			for op, sketch := range opSketches {
+				heavyHitters = append(heavyHitters, HeavyHitterRecord{Category: category, OpType: toString(op), Total: sketch.Total, MaxError: sketch.ErrorBound(), Capacity: capacity})
+				printTopKStats(sketch, topK, "stream-"+rangeName+"_"+category+"_"+toString(op)+"_topk.txt")
+			}
+		}
+		sort.Slice(heavyHitters, func(i, j int) bool {
+			if heavyHitters[i].Category != heavyHitters[j].Category {
+				return heavyHitters[i].Category < heavyHitters[j].Category
+			}
+			return heavyHitters[i].OpType < heavyHitters[j].OpType
+		})
+		if err := writeRecords(outputPath("countKVDist-heavyHitters-"+rangeName+".txt", outputFormat), outputFormat, heavyHitters); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	outputFile, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer outputFile.Close()
+	fmt.Fprintln(outputFile, "Count of KV operations:")
+	for category, opStats := range stats {
+		fmt.Fprintf(outputFile, "Category: %s\n", category)
+		for opType, count := range opStats.OpTypeCount {
+			fmt.Fprintf(outputFile, "  OPType: %s, Count: %d\n", opType, count)
+		}
+	}
+
+	fmt.Fprintf(outputFile, "\n\nTop-%d heavy hitters of KV operations (Space-Saving capacity: %d):\n", topK, capacity)
+	for category, opSketches := range sketches {
+		fmt.Fprintf(outputFile, "Category: %s\n", category)
+		for op, sketch := range opSketches {
+			fmt.Fprintf(outputFile, "  OPType: %s, Total: %d, Max error: %d\n", toString(op), sketch.Total, sketch.ErrorBound())
+			printTopKStats(sketch, topK, "stream-"+rangeName+"_"+category+"_"+toString(op)+"_topk.txt")
+		}
+	}
+	fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+}
+
+// printTopKStats writes the top-K keys (the frequency-rank curve) with the error bound of each count
+func printTopKStats(sketch *SpaceSaving, topK int, fileName string) {
+	if outputFormat != "text" {
+		var records []TopKRecord
+		for id, entry := range sketch.TopK(topK) {
+			records = append(records, TopKRecord{ID: id + 1, Key: entry.Key, Count: entry.Count, Error: entry.Error})
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %s\n", fileName)
+		return
+	}
+	defer file.Close()
+
+	// Count is the estimated count, the real count is in [Count-Error, Count]
+	_, _ = file.WriteString("ID\tKey\tCount\tError\n")
+	for id, entry := range sketch.TopK(topK) {
+		_, _ = file.WriteString(fmt.Sprintf("%d\t%s\t%d\t%d\n", id+1, entry.Key, entry.Count, entry.Error))
+	}
+}
+
 func main() {
-	if len(os.Args) < 6 {
-		fmt.Println("Usage: program <log_file_path> <batch_size_for_each_output> <print_progress_interval> <start_block_number> <end_block_number>")
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := flag.Args()
+	const usage = "Usage: program [--format=json|csv|text] <log_file_path> <batch_size_for_each_output> <print_progress_interval> <start_block_number> <end_block_number> [stream <top_k> <sketch_capacity>]"
+	if len(args) < 5 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println(usage)
+		return
+	}
+	outputFormat = *format
+	logFilePath := args[0]
+	stepSize, _ := strconv.ParseUint(args[1], 10, 64)
+	progressInterval, _ := strconv.ParseUint(args[2], 10, 64)
+	startBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[4], 10, 64)
+	if len(args) > 5 && args[5] == "stream" {
+		// Streaming mode: one pass over the whole range with bounded memory, the batch size is ignored
+		topK := 1000
+		var err error
+		if len(args) > 6 {
+			if topK, err = strconv.Atoi(args[6]); err != nil || topK < 1 {
+				fmt.Println("The top_k must be a positive integer")
+				fmt.Println(usage)
+				return
+			}
+		}
+		capacity := topK * 10
+		if len(args) > 7 {
+			if capacity, err = strconv.Atoi(args[7]); err != nil || capacity < topK {
+				fmt.Println("The sketch_capacity must be an integer not smaller than the top_k")
+				fmt.Println(usage)
+				return
+			}
+		}
+		processLogFileStreaming(logFilePath, progressInterval, startBlockNumber, endBlockNumber, capacity, topK)
 		return
 	}
-	logFilePath := os.Args[1]
-	stepSize, _ := strconv.ParseUint(os.Args[2], 10, 64)
-	progressInterval, _ := strconv.ParseUint(os.Args[3], 10, 64)
-	startBlockNumber, _ := strconv.ParseUint(os.Args[4], 10, 64)
-	endBlockNumber, _ := strconv.ParseUint(os.Args[5], 10, 64)
 	processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber, stepSize)
 }
@@ -2,19 +2,31 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-func main() {
+// OpCountRecord is a record of countKVDist-<start>_<end>.{json,csv} and countKVDist-merged.{json,csv}
+type OpCountRecord struct {
+	Category string `json:"category"`
+	OpType   string `json:"op_type"`
+	Count    uint64 `json:"count"`
+}
 
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: program <log_files_list_path>")
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 1 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_files_list_path>")
+		fmt.Println("The listed files are countKVDist-* outputs in any format (.txt, .json, or .csv)")
 		return
 	}
-	logFilePath := os.Args[1]
+	logFilePath := flag.Arg(0)
 	fmt.Println("Processing log file list:", logFilePath)
 	inputFileList, err := os.Open(logFilePath)
 	if err != nil {
@@ -50,11 +62,46 @@ func main() {
 		processFile(fileName, aggregatedData)
 	}
 	// Output the aggregated data
-	printAggregatedData(aggregatedData)
+	if *format == "text" {
+		printAggregatedData(aggregatedData)
+		return
+	}
+	var records []OpCountRecord
+	for category, opTypes := range aggregatedData {
+		for opType, count := range opTypes {
+			records = append(records, OpCountRecord{Category: category, OpType: opType, Count: count})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Category != records[j].Category {
+			return records[i].Category < records[j].Category
+		}
+		return records[i].OpType < records[j].OpType
+	})
+	if err := writeRecords("countKVDist-merged."+*format, *format, records); err != nil {
+		fmt.Println("Error:", err)
+	}
 }
 
 // processFile processes a single file and aggregates data
 func processFile(fileName string, aggregatedData map[string]map[string]uint64) error {
+	if isStructured(fileName) {
+		records, err := readRecords(fileName)
+		if err != nil {
+			return err
+		}
+		var totalOpCount uint64
+		for _, record := range records {
+			count, _ := strconv.ParseUint(record["count"], 10, 64)
+			if _, exists := aggregatedData[record["category"]]; !exists {
+				aggregatedData[record["category"]] = make(map[string]uint64)
+			}
+			aggregatedData[record["category"]][record["op_type"]] += count
+			totalOpCount += count
+		}
+		fmt.Printf("%d\n", totalOpCount)
+		return nil
+	}
 	file, err := os.Open(fileName)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %v", fileName, err)
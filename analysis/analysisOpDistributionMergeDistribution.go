@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -29,6 +30,19 @@ type OperationStats struct {
 	OpTypeCount map[string]int
 }
 
+// KeyCountRecord is a record of <category>_<op>_with_key_dis.{json,csv}
+type KeyCountRecord struct {
+	ID    int    `json:"id"`
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// RankCountRecord is a record of <category>_<op>_without_key_dis.{json,csv}
+type RankCountRecord struct {
+	ID    int `json:"id"`
+	Count int `json:"count"`
+}
+
 type OperationDistribution struct {
 	GetOpDistributionCount            map[string]int
 	UpdateOpDistributionCount         map[string]int
@@ -40,6 +54,7 @@ type OperationDistribution struct {
 var (
 	stats          = make(map[string]*OperationStats)
 	opDistribution = make(map[string]*OperationDistribution)
+	outputFormat   = "text" // One of text, json, and csv
 	hexPrefixes    = []PrefixCategory{
 		{"7365637572652d6b65792d", "PreimagePrefix"},
 		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
@@ -151,6 +166,22 @@ var (
 )
 
 func processLogFile(filePath, category, opType string) {
+	if isStructured(filePath) {
+		records, err := readRecords(filePath)
+		if err != nil {
+			panic(err)
+		}
+		for _, record := range records {
+			count, err := strconv.ParseUint(record["count"], 10, 64)
+			if err != nil {
+				fmt.Println("Error parsing count:", err)
+				continue
+			}
+			addCount(category, opType, record["key"], count)
+		}
+		fmt.Printf("\rProcessed a total of %d records.\n", len(records))
+		return
+	}
 	file, err := os.Open(filePath)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to open file: %s", filePath))
@@ -192,38 +223,42 @@ func processLogFile(filePath, category, opType string) {
 			continue
 		}
 		// fmt.Println("key:", key, "count:", count)
+		addCount(category, opType, key, count)
+	}
+	fmt.Printf("\rProcessed a total of %d lines.\n", lineCount)
+}
 
-		// Update stats
-		if _, exists := stats[category]; !exists {
-			stats[category] = &OperationStats{OpTypeCount: make(map[string]int)}
-		}
-		stats[category].OpTypeCount[opType] += int(count)
+// addCount adds the accesses of a key
+func addCount(category, opType, key string, count uint64) {
+	// Update stats
+	if _, exists := stats[category]; !exists {
+		stats[category] = &OperationStats{OpTypeCount: make(map[string]int)}
+	}
+	stats[category].OpTypeCount[opType] += int(count)
 
-		// Update operation distribution
-		if _, exists := opDistribution[category]; !exists {
-			opDistribution[category] = &OperationDistribution{
-				GetOpDistributionCount:            make(map[string]int),
-				UpdateOpDistributionCount:         make(map[string]int),
-				UpdateNotBatchOpDistributionCount: make(map[string]int),
-				DeleteOpDistributionCount:         make(map[string]int),
-				ScanOpDistributionCountRange:      make(map[string]int),
-			}
-		}
-		dist := opDistribution[category]
-		switch opType {
-		case "get":
-			dist.GetOpDistributionCount[key] += int(count)
-		case "batchput":
-			dist.UpdateOpDistributionCount[key] += int(count)
-		case "put":
-			dist.UpdateNotBatchOpDistributionCount[key] += int(count)
-		case "delete":
-			dist.DeleteOpDistributionCount[key] += int(count)
-		case "scan":
-			dist.ScanOpDistributionCountRange[key] += int(count)
+	// Update operation distribution
+	if _, exists := opDistribution[category]; !exists {
+		opDistribution[category] = &OperationDistribution{
+			GetOpDistributionCount:            make(map[string]int),
+			UpdateOpDistributionCount:         make(map[string]int),
+			UpdateNotBatchOpDistributionCount: make(map[string]int),
+			DeleteOpDistributionCount:         make(map[string]int),
+			ScanOpDistributionCountRange:      make(map[string]int),
 		}
 	}
-	fmt.Printf("\rProcessed a total of %d lines.\n", lineCount)
+	dist := opDistribution[category]
+	switch opType {
+	case "get":
+		dist.GetOpDistributionCount[key] += int(count)
+	case "batchput":
+		dist.UpdateOpDistributionCount[key] += int(count)
+	case "put":
+		dist.UpdateNotBatchOpDistributionCount[key] += int(count)
+	case "delete":
+		dist.DeleteOpDistributionCount[key] += int(count)
+	case "scan":
+		dist.ScanOpDistributionCountRange[key] += int(count)
+	}
 }
 
 func printDistributionStats(opMap map[string]int, category, opType string) {
@@ -249,6 +284,21 @@ func printDistributionStats(opMap map[string]int, category, opType string) {
 	}
 	fileName := category + "_" + opType + "_with_key_dis.txt"
 	fileNameWithoutKey := category + "_" + opType + "_without_key_dis.txt"
+	if outputFormat != "text" {
+		records := make([]KeyCountRecord, len(sortedOps))
+		recordsWithoutKey := make([]RankCountRecord, len(sortedOps))
+		for id, entry := range sortedOps {
+			records[id] = KeyCountRecord{ID: id + 1, Key: entry.Key, Count: entry.Count}
+			recordsWithoutKey[id] = RankCountRecord{ID: id + 1, Count: entry.Count}
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		if err := writeRecords(outputPath(fileNameWithoutKey, outputFormat), outputFormat, recordsWithoutKey); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		return
+	}
 	file, err := os.Create(fileName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating output file (with key): %s\n", fileName)
@@ -274,13 +324,17 @@ func printDistributionStats(opMap map[string]int, category, opType string) {
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: program <log_files_list_path> <category> <op_type>")
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 3 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_files_list_path> <category> <op_type>")
+		fmt.Println("The listed files are *_dis outputs in any format (.txt, .json, or .csv)")
 		return
 	}
-	logFilePath := os.Args[1]
-	category := os.Args[2]
-	opType := os.Args[3]
+	outputFormat = *format
+	logFilePath := flag.Arg(0)
+	category := flag.Arg(1)
+	opType := flag.Arg(2)
 	fmt.Println("Processing log file:", logFilePath)
 	inputFileList, err := os.Open(logFilePath)
 	if err != nil {
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// CacheEntry is an entry of the LRU cache, Prefetched is cleared once the entry is accessed
+type CacheEntry struct {
+	Key        string
+	Prefetched bool
+}
+
+// LRUCache is a cache bounded by the number of entries
+type LRUCache struct {
+	capacity int
+	order    *list.List // Front is the most recently used
+	entries  map[string]*list.Element
+}
+
+// PrefetchStats stores the replay results of a category. Accesses are counted by the category of the accessed
+// key, and prefetches by the category of the prefetched key
+type PrefetchStats struct {
+	Accesses         uint64
+	BaselineHits     uint64
+	PrefetchHits     uint64
+	PrefetchedHits   uint64 // Hits served by an entry that was prefetched and not accessed before
+	Prefetches       uint64
+	WastedPrefetches uint64 // Prefetched entries evicted (or left at the end) without being accessed
+	PrefetchBytes    uint64
+	WastedBytes      uint64
+}
+
+// PrefetchRecord is a record of prefetchOracle-<op>-<start>_<end>-top<n>-cache<entries>.{json,csv}, the category
+// All sums up the others
+type PrefetchRecord struct {
+	Category         string  `json:"category"`
+	Accesses         uint64  `json:"accesses"`
+	BaselineHitRatio float64 `json:"baseline_hit_ratio"`
+	PrefetchHitRatio float64 `json:"prefetch_hit_ratio"`
+	Prefetches       uint64  `json:"prefetches"`
+	PrefetchBytes    uint64  `json:"prefetch_bytes"`
+	UsefulPrefetches uint64  `json:"useful_prefetches"`
+	WastedPrefetches uint64  `json:"wasted_prefetches"`
+	WastedBytes      uint64  `json:"wasted_bytes"`
+	Precision        float64 `json:"precision"`
+	Recall           float64 `json:"recall"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: (\d+)(?:, value: [a-fA-F0-9]*, size: (\d+))?`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+	pairLineRegex   = regexp.MustCompile(`key: ([a-fA-F0-9]+)-\d+;([a-fA-F0-9]+)-\d+; Freq: (\d+); Blocks: ([\d;]+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Access looks up the key and moves it to the front, returning whether it hits and whether the hit entry was
+// prefetched. On a miss, the key is inserted, and the evicted entry (if any) is returned
+func (c *LRUCache) Access(key string) (hit, prefetched bool, evicted *CacheEntry) {
+	if element, exists := c.entries[key]; exists {
+		c.order.MoveToFront(element)
+		entry := element.Value.(*CacheEntry)
+		prefetched = entry.Prefetched
+		entry.Prefetched = false
+		return true, prefetched, nil
+	}
+	return false, false, c.insert(key, false)
+}
+
+// Prefetch inserts the key if it is not cached, returning whether it was inserted and the evicted entry (if any)
+func (c *LRUCache) Prefetch(key string) (inserted bool, evicted *CacheEntry) {
+	if _, exists := c.entries[key]; exists {
+		return false, nil
+	}
+	return true, c.insert(key, true)
+}
+
+func (c *LRUCache) insert(key string, prefetched bool) *CacheEntry {
+	c.entries[key] = c.order.PushFront(&CacheEntry{Key: key, Prefetched: prefetched})
+	if c.order.Len() <= c.capacity {
+		return nil
+	}
+	back := c.order.Back()
+	c.order.Remove(back)
+	entry := back.Value.(*CacheEntry)
+	delete(c.entries, entry.Key)
+	return entry
+}
+
+// loadRules reads the sorted correlation log and keeps the top-N correlated keys of each key. Since the log is
+// sorted by frequency in descending order, the first N neighbors of a key are its top-N. It also returns the
+// largest block ID in the log, to check that the training range precedes the replay range. The log is either the
+// text log or its .json or .csv output
+func loadRules(filePath string, topN int, minFreq uint64) (map[string][]string, uint64, error) {
+	rules := make(map[string][]string)
+	var maxBlockID uint64
+	var lineCount, pairCount uint64
+
+	// addPair adds the rules of a pair, and returns false once the frequency is below the threshold
+	addPair := func(key1, key2 string, frequency uint64, blockIDs []string) bool {
+		if frequency < minFreq {
+			// Sorted in descending order, all remaining pairs are below the threshold
+			return false
+		}
+		for _, blockID := range blockIDs {
+			if id, err := strconv.ParseUint(blockID, 10, 64); err == nil && id > maxBlockID {
+				maxBlockID = id
+			}
+		}
+		key1, key2 = strings.ToLower(key1), strings.ToLower(key2)
+		if key1 == key2 {
+			return true
+		}
+		// The pairs are order-independent, so the rule applies in both directions
+		if len(rules[key1]) < topN {
+			rules[key1] = append(rules[key1], key2)
+			pairCount++
+		}
+		if len(rules[key2]) < topN {
+			rules[key2] = append(rules[key2], key1)
+			pairCount++
+		}
+		return true
+	}
+
+	if isStructured(filePath) {
+		records, err := readRecords(filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range records {
+			frequency, _ := strconv.ParseUint(record["frequency"], 10, 64)
+			if !addPair(record["key1"], record["key2"], frequency, strings.Fields(strings.Trim(record["block_ids"], "[]"))) {
+				break
+			}
+		}
+		fmt.Printf("Loaded %d rules for %d keys\n", pairCount, len(rules))
+		return rules, maxBlockID, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, fmt.Errorf("error reading file: %v", err)
+		}
+		lineCount++
+		if lineCount%100000 == 0 {
+			fmt.Printf("\rLoaded %d lines", lineCount)
+		}
+
+		matches := pairLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, 0, fmt.Errorf("invalid log format: %s", line)
+		}
+		frequency, _ := strconv.ParseUint(matches[3], 10, 64)
+		if !addPair(matches[1], matches[2], frequency, strings.Split(matches[4], ";")) {
+			break
+		}
+	}
+	fmt.Printf("\nLoaded %d rules for %d keys\n", pairCount, len(rules))
+	return rules, maxBlockID, nil
+}
+
+// replay runs a cache without prefetching (baseline) and a cache with prefetching side by side. After each access,
+// the top-N correlated keys of the accessed key are prefetched into the second cache
+func replay(filePath, opType string, progressInterval, startBlockNumber, endBlockNumber uint64, capacity int, rules map[string][]string) map[string]*PrefetchStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	prefetchStats := make(map[string]*PrefetchStats)
+	getStats := func(category string) *PrefetchStats {
+		if _, exists := prefetchStats[category]; !exists {
+			prefetchStats[category] = &PrefetchStats{}
+		}
+		return prefetchStats[category]
+	}
+	baseline := NewLRUCache(capacity)
+	prefetchCache := NewLRUCache(capacity)
+	// The trace only logs the value size of writes, so the size of a prefetched KV pair is the key size plus the
+	// last written value size of the key, or the average written value size of its category if never written
+	valueSizes := make(map[string]uint32)
+	categoryValueBytes := make(map[string]uint64)
+	categoryValueCount := make(map[string]uint64)
+	kvSize := func(key string) uint64 {
+		size := uint64(len(key) / 2)
+		if valueSize, exists := valueSizes[key]; exists {
+			return size + uint64(valueSize)
+		}
+		category := matchPrefix(key)
+		if categoryValueCount[category] > 0 {
+			size += categoryValueBytes[category] / categoryValueCount[category]
+		}
+		return size
+	}
+	wasted := func(entry *CacheEntry) {
+		if entry != nil && entry.Prefetched {
+			ps := getStats(matchPrefix(entry.Key))
+			ps.WastedPrefetches++
+			ps.WastedBytes += kvSize(entry.Key)
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		key := strings.ToLower(matches[2])
+		// Track the value sizes of all writes, including the ones before the range
+		if matches[4] != "" {
+			valueSize, _ := strconv.ParseUint(matches[4], 10, 32)
+			category := matchPrefix(key)
+			valueSizes[key] = uint32(valueSize)
+			categoryValueBytes[category] += valueSize
+			categoryValueCount[category]++
+		}
+		if !foundStartBlock || matches[1] != opType {
+			continue
+		}
+
+		ps := getStats(matchPrefix(key))
+		ps.Accesses++
+		if hit, _, _ := baseline.Access(key); hit {
+			ps.BaselineHits++
+		}
+		hit, prefetched, evicted := prefetchCache.Access(key)
+		if hit {
+			ps.PrefetchHits++
+			if prefetched {
+				ps.PrefetchedHits++
+			}
+		}
+		wasted(evicted)
+
+		for _, neighbor := range rules[key] {
+			inserted, evicted := prefetchCache.Prefetch(neighbor)
+			if !inserted {
+				continue
+			}
+			nps := getStats(matchPrefix(neighbor))
+			nps.Prefetches++
+			nps.PrefetchBytes += kvSize(neighbor)
+			wasted(evicted)
+		}
+	}
+
+	// The prefetched entries that are never accessed until the end are also wasted
+	for element := prefetchCache.order.Front(); element != nil; element = element.Next() {
+		wasted(element.Value.(*CacheEntry))
+	}
+	return prefetchStats
+}
+
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func sortedCategories(prefetchStats map[string]*PrefetchStats) []string {
+	categories := make([]string, 0, len(prefetchStats))
+	for category := range prefetchStats {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func printCategory(outputFile *os.File, category string, ps *PrefetchStats) {
+	baselineHitRatio := ratio(ps.BaselineHits, ps.Accesses)
+	prefetchHitRatio := ratio(ps.PrefetchHits, ps.Accesses)
+	fmt.Fprintf(outputFile, "Category: %s\n", category)
+	fmt.Fprintf(outputFile, "  Accesses: %d\n", ps.Accesses)
+	fmt.Fprintf(outputFile, "  Baseline hit ratio: %.6f\n", baselineHitRatio)
+	fmt.Fprintf(outputFile, "  Prefetch hit ratio: %.6f\n", prefetchHitRatio)
+	fmt.Fprintf(outputFile, "  Hit ratio improvement: %.6f\n", prefetchHitRatio-baselineHitRatio)
+	fmt.Fprintf(outputFile, "  Prefetches: %d (%d bytes)\n", ps.Prefetches, ps.PrefetchBytes)
+	fmt.Fprintf(outputFile, "  Useful prefetches: %d\n", ps.Prefetches-ps.WastedPrefetches)
+	fmt.Fprintf(outputFile, "  Wasted prefetches: %d (%d bytes)\n", ps.WastedPrefetches, ps.WastedBytes)
+	fmt.Fprintf(outputFile, "  Precision: %.6f\n", ratio(ps.Prefetches-ps.WastedPrefetches, ps.Prefetches))
+	fmt.Fprintf(outputFile, "  Recall: %.6f\n", ratio(ps.PrefetchedHits, ps.Accesses-ps.BaselineHits))
+}
+
+// totalStats sums up the statistics of all categories
+func totalStats(prefetchStats map[string]*PrefetchStats) *PrefetchStats {
+	total := &PrefetchStats{}
+	for _, ps := range prefetchStats {
+		total.Accesses += ps.Accesses
+		total.BaselineHits += ps.BaselineHits
+		total.PrefetchHits += ps.PrefetchHits
+		total.PrefetchedHits += ps.PrefetchedHits
+		total.Prefetches += ps.Prefetches
+		total.WastedPrefetches += ps.WastedPrefetches
+		total.PrefetchBytes += ps.PrefetchBytes
+		total.WastedBytes += ps.WastedBytes
+	}
+	return total
+}
+
+func printStats(outputFile *os.File, prefetchStats map[string]*PrefetchStats) {
+	for _, category := range sortedCategories(prefetchStats) {
+		printCategory(outputFile, category, prefetchStats[category])
+	}
+	printCategory(outputFile, "All", totalStats(prefetchStats))
+}
+
+// prefetchRecords returns the records of the categories, followed by the total
+func prefetchRecords(prefetchStats map[string]*PrefetchStats) []PrefetchRecord {
+	var records []PrefetchRecord
+	for _, category := range append(sortedCategories(prefetchStats), "All") {
+		ps, exists := prefetchStats[category]
+		if !exists {
+			ps = totalStats(prefetchStats)
+		}
+		records = append(records, PrefetchRecord{
+			Category:         category,
+			Accesses:         ps.Accesses,
+			BaselineHitRatio: ratio(ps.BaselineHits, ps.Accesses),
+			PrefetchHitRatio: ratio(ps.PrefetchHits, ps.Accesses),
+			Prefetches:       ps.Prefetches,
+			PrefetchBytes:    ps.PrefetchBytes,
+			UsefulPrefetches: ps.Prefetches - ps.WastedPrefetches,
+			WastedPrefetches: ps.WastedPrefetches,
+			WastedBytes:      ps.WastedBytes,
+			Precision:        ratio(ps.Prefetches-ps.WastedPrefetches, ps.Prefetches),
+			Recall:           ratio(ps.PrefetchedHits, ps.Accesses-ps.BaselineHits),
+		})
+	}
+	return records
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 7 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <correlation_log_path> <log_file_path> <start_block_number> <end_block_number> <top_n> <cache_entries> [min_freq] [op_type] [print_progress_interval]")
+		fmt.Println("E.g.: program ./freq-sorted-0.log /path/to/trace 21000000 21010000 4 1000000 2 Get 100000")
+		return
+	}
+	correlationLogPath := args[1]
+	logFilePath := args[2]
+	startBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[4], 10, 64)
+	topN, _ := strconv.Atoi(args[5])
+	capacity, _ := strconv.Atoi(args[6])
+	minFreq := uint64(2)
+	if len(args) > 7 {
+		minFreq, _ = strconv.ParseUint(args[7], 10, 64)
+	}
+	opType := "Get"
+	if len(args) > 8 {
+		opType = args[8]
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 9 {
+		progressInterval, _ = strconv.ParseUint(args[9], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+	if topN < 1 || capacity < 1 {
+		fmt.Println("Invalid top N or cache entries:", topN, capacity)
+		return
+	}
+
+	rules, maxTrainingBlockID, err := loadRules(correlationLogPath, topN, minFreq)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if maxTrainingBlockID >= startBlockNumber {
+		fmt.Printf("Warning: the correlations are mined up to block %d, which overlaps the replay range starting at %d\n", maxTrainingBlockID, startBlockNumber)
+	}
+
+	prefetchStats := replay(logFilePath, opType, progressInterval, startBlockNumber, endBlockNumber, capacity, rules)
+
+	outPutLogPath := fmt.Sprintf("prefetchOracle-%s-%d_%d-top%d-cache%d.txt", opType, startBlockNumber, endBlockNumber, topN, capacity)
+	if *format != "text" {
+		if err := writeRecords(outputPath(outPutLogPath, *format), *format, prefetchRecords(prefetchStats)); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "Prefetch replay of %s operations, blocks %d-%d, top %d correlated keys, cache entries %d, min freq %d, training blocks up to %d, rules for %d keys\n",
+		opType, startBlockNumber, endBlockNumber, topN, capacity, minFreq, maxTrainingBlockID, len(rules))
+	printStats(file, prefetchStats)
+	fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// WriteRecord stores the position of the most recent write of a key
+type WriteRecord struct {
+	BlockID      uint64 // Block ID of the write
+	OpIndex      uint64 // Index of the write among all KV operations
+	BytesWritten uint64 // Accumulated bytes written (including this write) when the write happens
+}
+
+// DistanceStats store the read-after-write distances of a category
+type DistanceStats struct {
+	ReadCount             uint64            // Number of reads
+	ReadWithoutWriteCount uint64            // Number of reads whose key is not written before in the trace
+	BlockHistogram        map[uint64]uint64 // Histogram of distances in blocks, key is the distance and value is the count
+	OpHistogram           map[uint64]uint64 // Histogram of distances in operations, key is the power-of-two bucket upper bound
+	ByteHistogram         map[uint64]uint64 // Histogram of distances in bytes written, key is the power-of-two bucket upper bound
+}
+
+// FractionRecord is a record of readAfterWrite-<label>-<start>_<end>.{json,csv}, the unit of the threshold is blocks
+// or MiB
+type FractionRecord struct {
+	Category              string  `json:"category"`
+	ReadCount             uint64  `json:"read_count"`
+	ReadWithoutWriteCount uint64  `json:"read_without_write_count"`
+	Threshold             uint64  `json:"threshold"`
+	Unit                  string  `json:"unit"`
+	Fraction              float64 `json:"fraction"`
+}
+
+// CDFRecord is a record of the readAfterWriteCDF-<label>-<start>_<end>_<category>_*_cdf.{json,csv} files
+type CDFRecord struct {
+	Distance uint64  `json:"distance"`
+	Count    uint64  `json:"count"`
+	CDF      float64 `json:"cdf"`
+}
+
+// CompareRecord is a record of readAfterWrite-compare-<start>_<end>.{json,csv}, with one record per trace
+type CompareRecord struct {
+	Category  string  `json:"category"`
+	Threshold uint64  `json:"threshold"`
+	Unit      string  `json:"unit"`
+	Trace     string  `json:"trace"`
+	Fraction  float64 `json:"fraction"`
+}
+
+var (
+	outputFormat = "text" // One of text, json, and csv
+	hexPrefixes  = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	// The value part only exists for writes, the value itself is skipped to avoid capturing large strings
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: (\d+)(?:, value: [a-fA-F0-9]*, size: (\d+))?`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+
+	// Thresholds used to compare the traces
+	blockThresholds = []uint64{0, 1, 2, 4, 8, 16, 32, 64, 128}
+	byteThresholds  = []uint64{1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20, 1 << 30}
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// powerOfTwoBucket returns the smallest power of two that is not smaller than the distance (0 for 0)
+func powerOfTwoBucket(distance uint64) uint64 {
+	if distance == 0 {
+		return 0
+	}
+	return 1 << bits.Len64(distance-1)
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64) map[string]*DistanceStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	distanceStats := make(map[string]*DistanceStats)
+	lastWrites := make(map[string]WriteRecord)
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount, opIndex, bytesWritten uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, tracked keys: %d, elapsed time: %.2fs", lineCount, currentBlockID, len(lastWrites), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		opType, hexKey := matches[1], matches[2]
+		rawKey, err := hex.DecodeString(hexKey)
+		if err != nil {
+			fmt.Println("Error decoding hex key:", err)
+			continue
+		}
+		key := string(rawKey)
+		opIndex++
+
+		switch opType {
+		case "Put", "BatchPut", "Update":
+			keySize, _ := strconv.ParseUint(matches[3], 10, 64)
+			valueSize, _ := strconv.ParseUint(matches[4], 10, 64)
+			bytesWritten += keySize + valueSize
+			lastWrites[key] = WriteRecord{BlockID: currentBlockID, OpIndex: opIndex, BytesWritten: bytesWritten}
+		case "Delete", "BatchDelete":
+			bytesWritten += uint64(len(rawKey))
+			delete(lastWrites, key)
+		case "Get":
+			category := matchPrefix(hexKey)
+			if _, exists := distanceStats[category]; !exists {
+				distanceStats[category] = &DistanceStats{
+					BlockHistogram: make(map[uint64]uint64),
+					OpHistogram:    make(map[uint64]uint64),
+					ByteHistogram:  make(map[uint64]uint64),
+				}
+			}
+			ds := distanceStats[category]
+			ds.ReadCount++
+			record, exists := lastWrites[key]
+			if !exists {
+				ds.ReadWithoutWriteCount++
+				continue
+			}
+			ds.BlockHistogram[currentBlockID-record.BlockID]++
+			ds.OpHistogram[powerOfTwoBucket(opIndex-record.OpIndex)]++
+			ds.ByteHistogram[powerOfTwoBucket(bytesWritten-record.BytesWritten)]++
+		}
+	}
+	return distanceStats
+}
+
+// PrintCDF writes the histogram with the cumulative fraction over all reads of the category
+func PrintCDF(fileName, columnName string, histogram map[uint64]uint64, readCount uint64) {
+	buckets := make([]uint64, 0, len(histogram))
+	for bucket := range histogram {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i] < buckets[j]
+	})
+
+	if outputFormat != "text" {
+		records := make([]CDFRecord, 0, len(buckets))
+		var accumulated uint64
+		for _, bucket := range buckets {
+			accumulated += histogram[bucket]
+			records = append(records, CDFRecord{Distance: bucket, Count: histogram[bucket], CDF: float64(accumulated) / float64(readCount)})
+		}
+		if err := writeRecords(outputPath(fileName, outputFormat), outputFormat, records); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	outputFile, err := os.Create(fileName)
+	if err != nil {
+		fmt.Printf("Cannot open the output file %s: %v\n", fileName, err)
+		return
+	}
+	defer outputFile.Close()
+	fmt.Fprintf(outputFile, "%s\tcount\tcdf\n", columnName)
+	var accumulated uint64
+	for _, bucket := range buckets {
+		accumulated += histogram[bucket]
+		fmt.Fprintf(outputFile, "%d\t%d\t%.6f\n", bucket, histogram[bucket], float64(accumulated)/float64(readCount))
+	}
+}
+
+// fractionWithin returns the fraction of reads whose distance is not larger than the threshold
+func fractionWithin(histogram map[uint64]uint64, threshold, readCount uint64) float64 {
+	if readCount == 0 {
+		return 0
+	}
+	var count uint64
+	for bucket, bucketCount := range histogram {
+		if bucket <= threshold {
+			count += bucketCount
+		}
+	}
+	return float64(count) / float64(readCount)
+}
+
+func sortedCategories(distanceStats map[string]*DistanceStats) []string {
+	categories := make([]string, 0, len(distanceStats))
+	for category := range distanceStats {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func printStats(outputFile *os.File, distanceStats map[string]*DistanceStats) {
+	fmt.Fprintln(outputFile, "Read-after-write distance of KV operations:")
+	for _, category := range sortedCategories(distanceStats) {
+		ds := distanceStats[category]
+		fmt.Fprintf(outputFile, "Category: %s\n", category)
+		fmt.Fprintf(outputFile, "  Read count: %d\n", ds.ReadCount)
+		fmt.Fprintf(outputFile, "  Read count (not written in trace): %d\n", ds.ReadWithoutWriteCount)
+		for _, threshold := range blockThresholds {
+			fmt.Fprintf(outputFile, "  Reads within %d blocks: %.6f\n", threshold, fractionWithin(ds.BlockHistogram, threshold, ds.ReadCount))
+		}
+		for _, threshold := range byteThresholds {
+			fmt.Fprintf(outputFile, "  Reads within %d MiB written: %.6f\n", threshold>>20, fractionWithin(ds.ByteHistogram, threshold, ds.ReadCount))
+		}
+	}
+}
+
+// fractionRecords returns the fraction of reads within each threshold of the categories
+func fractionRecords(distanceStats map[string]*DistanceStats) []FractionRecord {
+	var records []FractionRecord
+	for _, category := range sortedCategories(distanceStats) {
+		ds := distanceStats[category]
+		record := FractionRecord{Category: category, ReadCount: ds.ReadCount, ReadWithoutWriteCount: ds.ReadWithoutWriteCount}
+		for _, threshold := range blockThresholds {
+			record.Threshold, record.Unit = threshold, "blocks"
+			record.Fraction = fractionWithin(ds.BlockHistogram, threshold, ds.ReadCount)
+			records = append(records, record)
+		}
+		for _, threshold := range byteThresholds {
+			record.Threshold, record.Unit = threshold>>20, "MiB"
+			record.Fraction = fractionWithin(ds.ByteHistogram, threshold, ds.ReadCount)
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// printCDFs writes the distance CDFs of the categories that have reads of written keys
+func printCDFs(filePrefix string, distanceStats map[string]*DistanceStats) {
+	for _, category := range sortedCategories(distanceStats) {
+		ds := distanceStats[category]
+		if ds.ReadCount == ds.ReadWithoutWriteCount {
+			continue
+		}
+		PrintCDF(filePrefix+category+"_blocks_cdf.txt", "blocks", ds.BlockHistogram, ds.ReadCount)
+		PrintCDF(filePrefix+category+"_ops_cdf.txt", "ops", ds.OpHistogram, ds.ReadCount)
+		PrintCDF(filePrefix+category+"_bytes_cdf.txt", "bytes", ds.ByteHistogram, ds.ReadCount)
+	}
+}
+
+// compareRecords returns the fraction of reads within each threshold of all traces
+func compareRecords(labels []string, results []map[string]*DistanceStats) []CompareRecord {
+	categorySet := make(map[string]*DistanceStats)
+	for _, result := range results {
+		for category, ds := range result {
+			categorySet[category] = ds
+		}
+	}
+	var records []CompareRecord
+	for _, category := range sortedCategories(categorySet) {
+		for _, threshold := range blockThresholds {
+			for i, result := range results {
+				record := CompareRecord{Category: category, Threshold: threshold, Unit: "blocks", Trace: labels[i]}
+				if ds, exists := result[category]; exists {
+					record.Fraction = fractionWithin(ds.BlockHistogram, threshold, ds.ReadCount)
+				}
+				records = append(records, record)
+			}
+		}
+		for _, threshold := range byteThresholds {
+			for i, result := range results {
+				record := CompareRecord{Category: category, Threshold: threshold >> 20, Unit: "MiB", Trace: labels[i]}
+				if ds, exists := result[category]; exists {
+					record.Fraction = fractionWithin(ds.ByteHistogram, threshold, ds.ReadCount)
+				}
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// printComparison writes the fraction of reads within each threshold side by side for all traces
+func printComparison(outputFile *os.File, labels []string, results []map[string]*DistanceStats) {
+	categorySet := make(map[string]*DistanceStats)
+	for _, result := range results {
+		for category, ds := range result {
+			categorySet[category] = ds
+		}
+	}
+	fmt.Fprintf(outputFile, "Category\tThreshold\t%s\n", strings.Join(labels, "\t"))
+	for _, category := range sortedCategories(categorySet) {
+		for _, threshold := range blockThresholds {
+			fmt.Fprintf(outputFile, "%s\t%d blocks", category, threshold)
+			for _, result := range results {
+				fraction := 0.0
+				if ds, exists := result[category]; exists {
+					fraction = fractionWithin(ds.BlockHistogram, threshold, ds.ReadCount)
+				}
+				fmt.Fprintf(outputFile, "\t%.6f", fraction)
+			}
+			fmt.Fprintln(outputFile)
+		}
+		for _, threshold := range byteThresholds {
+			fmt.Fprintf(outputFile, "%s\t%d MiB", category, threshold>>20)
+			for _, result := range results {
+				fraction := 0.0
+				if ds, exists := result[category]; exists {
+					fraction = fractionWithin(ds.ByteHistogram, threshold, ds.ReadCount)
+				}
+				fmt.Fprintf(outputFile, "\t%.6f", fraction)
+			}
+			fmt.Fprintln(outputFile)
+		}
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 6 || len(args)%2 != 0 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <print_progress_interval> <start_block_number> <end_block_number> <trace_label> <log_file_path> [<trace_label> <log_file_path> ...]")
+		fmt.Println("E.g.: program 10000 20500000 21500000 BareTrace /path/to/bare-trace CacheTrace /path/to/cache-trace")
+		return
+	}
+	outputFormat = *format
+	progressInterval, _ := strconv.ParseUint(args[1], 10, 64)
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	if progressInterval == 0 {
+		progressInterval = 1000
+	}
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+
+	var labels []string
+	var results []map[string]*DistanceStats
+	for i := 4; i+1 < len(args); i += 2 {
+		label, logFilePath := args[i], args[i+1]
+		fmt.Println("Processing trace", label, ":", logFilePath)
+		distanceStats := processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber)
+
+		outPutLogPath := "readAfterWrite-" + label + "-" + rangeName + ".txt"
+		printCDFs("readAfterWriteCDF-"+label+"-"+rangeName+"_", distanceStats)
+		if outputFormat != "text" {
+			if err := writeRecords(outputPath(outPutLogPath, outputFormat), outputFormat, fractionRecords(distanceStats)); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		} else {
+			file, err := os.Create(outPutLogPath)
+			if err != nil {
+				fmt.Println("Error creating output file:", outPutLogPath)
+				return
+			}
+			printStats(file, distanceStats)
+			file.Close()
+			fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+		}
+
+		labels = append(labels, label)
+		results = append(results, distanceStats)
+	}
+
+	if len(results) > 1 {
+		comparePath := "readAfterWrite-compare-" + rangeName + ".txt"
+		if outputFormat != "text" {
+			if err := writeRecords(outputPath(comparePath, outputFormat), outputFormat, compareRecords(labels, results)); err != nil {
+				fmt.Println("Error:", err)
+			}
+			return
+		}
+		file, err := os.Create(comparePath)
+		if err != nil {
+			fmt.Println("Error creating output file:", comparePath)
+			return
+		}
+		defer file.Close()
+		printComparison(file, labels, results)
+		fmt.Printf("Comparison is stored to: %s\n", comparePath)
+	}
+}
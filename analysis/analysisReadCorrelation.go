@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -16,6 +17,28 @@ type PairInfo struct {
 	BlockIDs  string // Store block IDs as a single concatenated string
 }
 
+// KeyPairRecord is a record of the sorted frequency output (freq-sorted-<distance>.{json,csv}), the keys are hex
+// strings
+type KeyPairRecord struct {
+	Key1      string   `json:"key1"`
+	Size1     uint64   `json:"size1"`
+	Key2      string   `json:"key2"`
+	Size2     uint64   `json:"size2"`
+	Frequency int      `json:"frequency"`
+	BlockIDs  []uint64 `json:"block_ids"`
+}
+
+// CategoryPairRecord is a record of the category frequency output (freq-category-<distance>.{json,csv})
+type CategoryPairRecord struct {
+	Category1      string `json:"category1"`
+	Category2      string `json:"category2"`
+	Frequency      int    `json:"frequency"`
+	TotalFrequency int    `json:"total_frequency"`
+}
+
+// outputFormat is one of text, json, and csv
+var outputFormat = "text"
+
 const outputPathPrefix = "/mnt/16T/"
 
 func MergeLogFiles(logFiles []string, outputFile string) error {
@@ -30,6 +53,25 @@ func MergeLogFiles(logFiles []string, outputFile string) error {
 	fmt.Printf("Merge log files\n")
 
 	for _, logFile := range logFiles {
+		// The structured outputs of the collectors are read by their fields
+		if isStructured(logFile) {
+			records, err := readRecords(logFile)
+			if err != nil {
+				return err
+			}
+			for _, record := range records {
+				frequency, err := strconv.Atoi(record["frequency"])
+				if err != nil {
+					return fmt.Errorf("failed to parse frequency: %v", err)
+				}
+				blockIDs := strings.Join(strings.Fields(strings.Trim(record["block_ids"], "[]")), ";")
+				mergeKeyPair(globalMap, record["key1"]+"-"+record["size1"], record["key2"]+"-"+record["size2"], frequency, blockIDs)
+			}
+			lineCount += len(records)
+			fmt.Printf("\rMerged %d lines", lineCount)
+			continue
+		}
+
 		// Open the log file
 		file, err := os.Open(logFile)
 		if err != nil {
@@ -72,29 +114,7 @@ func MergeLogFiles(logFiles []string, outputFile string) error {
 			}
 			blockIDs := matches[4] // Extract block IDs as a single string
 
-			// Ensure key pairs are order-independent (e.g., key1;key2 is the same as key2;key1)
-			keyPair := fmt.Sprintf("%s;%s", key1, key2)
-			if key1 > key2 {
-				keyPair = fmt.Sprintf("%s;%s", key2, key1)
-			}
-
-			// Update the global map
-			if existingPairInfo, exists := globalMap[keyPair]; exists {
-				// Increment frequency
-				existingPairInfo.Frequency += frequency
-
-				// Append block IDs, separated by a semicolon
-				existingPairInfo.BlockIDs += ";" + blockIDs
-
-				// Update the map
-				globalMap[keyPair] = existingPairInfo
-			} else {
-				// Create a new entry
-				globalMap[keyPair] = PairInfo{
-					Frequency: frequency,
-					BlockIDs:  blockIDs,
-				}
-			}
+			mergeKeyPair(globalMap, key1, key2, frequency, blockIDs)
 		}
 	}
 
@@ -117,6 +137,33 @@ func MergeLogFiles(logFiles []string, outputFile string) error {
 	return nil
 }
 
+// mergeKeyPair adds the frequency and block IDs of a key pair to the global map
+func mergeKeyPair(globalMap map[string]PairInfo, key1, key2 string, frequency int, blockIDs string) {
+	// Ensure key pairs are order-independent (e.g., key1;key2 is the same as key2;key1)
+	keyPair := fmt.Sprintf("%s;%s", key1, key2)
+	if key1 > key2 {
+		keyPair = fmt.Sprintf("%s;%s", key2, key1)
+	}
+
+	// Update the global map
+	if existingPairInfo, exists := globalMap[keyPair]; exists {
+		// Increment frequency
+		existingPairInfo.Frequency += frequency
+
+		// Append block IDs, separated by a semicolon
+		existingPairInfo.BlockIDs += ";" + blockIDs
+
+		// Update the map
+		globalMap[keyPair] = existingPairInfo
+	} else {
+		// Create a new entry
+		globalMap[keyPair] = PairInfo{
+			Frequency: frequency,
+			BlockIDs:  blockIDs,
+		}
+	}
+}
+
 // LogEntry represents a single log entry with its frequency and the original line
 type LogEntry struct {
 	Frequency int
@@ -215,9 +262,42 @@ func SortLogFile(inputFile, outputFile string) (int, error) {
 	}
 
 	fmt.Printf("Sorted log written to %s\n", outputFile)
+
+	if outputFormat != "text" {
+		records := make([]KeyPairRecord, 0, len(entries))
+		for _, entry := range entries {
+			record, err := parseKeyPairRecord(entry.Line)
+			if err != nil {
+				return 0, err
+			}
+			records = append(records, record)
+		}
+		if err := writeRecords(outputPath(outputFile, outputFormat), outputFormat, records); err != nil {
+			return 0, err
+		}
+	}
 	return totalFrequency, nil
 }
 
+// parseKeyPairRecord converts a line of the sorted log to a record
+func parseKeyPairRecord(line string) (KeyPairRecord, error) {
+	re := regexp.MustCompile(`key: ([a-fA-F0-9]+)-([0-9]+);([a-fA-F0-9]+)-([0-9]+); Freq: (\d+); Blocks: ([0-9;]+)`)
+	matches := re.FindStringSubmatch(line)
+	if len(matches) != 7 {
+		return KeyPairRecord{}, fmt.Errorf("invalid log format: %s", line)
+	}
+	record := KeyPairRecord{Key1: matches[1], Key2: matches[3]}
+	record.Size1, _ = strconv.ParseUint(matches[2], 10, 64)
+	record.Size2, _ = strconv.ParseUint(matches[4], 10, 64)
+	record.Frequency, _ = strconv.Atoi(matches[5])
+	for _, blockID := range strings.Split(matches[6], ";") {
+		if id, err := strconv.ParseUint(blockID, 10, 64); err == nil {
+			record.BlockIDs = append(record.BlockIDs, id)
+		}
+	}
+	return record, nil
+}
+
 // PrefixCategory represents a prefix and its corresponding category name
 type PrefixCategory struct {
 	Prefix   string
@@ -462,10 +542,34 @@ func GetCategoryFrequency(inputFileName string, outputFileName string, distance
 
 	fmt.Printf("Frequency map written to %s\n", outputFileName)
 
+	if outputFormat != "text" {
+		records := make([]CategoryPairRecord, 0, len(sortedFrequencies))
+		for _, entry := range sortedFrequencies {
+			categories := strings.SplitN(entry.CategoryPair, ";", 2)
+			records = append(records, CategoryPairRecord{
+				Category1:      categories[0],
+				Category2:      categories[1],
+				Frequency:      entry.Frequency,
+				TotalFrequency: totalFreq,
+			})
+		}
+		if err := writeRecords(outputPath(outputFileName, outputFormat), outputFormat, records); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if *format != "text" && *format != "json" && *format != "csv" {
+		fmt.Println("Usage: program [--format=json|csv|text]")
+		return
+	}
+	outputFormat = *format
+
 	// Step 1: Merge log files
 
 	distance := 64
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// ReadRecord stores the last read of a key in the current block
+type ReadRecord struct {
+	OpIndex      uint64
+	Written      bool   // Whether the key had been written before the read, i.e., a write-through cache holds it
+	WrittenBlock uint64 // Block of the last write before the read
+}
+
+// RMWStats stores the read-modify-write statistics of a category. Each update is classified once, in the order
+// of same-key read, parent path read, and ancestor path read (the last two only apply to trie nodes)
+type RMWStats struct {
+	ReadCount           uint64
+	UpdateCount         uint64
+	SameKeyReadCount    uint64
+	ParentReadCount     uint64
+	AncestorReadCount   uint64
+	OpDistanceHistogram map[uint64]uint64 // Operations between the same-key read and the update, in power-of-two buckets
+	CachedReadCount     uint64            // Same-key reads of keys written before, which a write-through cache would serve
+	CacheBlockHistogram map[uint64]uint64 // Blocks between the last write and the same-key read, for cached reads
+}
+
+// RMWRecord is a record of readModifyWrite-<start>_<end>.{json,csv}, the fractions follow opThresholds and
+// blockThresholds, and the category All sums up the others
+type RMWRecord struct {
+	Category           string    `json:"category"`
+	ReadCount          uint64    `json:"read_count"`
+	UpdateCount        uint64    `json:"update_count"`
+	SameKeyReadCount   uint64    `json:"same_key_read_count"`
+	ParentReadCount    uint64    `json:"parent_read_count"`
+	AncestorReadCount  uint64    `json:"ancestor_read_count"`
+	NoReadCount        uint64    `json:"no_read_count"`
+	CachedReadCount    uint64    `json:"cached_read_count"`
+	SameKeyWithinOps   []float64 `json:"same_key_within_ops"`
+	CachedWithinBlocks []float64 `json:"cached_within_blocks"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+)`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+
+	// Thresholds used to summarize the distances
+	opThresholds    = []uint64{1, 4, 16, 64, 256, 1024, 4096}
+	blockThresholds = []uint64{0, 1, 2, 4, 8, 16, 32, 64, 128}
+)
+
+const (
+	// Trie node keys: "A" + path for the account trie, "O" + account hash + path for the storage tries,
+	// where the path stores one nibble per byte
+	accountTriePrefix = "41"
+	storageTriePrefix = "4f"
+	accountHashHexLen = 64
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// powerOfTwoBucket returns the smallest power of two that is not smaller than the distance (0 for 0)
+func powerOfTwoBucket(distance uint64) uint64 {
+	if distance == 0 {
+		return 0
+	}
+	return 1 << bits.Len64(distance-1)
+}
+
+// triePathStart returns the offset of the trie path in the hex key, or -1 if the key is not a trie node
+func triePathStart(hexKey string) int {
+	switch {
+	case strings.HasPrefix(hexKey, accountTriePrefix):
+		return len(accountTriePrefix)
+	case strings.HasPrefix(hexKey, storageTriePrefix) && len(hexKey) >= len(storageTriePrefix)+accountHashHexLen:
+		return len(storageTriePrefix) + accountHashHexLen
+	}
+	return -1
+}
+
+func newRMWStats() *RMWStats {
+	return &RMWStats{
+		OpDistanceHistogram: make(map[uint64]uint64),
+		CacheBlockHistogram: make(map[uint64]uint64),
+	}
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64) map[string]*RMWStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	rmwStats := make(map[string]*RMWStats)
+	lastWrites := make(map[string]uint64) // Block of the last write of each key
+	blockReads := make(map[string]ReadRecord)
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount, opIndex uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, tracked keys: %d, elapsed time: %.2fs", lineCount, currentBlockID, len(lastWrites), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			// Only the reads in the same block are matched with the updates
+			blockReads = make(map[string]ReadRecord)
+			opIndex = 0
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		opType, hexKey := matches[1], strings.ToLower(matches[2])
+		opIndex++
+
+		// The writes before the range are still tracked, so that the write-through cache is warm at the start
+		switch opType {
+		case "Put", "BatchPut":
+			lastWrites[hexKey] = currentBlockID
+			continue
+		case "Delete", "BatchDelete":
+			delete(lastWrites, hexKey)
+			continue
+		}
+		if !foundStartBlock {
+			if opType == "Update" {
+				lastWrites[hexKey] = currentBlockID
+			}
+			continue
+		}
+
+		switch opType {
+		case "Get":
+			category := matchPrefix(hexKey)
+			if _, exists := rmwStats[category]; !exists {
+				rmwStats[category] = newRMWStats()
+			}
+			rmwStats[category].ReadCount++
+			writtenBlock, written := lastWrites[hexKey]
+			blockReads[hexKey] = ReadRecord{OpIndex: opIndex, Written: written, WrittenBlock: writtenBlock}
+		case "Update":
+			category := matchPrefix(hexKey)
+			if _, exists := rmwStats[category]; !exists {
+				rmwStats[category] = newRMWStats()
+			}
+			rs := rmwStats[category]
+			rs.UpdateCount++
+			lastWrites[hexKey] = currentBlockID
+
+			if record, exists := blockReads[hexKey]; exists {
+				rs.SameKeyReadCount++
+				rs.OpDistanceHistogram[powerOfTwoBucket(opIndex-record.OpIndex)]++
+				if record.Written {
+					rs.CachedReadCount++
+					rs.CacheBlockHistogram[currentBlockID-record.WrittenBlock]++
+				}
+				// The read is consumed by this update, a later update of the key does not need it again
+				delete(blockReads, hexKey)
+				continue
+			}
+
+			pathStart := triePathStart(hexKey)
+			if pathStart < 0 {
+				continue
+			}
+			// Walk up the trie, one nibble (one byte in the key) at a time
+			for end := len(hexKey) - 2; end >= pathStart; end -= 2 {
+				if _, exists := blockReads[hexKey[:end]]; exists {
+					if end == len(hexKey)-2 {
+						rs.ParentReadCount++
+					} else {
+						rs.AncestorReadCount++
+					}
+					break
+				}
+			}
+		}
+	}
+	return rmwStats
+}
+
+// fractionWithin returns the fraction of the total whose distance is not larger than the threshold
+func fractionWithin(histogram map[uint64]uint64, threshold, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	var count uint64
+	for bucket, bucketCount := range histogram {
+		if bucket <= threshold {
+			count += bucketCount
+		}
+	}
+	return float64(count) / float64(total)
+}
+
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func sortedCategories(rmwStats map[string]*RMWStats) []string {
+	categories := make([]string, 0, len(rmwStats))
+	for category := range rmwStats {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// totalStats sums up the statistics of all categories
+func totalStats(rmwStats map[string]*RMWStats) *RMWStats {
+	total := newRMWStats()
+	for _, rs := range rmwStats {
+		total.ReadCount += rs.ReadCount
+		total.UpdateCount += rs.UpdateCount
+		total.SameKeyReadCount += rs.SameKeyReadCount
+		total.ParentReadCount += rs.ParentReadCount
+		total.AncestorReadCount += rs.AncestorReadCount
+		total.CachedReadCount += rs.CachedReadCount
+		for bucket, count := range rs.OpDistanceHistogram {
+			total.OpDistanceHistogram[bucket] += count
+		}
+		for bucket, count := range rs.CacheBlockHistogram {
+			total.CacheBlockHistogram[bucket] += count
+		}
+	}
+	return total
+}
+
+func printStats(outputFile *os.File, rmwStats map[string]*RMWStats) {
+	fmt.Fprintln(outputFile, "Read-modify-write patterns of KV operations (reads before updates in the same block):")
+	for _, category := range sortedCategories(rmwStats) {
+		printCategory(outputFile, category, rmwStats[category])
+	}
+	printCategory(outputFile, "All", totalStats(rmwStats))
+}
+
+// rmwRecords returns the records of the categories with updates, followed by the total
+func rmwRecords(rmwStats map[string]*RMWStats) []RMWRecord {
+	var records []RMWRecord
+	categories := append(sortedCategories(rmwStats), "All")
+	for _, category := range categories {
+		rs, exists := rmwStats[category]
+		if !exists {
+			rs = totalStats(rmwStats)
+		}
+		if rs.UpdateCount == 0 {
+			continue
+		}
+		record := RMWRecord{
+			Category:          category,
+			ReadCount:         rs.ReadCount,
+			UpdateCount:       rs.UpdateCount,
+			SameKeyReadCount:  rs.SameKeyReadCount,
+			ParentReadCount:   rs.ParentReadCount,
+			AncestorReadCount: rs.AncestorReadCount,
+			NoReadCount:       rs.UpdateCount - rs.SameKeyReadCount - rs.ParentReadCount - rs.AncestorReadCount,
+			CachedReadCount:   rs.CachedReadCount,
+		}
+		for _, threshold := range opThresholds {
+			record.SameKeyWithinOps = append(record.SameKeyWithinOps, fractionWithin(rs.OpDistanceHistogram, threshold, rs.SameKeyReadCount))
+		}
+		for _, threshold := range blockThresholds {
+			record.CachedWithinBlocks = append(record.CachedWithinBlocks, fractionWithin(rs.CacheBlockHistogram, threshold, rs.SameKeyReadCount))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func printCategory(outputFile *os.File, category string, rs *RMWStats) {
+	if rs.UpdateCount == 0 {
+		return
+	}
+	noReadCount := rs.UpdateCount - rs.SameKeyReadCount - rs.ParentReadCount - rs.AncestorReadCount
+	fmt.Fprintf(outputFile, "Category: %s\n", category)
+	fmt.Fprintf(outputFile, "  Read count: %d\n", rs.ReadCount)
+	fmt.Fprintf(outputFile, "  Update count: %d\n", rs.UpdateCount)
+	fmt.Fprintf(outputFile, "  Updates with same-key read: %d (%.6f)\n", rs.SameKeyReadCount, ratio(rs.SameKeyReadCount, rs.UpdateCount))
+	fmt.Fprintf(outputFile, "  Updates with trie parent path read: %d (%.6f)\n", rs.ParentReadCount, ratio(rs.ParentReadCount, rs.UpdateCount))
+	fmt.Fprintf(outputFile, "  Updates with trie ancestor path read: %d (%.6f)\n", rs.AncestorReadCount, ratio(rs.AncestorReadCount, rs.UpdateCount))
+	fmt.Fprintf(outputFile, "  Updates without read: %d (%.6f)\n", noReadCount, ratio(noReadCount, rs.UpdateCount))
+	fmt.Fprintf(outputFile, "  Reads followed by update of the same key: %.6f\n", ratio(rs.SameKeyReadCount, rs.ReadCount))
+	for _, threshold := range opThresholds {
+		fmt.Fprintf(outputFile, "  Same-key reads within %d ops before the update: %.6f\n", threshold, fractionWithin(rs.OpDistanceHistogram, threshold, rs.SameKeyReadCount))
+	}
+	fmt.Fprintf(outputFile, "  Same-key reads served by a write-through cache: %d (%.6f)\n", rs.CachedReadCount, ratio(rs.CachedReadCount, rs.SameKeyReadCount))
+	for _, threshold := range blockThresholds {
+		fmt.Fprintf(outputFile, "  Same-key reads written within %d blocks: %.6f\n", threshold, fractionWithin(rs.CacheBlockHistogram, threshold, rs.SameKeyReadCount))
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if flag.NArg() < 4 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <update_enhanced_log_file_path> <print_progress_interval> <start_block_number> <end_block_number>")
+		return
+	}
+	logFilePath := flag.Arg(0)
+	progressInterval, _ := strconv.ParseUint(flag.Arg(1), 10, 64)
+	startBlockNumber, _ := strconv.ParseUint(flag.Arg(2), 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(flag.Arg(3), 10, 64)
+	if progressInterval == 0 {
+		progressInterval = 1000
+	}
+
+	rmwStats := processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber)
+
+	outPutLogPath := "readModifyWrite-" + strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10) + ".txt"
+	if *format != "text" {
+		if err := writeRecords(outputPath(outPutLogPath, *format), *format, rmwRecords(rmwStats)); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	printStats(file, rmwStats)
+	fmt.Printf("Statistics are stored to: %s\n", outPutLogPath)
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// BlockOps stores the operations of a block, counted by "<category>:<OPType>"
+type BlockOps struct {
+	BlockID uint64
+	Counts  map[string]uint64
+	Keys    map[string]uint32 // Access count of each key (raw bytes) in the block
+	Ops     uint64
+	Writes  uint64
+}
+
+// TraceReader reads a trace block by block, and records the accessed keys of the committed blocks
+type TraceReader struct {
+	label            string
+	reader           *bufio.Reader
+	pendingID        uint64 // Block ID of the start marker read ahead by the previous block
+	hasPending       bool
+	lineCount        uint64
+	progressInterval uint64
+	start            time.Time
+	keyCounts        map[string]uint32 // Access count of each key (raw bytes) in the committed (aligned) blocks
+}
+
+// RatioStats accumulates the per-block absorption ratios of a series, as a histogram of clamped ratios
+type RatioStats struct {
+	Blocks    uint64
+	Sum       float64
+	Histogram []uint64
+}
+
+// FlaggedBlock is a block where the cached run did more disk I/O than the bare run
+type FlaggedBlock struct {
+	BlockID      uint64
+	BareOps      uint64
+	CacheOps     uint64
+	BareWrites   uint64
+	CacheWrites  uint64
+	ExcessWrites int64
+}
+
+// BlockDiffRecord is a record of traceDiff-blocks-<start>_<end>.{json,csv}
+type BlockDiffRecord struct {
+	BlockID      uint64  `json:"block_id"`
+	BareOps      uint64  `json:"bare_ops"`
+	CacheOps     uint64  `json:"cache_ops"`
+	BareWrites   uint64  `json:"bare_writes"`
+	CacheWrites  uint64  `json:"cache_writes"`
+	Absorption   float64 `json:"absorption"`
+	CacheHeavier bool    `json:"cache_heavier"`
+}
+
+// AbsorptionRecord is a record of traceDiff-<start>_<end>.{json,csv}, the block ratios are null if the bare run
+// never accesses the series
+type AbsorptionRecord struct {
+	Category        string  `json:"category"`
+	OpType          string  `json:"op_type"`
+	BareCount       uint64  `json:"bare_count"`
+	CacheCount      uint64  `json:"cache_count"`
+	Absorbed        int64   `json:"absorbed"`
+	AbsorptionRatio float64 `json:"absorption_ratio"`
+	BlockRatioMean  float64 `json:"block_ratio_mean"`
+	BlockRatioP10   float64 `json:"block_ratio_p10"`
+	BlockRatioP50   float64 `json:"block_ratio_p50"`
+	BlockRatioP90   float64 `json:"block_ratio_p90"`
+}
+
+// NeverOnDiskRecord is a record of traceDiff-neverOnDiskCategories-<start>_<end>.{json,csv}
+type NeverOnDiskRecord struct {
+	Category            string  `json:"category"`
+	BareDistinctKeys    uint64  `json:"bare_distinct_keys"`
+	NeverOnDiskKeys     uint64  `json:"never_on_disk_keys"`
+	NeverOnDiskRatio    float64 `json:"never_on_disk_ratio"`
+	NeverOnDiskAccesses uint64  `json:"never_on_disk_accesses"`
+}
+
+// FlaggedRecord is a record of traceDiff-flagged-<start>_<end>.{json,csv}, sorted by the excess writes
+type FlaggedRecord struct {
+	BlockID      uint64 `json:"block_id"`
+	BareOps      uint64 `json:"bare_ops"`
+	CacheOps     uint64 `json:"cache_ops"`
+	BareWrites   uint64 `json:"bare_writes"`
+	CacheWrites  uint64 `json:"cache_writes"`
+	ExcessWrites int64  `json:"excess_writes"`
+}
+
+// KeyRecord is a record of traceDiff-neverOnDisk-<start>_<end>.{json,csv}
+type KeyRecord struct {
+	Key          string `json:"key"`
+	Category     string `json:"category"`
+	BareAccesses uint32 `json:"bare_accesses"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), (?:key: ([a-fA-F0-9]+)|prefix: ([a-fA-F0-9]*))`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+
+	writeOpTypes = map[string]bool{"Put": true, "BatchPut": true, "Update": true, "Delete": true, "BatchDelete": true}
+)
+
+const (
+	// The absorption ratio 1 - cache/bare is clamped to [ratioMin, 1] and bucketed by ratioBucketWidth
+	ratioMin         = -1.0
+	ratioBucketWidth = 0.01
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func NewTraceReader(label string, file *os.File, progressInterval uint64) *TraceReader {
+	return &TraceReader{
+		label:            label,
+		reader:           bufio.NewReader(file),
+		progressInterval: progressInterval,
+		start:            time.Now(),
+		keyCounts:        make(map[string]uint32),
+	}
+}
+
+// commit adds the key accesses of the block, so that only the blocks aligned in both traces are compared by key
+func (tr *TraceReader) commit(block *BlockOps) {
+	for key, count := range block.Keys {
+		tr.keyCounts[key] += count
+	}
+}
+
+// NextBlock returns the operations of the next block whose ID is at least startBlockNumber, or false at the end
+// of the trace or after endBlockNumber
+func (tr *TraceReader) NextBlock(startBlockNumber, endBlockNumber uint64) (*BlockOps, bool) {
+	var block *BlockOps
+	if tr.hasPending {
+		tr.hasPending = false
+		if tr.pendingID > endBlockNumber {
+			return nil, false
+		}
+		if tr.pendingID >= startBlockNumber {
+			block = &BlockOps{BlockID: tr.pendingID, Counts: make(map[string]uint64), Keys: make(map[string]uint32)}
+		}
+	}
+	for {
+		line, err := tr.reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("Error reading file:", err)
+			}
+			return block, block != nil
+		}
+
+		tr.lineCount++
+		if tr.lineCount%tr.progressInterval == 0 && block != nil {
+			elapsed := time.Since(tr.start).Seconds()
+			fmt.Printf("\r%s: processed %d lines, current block ID: %d, distinct keys: %d, elapsed time: %.2fs", tr.label, tr.lineCount, block.BlockID, len(tr.keyCounts), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if block != nil {
+				// Keep the start marker for the next call
+				tr.pendingID, tr.hasPending = id, true
+				return block, true
+			}
+			if id > endBlockNumber {
+				return nil, false
+			}
+			if id >= startBlockNumber {
+				block = &BlockOps{BlockID: id, Counts: make(map[string]uint64), Keys: make(map[string]uint32)}
+			}
+			continue
+		}
+		if block == nil {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		opType := matches[1]
+		category := "noPrefix"
+		if matches[2] != "" {
+			category = matchPrefix(matches[2])
+			rawKey, err := hex.DecodeString(matches[2])
+			if err == nil {
+				block.Keys[string(rawKey)]++
+			}
+		} else if matches[3] != "" {
+			category = matchPrefix(matches[3])
+		}
+		block.Counts[category+":"+opType]++
+		block.Ops++
+		if writeOpTypes[opType] {
+			block.Writes++
+		}
+	}
+}
+
+func (rs *RatioStats) Add(ratio float64) {
+	rs.Blocks++
+	rs.Sum += ratio
+	bucket := int(math.Floor((math.Max(ratio, ratioMin) - ratioMin) / ratioBucketWidth))
+	if bucket >= len(rs.Histogram) {
+		bucket = len(rs.Histogram) - 1
+	}
+	rs.Histogram[bucket]++
+}
+
+// Percentile returns the lower bound of the bucket that contains the percentile
+func (rs *RatioStats) Percentile(p float64) float64 {
+	target := uint64(math.Ceil(p * float64(rs.Blocks)))
+	var accumulated uint64
+	for bucket, count := range rs.Histogram {
+		accumulated += count
+		if accumulated >= max(target, 1) {
+			return ratioMin + float64(bucket)*ratioBucketWidth
+		}
+	}
+	return 1
+}
+
+func newRatioStats() *RatioStats {
+	return &RatioStats{Histogram: make([]uint64, int((1-ratioMin)/ratioBucketWidth)+1)}
+}
+
+func absorption(bare, cache uint64) float64 {
+	return 1 - float64(cache)/float64(bare)
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 5 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <bare_trace_path> <cache_trace_path> <start_block_number> <end_block_number> [top_keys] [print_progress_interval]")
+		return
+	}
+	bareTracePath, cacheTracePath := args[1], args[2]
+	startBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[4], 10, 64)
+	topKeys := 10000
+	if len(args) > 5 {
+		topKeys, _ = strconv.Atoi(args[5])
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 6 {
+		progressInterval, _ = strconv.ParseUint(args[6], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+
+	bareFile, err := os.Open(bareTracePath)
+	if err != nil {
+		fmt.Println("Error opening file:", bareTracePath)
+		return
+	}
+	defer bareFile.Close()
+	cacheFile, err := os.Open(cacheTracePath)
+	if err != nil {
+		fmt.Println("Error opening file:", cacheTracePath)
+		return
+	}
+	defer cacheFile.Close()
+	bare := NewTraceReader("BareTrace", bareFile, progressInterval)
+	cache := NewTraceReader("CacheTrace", cacheFile, progressInterval)
+
+	// In text mode the blocks are written as CSV with the original header, the other formats use BlockDiffRecord
+	blocksPath := outputPath("traceDiff-blocks-"+rangeName+".csv", *format)
+	if *format == "text" {
+		blocksPath = "traceDiff-blocks-" + rangeName + ".csv"
+	}
+	var blocksWriter *bufio.Writer
+	var blockRecords *RecordWriter
+	if *format != "text" {
+		blockRecords, err = newRecordWriter(blocksPath, *format, BlockDiffRecord{})
+		if err != nil {
+			fmt.Println("Error creating output file:", blocksPath)
+			return
+		}
+		defer blockRecords.Close()
+	} else {
+		blocksFile, err := os.Create(blocksPath)
+		if err != nil {
+			fmt.Println("Error creating output file:", blocksPath)
+			return
+		}
+		defer blocksFile.Close()
+		blocksWriter = bufio.NewWriter(blocksFile)
+		fmt.Fprintln(blocksWriter, "BlockID,BareOps,CacheOps,BareWrites,CacheWrites,Absorption,CacheHeavier")
+	}
+
+	bareTotals := make(map[string]uint64)
+	cacheTotals := make(map[string]uint64)
+	ratioStats := make(map[string]*RatioStats)
+	var flagged []FlaggedBlock
+	var alignedBlocks, bareOnlyBlocks, cacheOnlyBlocks uint64
+
+	// Align the two traces by block ID, advancing the one that is behind
+	bareBlock, bareOK := bare.NextBlock(startBlockNumber, endBlockNumber)
+	cacheBlock, cacheOK := cache.NextBlock(startBlockNumber, endBlockNumber)
+	for bareOK || cacheOK {
+		if !cacheOK || (bareOK && bareBlock.BlockID < cacheBlock.BlockID) {
+			bareOnlyBlocks++
+			bareBlock, bareOK = bare.NextBlock(startBlockNumber, endBlockNumber)
+			continue
+		}
+		if !bareOK || cacheBlock.BlockID < bareBlock.BlockID {
+			cacheOnlyBlocks++
+			cacheBlock, cacheOK = cache.NextBlock(startBlockNumber, endBlockNumber)
+			continue
+		}
+
+		alignedBlocks++
+		bare.commit(bareBlock)
+		cache.commit(cacheBlock)
+		for series, count := range bareBlock.Counts {
+			bareTotals[series] += count
+		}
+		for series, count := range cacheBlock.Counts {
+			cacheTotals[series] += count
+		}
+		// The per-block ratio is only defined for the series that the bare run accesses in the block
+		for series, count := range bareBlock.Counts {
+			if _, exists := ratioStats[series]; !exists {
+				ratioStats[series] = newRatioStats()
+			}
+			ratioStats[series].Add(absorption(count, cacheBlock.Counts[series]))
+		}
+
+		heavier := cacheBlock.Ops > bareBlock.Ops || cacheBlock.Writes > bareBlock.Writes
+		blockAbsorption := 0.0
+		if bareBlock.Ops > 0 {
+			blockAbsorption = absorption(bareBlock.Ops, cacheBlock.Ops)
+		}
+		heavierFlag := 0
+		if heavier {
+			heavierFlag = 1
+			flagged = append(flagged, FlaggedBlock{
+				BlockID:      bareBlock.BlockID,
+				BareOps:      bareBlock.Ops,
+				CacheOps:     cacheBlock.Ops,
+				BareWrites:   bareBlock.Writes,
+				CacheWrites:  cacheBlock.Writes,
+				ExcessWrites: int64(cacheBlock.Writes) - int64(bareBlock.Writes),
+			})
+		}
+		if blockRecords != nil {
+			if err := blockRecords.Write(BlockDiffRecord{
+				BlockID:      bareBlock.BlockID,
+				BareOps:      bareBlock.Ops,
+				CacheOps:     cacheBlock.Ops,
+				BareWrites:   bareBlock.Writes,
+				CacheWrites:  cacheBlock.Writes,
+				Absorption:   blockAbsorption,
+				CacheHeavier: heavier,
+			}); err != nil {
+				log.Fatalf("Cannot write to %s: %v", blocksPath, err)
+			}
+		} else {
+			fmt.Fprintf(blocksWriter, "%d,%d,%d,%d,%d,%.6f,%d\n", bareBlock.BlockID, bareBlock.Ops, cacheBlock.Ops, bareBlock.Writes, cacheBlock.Writes, blockAbsorption, heavierFlag)
+		}
+
+		bareBlock, bareOK = bare.NextBlock(startBlockNumber, endBlockNumber)
+		cacheBlock, cacheOK = cache.NextBlock(startBlockNumber, endBlockNumber)
+	}
+	if blocksWriter != nil {
+		blocksWriter.Flush()
+	}
+	fmt.Println()
+
+	// Keys accessed in BareTrace that never reach disk in CacheTrace
+	bareOnlyKeys := make([]string, 0)
+	bareOnlyDistinct := make(map[string]uint64)
+	bareOnlyAccesses := make(map[string]uint64)
+	for key, count := range bare.keyCounts {
+		if _, exists := cache.keyCounts[key]; exists {
+			continue
+		}
+		category := matchPrefix(hex.EncodeToString([]byte(key)))
+		bareOnlyKeys = append(bareOnlyKeys, key)
+		bareOnlyDistinct[category]++
+		bareOnlyAccesses[category] += uint64(count)
+	}
+	bareDistinct := make(map[string]uint64)
+	for key := range bare.keyCounts {
+		bareDistinct[matchPrefix(hex.EncodeToString([]byte(key)))]++
+	}
+
+	seriesSet := make(map[string]struct{})
+	for series := range bareTotals {
+		seriesSet[series] = struct{}{}
+	}
+	for series := range cacheTotals {
+		seriesSet[series] = struct{}{}
+	}
+	seriesNames := make([]string, 0, len(seriesSet))
+	for series := range seriesSet {
+		seriesNames = append(seriesNames, series)
+	}
+	sort.Strings(seriesNames)
+	var absorptionRecords []AbsorptionRecord
+	for _, series := range seriesNames {
+		parts := strings.SplitN(series, ":", 2)
+		bareCount, cacheCount := bareTotals[series], cacheTotals[series]
+		record := AbsorptionRecord{
+			Category:        parts[0],
+			OpType:          parts[1],
+			BareCount:       bareCount,
+			CacheCount:      cacheCount,
+			Absorbed:        int64(bareCount) - int64(cacheCount),
+			AbsorptionRatio: math.NaN(),
+			BlockRatioMean:  math.NaN(),
+			BlockRatioP10:   math.NaN(),
+			BlockRatioP50:   math.NaN(),
+			BlockRatioP90:   math.NaN(),
+		}
+		if bareCount > 0 {
+			record.AbsorptionRatio = absorption(bareCount, cacheCount)
+		}
+		if rs, exists := ratioStats[series]; exists {
+			record.BlockRatioMean = rs.Sum / float64(rs.Blocks)
+			record.BlockRatioP10, record.BlockRatioP50, record.BlockRatioP90 = rs.Percentile(0.1), rs.Percentile(0.5), rs.Percentile(0.9)
+		}
+		absorptionRecords = append(absorptionRecords, record)
+	}
+
+	categories := make([]string, 0, len(bareDistinct))
+	for category := range bareDistinct {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	var neverOnDiskRecords []NeverOnDiskRecord
+	for _, category := range categories {
+		neverOnDiskRecords = append(neverOnDiskRecords, NeverOnDiskRecord{
+			Category:            category,
+			BareDistinctKeys:    bareDistinct[category],
+			NeverOnDiskKeys:     bareOnlyDistinct[category],
+			NeverOnDiskRatio:    float64(bareOnlyDistinct[category]) / float64(bareDistinct[category]),
+			NeverOnDiskAccesses: bareOnlyAccesses[category],
+		})
+	}
+
+	sort.Slice(flagged, func(i, j int) bool {
+		if flagged[i].ExcessWrites != flagged[j].ExcessWrites {
+			return flagged[i].ExcessWrites > flagged[j].ExcessWrites
+		}
+		return flagged[i].BlockID < flagged[j].BlockID
+	})
+
+	sort.Slice(bareOnlyKeys, func(i, j int) bool {
+		ci, cj := bare.keyCounts[bareOnlyKeys[i]], bare.keyCounts[bareOnlyKeys[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return bareOnlyKeys[i] < bareOnlyKeys[j]
+	})
+	var keyRecords []KeyRecord
+	for i, key := range bareOnlyKeys {
+		if i >= topKeys {
+			break
+		}
+		hexKey := hex.EncodeToString([]byte(key))
+		keyRecords = append(keyRecords, KeyRecord{Key: hexKey, Category: matchPrefix(hexKey), BareAccesses: bare.keyCounts[key]})
+	}
+
+	outPutLogPath := "traceDiff-" + rangeName + ".txt"
+	keysPath := "traceDiff-neverOnDisk-" + rangeName + ".txt"
+	if *format != "text" {
+		fmt.Printf("Aligned blocks: %d, BareTrace only: %d, CacheTrace only: %d\n", alignedBlocks, bareOnlyBlocks, cacheOnlyBlocks)
+		flaggedRecords := make([]FlaggedRecord, 0, len(flagged))
+		for _, block := range flagged {
+			flaggedRecords = append(flaggedRecords, FlaggedRecord(block))
+		}
+		if err := writeRecords(outputPath(outPutLogPath, *format), *format, absorptionRecords); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := writeRecords(outputPath("traceDiff-neverOnDiskCategories-"+rangeName+".txt", *format), *format, neverOnDiskRecords); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := writeRecords(outputPath("traceDiff-flagged-"+rangeName+".txt", *format), *format, flaggedRecords); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := writeRecords(outputPath(keysPath, *format), *format, keyRecords); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "Aligned blocks: %d, BareTrace only: %d, CacheTrace only: %d\n", alignedBlocks, bareOnlyBlocks, cacheOnlyBlocks)
+	fmt.Fprintln(file, "Operations absorbed by caches (aligned blocks):")
+	fmt.Fprintln(file, "Category OPType BareCount CacheCount Absorbed AbsorptionRatio BlockRatioMean BlockRatioP10 BlockRatioP50 BlockRatioP90")
+	for _, r := range absorptionRecords {
+		fmt.Fprintf(file, "%s %s %d %d %d %.6f %.6f %.2f %.2f %.2f\n", r.Category, r.OpType, r.BareCount, r.CacheCount, r.Absorbed,
+			r.AbsorptionRatio, r.BlockRatioMean, r.BlockRatioP10, r.BlockRatioP50, r.BlockRatioP90)
+	}
+
+	fmt.Fprintln(file, "Keys accessed in BareTrace that never reach disk in CacheTrace:")
+	fmt.Fprintln(file, "Category BareDistinctKeys NeverOnDiskKeys NeverOnDiskRatio NeverOnDiskAccesses")
+	for _, r := range neverOnDiskRecords {
+		fmt.Fprintf(file, "%s %d %d %.6f %d\n", r.Category, r.BareDistinctKeys, r.NeverOnDiskKeys, r.NeverOnDiskRatio, r.NeverOnDiskAccesses)
+	}
+
+	fmt.Fprintf(file, "Blocks where CacheTrace did more disk I/O than BareTrace: %d\n", len(flagged))
+	fmt.Fprintln(file, "BlockID BareOps CacheOps BareWrites CacheWrites ExcessWrites")
+	for i, block := range flagged {
+		if i >= 100 {
+			break
+		}
+		fmt.Fprintf(file, "%d %d %d %d %d %d\n", block.BlockID, block.BareOps, block.CacheOps, block.BareWrites, block.CacheWrites, block.ExcessWrites)
+	}
+
+	keysFile, err := os.Create(keysPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", keysPath)
+		return
+	}
+	defer keysFile.Close()
+	keysWriter := bufio.NewWriter(keysFile)
+	fmt.Fprintln(keysWriter, "Key Category BareAccesses")
+	for _, r := range keyRecords {
+		fmt.Fprintf(keysWriter, "%s %s %d\n", r.Key, r.Category, r.BareAccesses)
+	}
+	keysWriter.Flush()
+	fmt.Printf("Statistics are stored to: %s, %s, and %s\n", outPutLogPath, blocksPath, keysPath)
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// TrieNode is a decoded trie node key, the path stores one nibble per byte (two hex characters)
+type TrieNode struct {
+	Trie      string // "Account" or "Storage"
+	Owner     string // Account hash of the storage trie, empty for the account trie
+	PathStart int    // Offset of the path in the hex key
+	Depth     int    // Number of nibbles in the path, 0 is the root
+}
+
+// WalkStats stores, for the accesses at a depth, how many of their ancestors were accessed earlier in the block
+type WalkStats struct {
+	Accesses         uint64
+	FullWalks        uint64 // Accesses whose ancestors at all levels were accessed earlier in the block
+	AncestorsPresent uint64 // Sum of the number of ancestors accessed earlier in the block
+}
+
+// CoAccessStats stores the structural relation of the trie nodes accessed in the same block, and of consecutive accesses
+type CoAccessStats struct {
+	Nodes               uint64 // Distinct non-root nodes accessed per block, summed over blocks
+	ParentAccessed      uint64 // ... whose parent is accessed in the same block
+	SiblingAccessed     uint64 // ... with at least one sibling (same parent) accessed in the same block
+	ConsecutivePairs    uint64 // Consecutive accesses within the same trie
+	ConsecutiveParent   uint64 // ... where one is the parent of the other
+	ConsecutiveAncestor uint64 // ... where one is a further ancestor of the other
+	ConsecutiveSibling  uint64 // ... with the same parent
+	ConsecutiveSame     uint64 // ... of the same node
+}
+
+// OwnerStats stores the accesses of a storage trie
+type OwnerStats struct {
+	Accesses uint64
+	DepthSum uint64
+	MaxDepth int
+}
+
+// DepthRecord is a record of trieStructure-<op>-<start>_<end>-depth.{json,csv}
+type DepthRecord struct {
+	Trie   string `json:"trie"`
+	OpType string `json:"op_type"`
+	Depth  int    `json:"depth"`
+	Count  uint64 `json:"count"`
+}
+
+// WalkRecord is a record of trieStructure-<op>-<start>_<end>-walk.{json,csv}
+type WalkRecord struct {
+	Trie             string  `json:"trie"`
+	Depth            int     `json:"depth"`
+	Accesses         uint64  `json:"accesses"`
+	FullWalkRatio    float64 `json:"full_walk_ratio"`
+	AvgLevelsPresent float64 `json:"avg_levels_present"`
+}
+
+// LevelRecord is a record of trieStructure-<op>-<start>_<end>-level.{json,csv}
+type LevelRecord struct {
+	Trie     string  `json:"trie"`
+	Level    int     `json:"level"`
+	Accesses uint64  `json:"accesses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// CoAccessRecord is a record of trieStructure-<op>-<start>_<end>-coaccess.{json,csv}, the block ratios are over
+// the nodes and the consecutive ratios over the consecutive pairs
+type CoAccessRecord struct {
+	Trie                string  `json:"trie"`
+	Nodes               uint64  `json:"nodes"`
+	ParentAccessed      float64 `json:"parent_accessed"`
+	SiblingAccessed     float64 `json:"sibling_accessed"`
+	ConsecutivePairs    uint64  `json:"consecutive_pairs"`
+	ConsecutiveParent   float64 `json:"consecutive_parent"`
+	ConsecutiveAncestor float64 `json:"consecutive_ancestor"`
+	ConsecutiveSibling  float64 `json:"consecutive_sibling"`
+	ConsecutiveSame     float64 `json:"consecutive_same"`
+}
+
+// OwnerRecord is a record of trieStorageDepth-<start>_<end>.{json,csv}
+type OwnerRecord struct {
+	AccountHash string  `json:"account_hash"`
+	Accesses    uint64  `json:"accesses"`
+	MaxDepth    int     `json:"max_depth"`
+	MeanDepth   float64 `json:"mean_depth"`
+}
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	opLineRegex     = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+)`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+const (
+	// Trie node keys: "A" + path for the account trie, "O" + account hash + path for the storage tries
+	accountTriePrefix = "41"
+	storageTriePrefix = "4f"
+	accountHashHexLen = 64
+	// The depth of a path never exceeds 64 nibbles
+	maxTrieDepth = 64
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+// decodeTrieNode decodes the hex key of a trie node, returning false if it is not a path-based trie node key
+func decodeTrieNode(hexKey string) (TrieNode, bool) {
+	var node TrieNode
+	switch {
+	case strings.HasPrefix(hexKey, accountTriePrefix):
+		node = TrieNode{Trie: "Account", PathStart: len(accountTriePrefix)}
+	case strings.HasPrefix(hexKey, storageTriePrefix) && len(hexKey) >= len(storageTriePrefix)+accountHashHexLen:
+		node = TrieNode{Trie: "Storage", Owner: hexKey[len(storageTriePrefix) : len(storageTriePrefix)+accountHashHexLen], PathStart: len(storageTriePrefix) + accountHashHexLen}
+	default:
+		return node, false
+	}
+	path := hexKey[node.PathStart:]
+	if len(path)%2 != 0 || len(path)/2 > maxTrieDepth {
+		return node, false
+	}
+	// Each byte of the path is a nibble
+	for i := 0; i < len(path); i += 2 {
+		if path[i] != '0' {
+			return node, false
+		}
+	}
+	node.Depth = len(path) / 2
+	return node, true
+}
+
+// parentKey returns the key of the parent node, the caller must ensure the depth is at least 1
+func parentKey(hexKey string) string {
+	return hexKey[:len(hexKey)-2]
+}
+
+// analysis holds the state and results of the trie structure analysis
+type analysis struct {
+	opType string
+
+	depthCounts  map[string]map[string][]uint64 // Trie -> OPType -> access count by depth
+	invalidCount map[string]uint64              // Trie node category keys that cannot be decoded as paths
+	walkStats    map[string][]WalkStats         // Trie -> depth
+	levelHits    map[string][]uint64            // Trie -> level, accesses deeper than the level whose ancestor at the level was accessed earlier in the block
+	levelTotal   map[string][]uint64            // Trie -> level, accesses deeper than the level
+	coAccess     map[string]*CoAccessStats      // Trie
+	owners       map[string]*OwnerStats
+
+	blockNodes map[string]TrieNode // Nodes accessed in the current block
+	lastKey    string
+	lastNode   TrieNode
+}
+
+func newAnalysis(opType string) *analysis {
+	a := &analysis{
+		opType:       opType,
+		depthCounts:  make(map[string]map[string][]uint64),
+		invalidCount: make(map[string]uint64),
+		walkStats:    make(map[string][]WalkStats),
+		levelHits:    make(map[string][]uint64),
+		levelTotal:   make(map[string][]uint64),
+		coAccess:     make(map[string]*CoAccessStats),
+		owners:       make(map[string]*OwnerStats),
+		blockNodes:   make(map[string]TrieNode),
+	}
+	for _, trie := range []string{"Account", "Storage"} {
+		a.depthCounts[trie] = make(map[string][]uint64)
+		a.walkStats[trie] = make([]WalkStats, maxTrieDepth+1)
+		a.levelHits[trie] = make([]uint64, maxTrieDepth+1)
+		a.levelTotal[trie] = make([]uint64, maxTrieDepth+1)
+		a.coAccess[trie] = &CoAccessStats{}
+	}
+	return a
+}
+
+// endBlock computes the parent and sibling co-access of the nodes accessed in the block, then resets the block state
+func (a *analysis) endBlock() {
+	children := make(map[string]uint64)
+	for key, node := range a.blockNodes {
+		if node.Depth > 0 {
+			children[parentKey(key)]++
+		}
+	}
+	for key, node := range a.blockNodes {
+		if node.Depth == 0 {
+			continue
+		}
+		stats := a.coAccess[node.Trie]
+		stats.Nodes++
+		if _, exists := a.blockNodes[parentKey(key)]; exists {
+			stats.ParentAccessed++
+		}
+		if children[parentKey(key)] > 1 {
+			stats.SiblingAccessed++
+		}
+	}
+	a.blockNodes = make(map[string]TrieNode)
+	a.lastKey = ""
+}
+
+func (a *analysis) processOperation(opType, hexKey string) {
+	node, ok := decodeTrieNode(hexKey)
+	if !ok {
+		category := matchPrefix(hexKey)
+		if category == "TrieNodeAccountPrefix" || category == "TrieNodeStoragePrefix" {
+			a.invalidCount[category]++
+		}
+		return
+	}
+
+	if _, exists := a.depthCounts[node.Trie][opType]; !exists {
+		a.depthCounts[node.Trie][opType] = make([]uint64, maxTrieDepth+1)
+	}
+	a.depthCounts[node.Trie][opType][node.Depth]++
+	if node.Trie == "Storage" {
+		if _, exists := a.owners[node.Owner]; !exists {
+			a.owners[node.Owner] = &OwnerStats{}
+		}
+		owner := a.owners[node.Owner]
+		owner.Accesses++
+		owner.DepthSum += uint64(node.Depth)
+		if node.Depth > owner.MaxDepth {
+			owner.MaxDepth = node.Depth
+		}
+	}
+	if opType != a.opType {
+		return
+	}
+
+	// Check which levels of the root-to-node walk were accessed earlier in the block
+	walk := &a.walkStats[node.Trie][node.Depth]
+	walk.Accesses++
+	var present int
+	for depth := 0; depth < node.Depth; depth++ {
+		a.levelTotal[node.Trie][depth]++
+		if _, exists := a.blockNodes[hexKey[:node.PathStart+2*depth]]; exists {
+			a.levelHits[node.Trie][depth]++
+			present++
+		}
+	}
+	walk.AncestorsPresent += uint64(present)
+	if present == node.Depth {
+		walk.FullWalks++
+	}
+
+	// Classify the relation with the previous access if both are in the same trie
+	if a.lastKey != "" && a.lastNode.Trie == node.Trie && a.lastNode.Owner == node.Owner {
+		stats := a.coAccess[node.Trie]
+		stats.ConsecutivePairs++
+		shallow, deep := a.lastKey, hexKey
+		if len(shallow) > len(deep) {
+			shallow, deep = deep, shallow
+		}
+		switch {
+		case shallow == deep:
+			stats.ConsecutiveSame++
+		case len(deep) == len(shallow)+2 && strings.HasPrefix(deep, shallow):
+			stats.ConsecutiveParent++
+		case strings.HasPrefix(deep, shallow):
+			stats.ConsecutiveAncestor++
+		case len(deep) == len(shallow) && node.Depth > 0 && parentKey(deep) == parentKey(shallow):
+			stats.ConsecutiveSibling++
+		}
+	}
+	a.lastKey, a.lastNode = hexKey, node
+	a.blockNodes[hexKey] = node
+}
+
+func processLogFile(filePath string, progressInterval, startBlockNumber, endBlockNumber uint64, a *analysis) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, storage tries: %d, elapsed time: %.2fs", lineCount, currentBlockID, len(a.owners), elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if foundStartBlock {
+				a.endBlock()
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				return
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := opLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		a.processOperation(matches[1], strings.ToLower(matches[2]))
+	}
+	if foundStartBlock {
+		a.endBlock()
+	}
+}
+
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (a *analysis) printStats(outputFile *os.File) {
+	fmt.Fprintln(outputFile, "Access count by trie depth:")
+	fmt.Fprintln(outputFile, "Trie OPType Depth Count")
+	for _, trie := range sortedKeys(a.depthCounts) {
+		for _, opType := range sortedKeys(a.depthCounts[trie]) {
+			for depth, count := range a.depthCounts[trie][opType] {
+				if count > 0 {
+					fmt.Fprintf(outputFile, "%s %s %d %d\n", trie, opType, depth, count)
+				}
+			}
+		}
+	}
+	for _, category := range sortedKeys(a.invalidCount) {
+		fmt.Fprintf(outputFile, "Undecodable %s keys: %d\n", category, a.invalidCount[category])
+	}
+
+	fmt.Fprintf(outputFile, "Root-to-node walks of %s operations (ancestors accessed earlier in the same block):\n", a.opType)
+	fmt.Fprintln(outputFile, "Trie Depth Accesses FullWalkRatio AvgLevelsPresent")
+	for _, trie := range sortedKeys(a.walkStats) {
+		for depth, walk := range a.walkStats[trie] {
+			if walk.Accesses > 0 {
+				fmt.Fprintf(outputFile, "%s %d %d %.6f %.6f\n", trie, depth, walk.Accesses, ratio(walk.FullWalks, walk.Accesses), ratio(walk.AncestorsPresent, walk.Accesses*uint64(max(depth, 1))))
+			}
+		}
+	}
+	fmt.Fprintln(outputFile, "Per-level hit ratio (accesses deeper than the level whose ancestor at the level was accessed earlier in the block):")
+	fmt.Fprintln(outputFile, "Trie Level Accesses HitRatio")
+	for _, trie := range sortedKeys(a.levelTotal) {
+		for level, total := range a.levelTotal[trie] {
+			if total > 0 {
+				fmt.Fprintf(outputFile, "%s %d %d %.6f\n", trie, level, total, ratio(a.levelHits[trie][level], total))
+			}
+		}
+	}
+
+	fmt.Fprintf(outputFile, "Structural co-access of %s operations:\n", a.opType)
+	for _, trie := range sortedKeys(a.coAccess) {
+		stats := a.coAccess[trie]
+		fmt.Fprintf(outputFile, "Trie: %s\n", trie)
+		fmt.Fprintf(outputFile, "  Non-root nodes accessed per block (summed): %d\n", stats.Nodes)
+		fmt.Fprintf(outputFile, "  Parent accessed in the same block: %.6f\n", ratio(stats.ParentAccessed, stats.Nodes))
+		fmt.Fprintf(outputFile, "  Sibling accessed in the same block: %.6f\n", ratio(stats.SiblingAccessed, stats.Nodes))
+		fmt.Fprintf(outputFile, "  Consecutive accesses in the same trie: %d\n", stats.ConsecutivePairs)
+		fmt.Fprintf(outputFile, "  Consecutive parent-child: %.6f\n", ratio(stats.ConsecutiveParent, stats.ConsecutivePairs))
+		fmt.Fprintf(outputFile, "  Consecutive ancestor-descendant (non-parent): %.6f\n", ratio(stats.ConsecutiveAncestor, stats.ConsecutivePairs))
+		fmt.Fprintf(outputFile, "  Consecutive siblings: %.6f\n", ratio(stats.ConsecutiveSibling, stats.ConsecutivePairs))
+		fmt.Fprintf(outputFile, "  Consecutive same node: %.6f\n", ratio(stats.ConsecutiveSame, stats.ConsecutivePairs))
+	}
+
+	maxDepthContracts := make([]uint64, maxTrieDepth+1)
+	for _, owner := range a.owners {
+		maxDepthContracts[owner.MaxDepth]++
+	}
+	fmt.Fprintf(outputFile, "Storage trie max accessed depth across %d contracts:\n", len(a.owners))
+	fmt.Fprintln(outputFile, "MaxDepth Contracts")
+	for depth, count := range maxDepthContracts {
+		if count > 0 {
+			fmt.Fprintf(outputFile, "%d %d\n", depth, count)
+		}
+	}
+}
+
+// printOwners writes the storage trie depth of each contract, sorted by the number of accesses
+func (a *analysis) printOwners(outputFile *os.File) {
+	owners := sortedKeys(a.owners)
+	sort.SliceStable(owners, func(i, j int) bool {
+		return a.owners[owners[i]].Accesses > a.owners[owners[j]].Accesses
+	})
+	writer := bufio.NewWriter(outputFile)
+	fmt.Fprintln(writer, "AccountHash Accesses MaxDepth MeanDepth")
+	for _, owner := range owners {
+		stats := a.owners[owner]
+		fmt.Fprintf(writer, "%s %d %d %.4f\n", owner, stats.Accesses, stats.MaxDepth, ratio(stats.DepthSum, stats.Accesses))
+	}
+	writer.Flush()
+}
+
+// writeStructured writes the sections of the statistics to one file each, named after the text output
+func (a *analysis) writeStructured(outputFilePath, ownersPath, format string) error {
+	for _, category := range sortedKeys(a.invalidCount) {
+		fmt.Printf("Undecodable %s keys: %d\n", category, a.invalidCount[category])
+	}
+	prefix := strings.TrimSuffix(outputFilePath, filepath.Ext(outputFilePath))
+
+	var depthRecords []DepthRecord
+	for _, trie := range sortedKeys(a.depthCounts) {
+		for _, opType := range sortedKeys(a.depthCounts[trie]) {
+			for depth, count := range a.depthCounts[trie][opType] {
+				if count > 0 {
+					depthRecords = append(depthRecords, DepthRecord{Trie: trie, OpType: opType, Depth: depth, Count: count})
+				}
+			}
+		}
+	}
+	if err := writeRecords(prefix+"-depth."+format, format, depthRecords); err != nil {
+		return err
+	}
+
+	var walkRecords []WalkRecord
+	for _, trie := range sortedKeys(a.walkStats) {
+		for depth, walk := range a.walkStats[trie] {
+			if walk.Accesses > 0 {
+				walkRecords = append(walkRecords, WalkRecord{
+					Trie:             trie,
+					Depth:            depth,
+					Accesses:         walk.Accesses,
+					FullWalkRatio:    ratio(walk.FullWalks, walk.Accesses),
+					AvgLevelsPresent: ratio(walk.AncestorsPresent, walk.Accesses*uint64(max(depth, 1))),
+				})
+			}
+		}
+	}
+	if err := writeRecords(prefix+"-walk."+format, format, walkRecords); err != nil {
+		return err
+	}
+
+	var levelRecords []LevelRecord
+	for _, trie := range sortedKeys(a.levelTotal) {
+		for level, total := range a.levelTotal[trie] {
+			if total > 0 {
+				levelRecords = append(levelRecords, LevelRecord{Trie: trie, Level: level, Accesses: total, HitRatio: ratio(a.levelHits[trie][level], total)})
+			}
+		}
+	}
+	if err := writeRecords(prefix+"-level."+format, format, levelRecords); err != nil {
+		return err
+	}
+
+	var coAccessRecords []CoAccessRecord
+	for _, trie := range sortedKeys(a.coAccess) {
+		stats := a.coAccess[trie]
+		coAccessRecords = append(coAccessRecords, CoAccessRecord{
+			Trie:                trie,
+			Nodes:               stats.Nodes,
+			ParentAccessed:      ratio(stats.ParentAccessed, stats.Nodes),
+			SiblingAccessed:     ratio(stats.SiblingAccessed, stats.Nodes),
+			ConsecutivePairs:    stats.ConsecutivePairs,
+			ConsecutiveParent:   ratio(stats.ConsecutiveParent, stats.ConsecutivePairs),
+			ConsecutiveAncestor: ratio(stats.ConsecutiveAncestor, stats.ConsecutivePairs),
+			ConsecutiveSibling:  ratio(stats.ConsecutiveSibling, stats.ConsecutivePairs),
+			ConsecutiveSame:     ratio(stats.ConsecutiveSame, stats.ConsecutivePairs),
+		})
+	}
+	if err := writeRecords(prefix+"-coaccess."+format, format, coAccessRecords); err != nil {
+		return err
+	}
+
+	owners := sortedKeys(a.owners)
+	sort.SliceStable(owners, func(i, j int) bool {
+		return a.owners[owners[i]].Accesses > a.owners[owners[j]].Accesses
+	})
+	ownerRecords := make([]OwnerRecord, 0, len(owners))
+	for _, owner := range owners {
+		stats := a.owners[owner]
+		ownerRecords = append(ownerRecords, OwnerRecord{AccountHash: owner, Accesses: stats.Accesses, MaxDepth: stats.MaxDepth, MeanDepth: ratio(stats.DepthSum, stats.Accesses)})
+	}
+	return writeRecords(outputPath(ownersPath, format), format, ownerRecords)
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 4 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <start_block_number> <end_block_number> [op_type] [print_progress_interval]")
+		return
+	}
+	logFilePath := args[1]
+	startBlockNumber, _ := strconv.ParseUint(args[2], 10, 64)
+	endBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+	opType := "Get"
+	if len(args) > 4 {
+		opType = args[4]
+	}
+	progressInterval := uint64(100000)
+	if len(args) > 5 {
+		progressInterval, _ = strconv.ParseUint(args[5], 10, 64)
+	}
+	if progressInterval == 0 {
+		progressInterval = 100000
+	}
+
+	a := newAnalysis(opType)
+	processLogFile(logFilePath, progressInterval, startBlockNumber, endBlockNumber, a)
+
+	rangeName := strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	outPutLogPath := "trieStructure-" + opType + "-" + rangeName + ".txt"
+	ownersPath := "trieStorageDepth-" + rangeName + ".txt"
+	if *format != "text" {
+		if err := a.writeStructured(outPutLogPath, ownersPath, *format); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	file, err := os.Create(outPutLogPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", outPutLogPath)
+		return
+	}
+	defer file.Close()
+	a.printStats(file)
+
+	ownersFile, err := os.Create(ownersPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", ownersPath)
+		return
+	}
+	defer ownersFile.Close()
+	a.printOwners(ownersFile)
+	fmt.Printf("Statistics are stored to: %s and %s\n", outPutLogPath, ownersPath)
+}
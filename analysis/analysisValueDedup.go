@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+type PrefixCategory struct {
+	Prefix   string
+	Category string
+}
+
+// contentHash identifies a value by the first 16 bytes of its SHA-256
+type contentHash [16]byte
+
+// ValueEntry store the copies of a value. The example key and the value prefix are only kept once the value is
+// duplicated, so that the unique values stay small
+type ValueEntry struct {
+	Size         uint32
+	Copies       uint32 // Number of times the value is stored or written
+	DistinctKeys uint32 // Number of distinct keys with the value
+	ExampleKey   []byte
+	ValuePrefix  []byte
+}
+
+// DedupStats store the values of a category
+type DedupStats struct {
+	Values   uint64
+	Bytes    uint64
+	Entries  map[contentHash]*ValueEntry
+	keyPairs map[[2]contentHash]bool // Pairs of value and key hashes seen in the trace, for the distinct keys
+}
+
+// DedupRecord is a record of valueDedup-<source>.{json,csv}, the last record (All) is over all categories
+type DedupRecord struct {
+	Category           string  `json:"category"`
+	Values             uint64  `json:"values"`
+	Bytes              uint64  `json:"bytes"`
+	DistinctValues     uint64  `json:"distinct_values"`
+	DistinctBytes      uint64  `json:"distinct_bytes"`
+	DuplicateBytes     uint64  `json:"duplicate_bytes"`
+	DuplicateByteRatio float64 `json:"duplicate_byte_ratio"`
+}
+
+// TopValueRecord is a record of valueDedup-top-<source>.{json,csv}, the values with the most duplicate bytes
+type TopValueRecord struct {
+	Category       string `json:"category"`
+	Rank           int    `json:"rank"`
+	Hash           string `json:"hash"`
+	Size           uint32 `json:"size"`
+	Copies         uint32 `json:"copies"`
+	DistinctKeys   uint32 `json:"distinct_keys"`
+	DuplicateBytes uint64 `json:"duplicate_bytes"`
+	ExampleKey     string `json:"example_key"`
+	ValuePrefix    string `json:"value_prefix"`
+}
+
+// SharingRecord is a record of valueDedup-sharing-<source>.{json,csv}, the distinct values shared by up to the given
+// number of distinct keys (power-of-two buckets)
+type SharingRecord struct {
+	Category     string `json:"category"`
+	DistinctKeys uint64 `json:"distinct_keys"`
+	Values       uint64 `json:"values"`
+	Bytes        uint64 `json:"bytes"`
+}
+
+const valuePrefixSize = 32 // Bytes of a duplicated value kept for the report
+
+var (
+	hexPrefixes = []PrefixCategory{
+		{"7365637572652d6b65792d", "PreimagePrefix"},
+		{"657468657265756d2d636f6e6669672d", "ConfigPrefix"},
+		{"657468657265756d2d67656e657369732d", "GenesisPrefix"},
+		{"636874526f6f7456322d", "ChtPrefix"},
+		{"636874496e64657856322d", "ChtIndexTablePrefix"},
+		{"6669786564526f6f742d", "FixedCommitteeRootKey"},
+		{"636f6d6d69747465652d", "SyncCommitteeKey"},
+		{"6368742d", "ChtTablePrefix"},
+		{"626c74526f6f742d", "BloomTriePrefix"},
+		{"626c74496e6465782d", "BloomTrieIndexPrefix"},
+		{"626c742d", "BloomTrieTablePrefix"},
+		{"636c697175652d", "CliqueSnapshotPrefix"},
+		{"7570646174652d", "BestUpdateKey"},
+		{"536e617073686f7453796e63537461747573", "SnapshotSyncStatusKey"},
+		{"536e617073686f7444697361626c6564", "SnapshotDisabledKey"},
+		{"536e617073686f74526f6f74", "SnapshotRootKey"},
+		{"536e617073686f744a6f75726e616c", "SnapshotJournalKey"},
+		{"536e617073686f7447656e657261746f72", "SnapshotGeneratorKey"},
+		{"536e617073686f745265636f76657279", "SnapshotRecoveryKey"},
+		{"536b656c65746f6e53796e63537461747573", "SkeletonSyncStatusKey"},
+		{"5472696553796e63", "FastTrieProgressKey"},
+		{"547269654a6f75726e616c", "TrieJournalKey"},
+		{"5472616e73616374696f6e496e6465785461696c", "TxIndexTailKey"},
+		{"466173745472616e73616374696f6e4c6f6f6b75704c696d6974", "FastTxLookupLimitKey"},
+		{"496e76616c6964426c6f636b", "BadBlockKey"},
+		{"756e636c65616e2d73687574646f776e", "UncleanShutdownKey"},
+		{"657468322d7472616e736974696f6e", "TransitionStatusKey"},
+		{"536e617053796e63537461747573", "SnapSyncStatusFlagKey"},
+		{"446174616261736556657273696f6e", "DatabaseVersionKey"},
+		{"4c617374486561646572", "HeadHeaderKey"},
+		{"4c617374426c6f636b", "HeadBlockKey"},
+		{"4c61737446617374", "HeadFastBlockKey"},
+		{"4c61737446696e616c697a6564", "HeadFinalizedBlockKey"},
+		{"4c61737453746174654944", "PersistentStateIDKey"},
+		{"4c6173745069766f74", "LastPivotKey"},
+		{"69", "BloomBitsIndexPrefix"},
+		{"68", "HeaderPrefix"},
+		{"74", "HeaderTDSuffix"},
+		{"6e", "HeaderHashSuffix"},
+		{"48", "HeaderNumberPrefix"},
+		{"62", "BlockBodyPrefix"},
+		{"72", "BlockReceiptsPrefix"},
+		{"6c", "TxLookupPrefix"},
+		{"42", "BloomBitsPrefix"},
+		{"61", "SnapshotAccountPrefix"},
+		{"6f", "SnapshotStoragePrefix"},
+		{"63", "CodePrefix"},
+		{"53", "SkeletonHeaderPrefix"},
+		{"41", "TrieNodeAccountPrefix"},
+		{"4f", "TrieNodeStoragePrefix"},
+		{"4c", "StateIDPrefix"},
+		{"76", "VerklePrefix"},
+	}
+
+	// Only the writes have values
+	valueLineRegex  = regexp.MustCompile(`OPType: (\w+), key: ([a-fA-F0-9]+), size: \d+, value: ([a-fA-F0-9]*), size: \d+`)
+	blockStartRegex = regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+)
+
+func matchPrefix(key string) string {
+	for _, prefix := range hexPrefixes {
+		if strings.HasPrefix(key, prefix.Prefix) {
+			return prefix.Category
+		}
+	}
+	return "Unknown"
+}
+
+func hashOf(data []byte) contentHash {
+	sum := sha256.Sum256(data)
+	var h contentHash
+	copy(h[:], sum[:16])
+	return h
+}
+
+func newDedupStats() *DedupStats {
+	return &DedupStats{Entries: make(map[contentHash]*ValueEntry), keyPairs: make(map[[2]contentHash]bool)}
+}
+
+// add counts a value of the key. With hashSample > 1, only the values whose hash is a multiple of hashSample are
+// kept, so that all copies of a kept value are counted. Each key of the KV store has one value, so the distinct keys
+// are only tracked for the trace
+func (ds *DedupStats) add(key, value []byte, hashSample uint64, trackKeys bool) {
+	h := hashOf(value)
+	if hashSample > 1 && binary.BigEndian.Uint64(h[:8])%hashSample != 0 {
+		return
+	}
+	ds.Values++
+	ds.Bytes += uint64(len(value))
+	entry, exists := ds.Entries[h]
+	if !exists {
+		entry = &ValueEntry{Size: uint32(len(value))}
+		ds.Entries[h] = entry
+	}
+	entry.Copies++
+	if entry.Copies == 2 {
+		entry.ExampleKey = append([]byte{}, key...)
+		entry.ValuePrefix = append([]byte{}, value[:min(len(value), valuePrefixSize)]...)
+	}
+	if !trackKeys {
+		entry.DistinctKeys++
+		return
+	}
+	pair := [2]contentHash{h, hashOf(key)}
+	if !ds.keyPairs[pair] {
+		ds.keyPairs[pair] = true
+		entry.DistinctKeys++
+	}
+}
+
+func (ds *DedupStats) distinctBytes() uint64 {
+	total := uint64(0)
+	for _, entry := range ds.Entries {
+		total += uint64(entry.Size)
+	}
+	return total
+}
+
+// scanDB hashes every value of the KV store
+func scanDB(db *pebble.DB, hashSample, progressInterval uint64) (map[string]*DedupStats, error) {
+	iter, err := db.NewIter(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %v", err)
+	}
+	defer iter.Close()
+
+	dedupStats := make(map[string]*DedupStats)
+	count := uint64(0)
+	start := time.Now()
+	for iter.First(); iter.Valid(); iter.Next() {
+		count++
+		if count%progressInterval == 0 {
+			fmt.Printf("\rProcessed %d KV pairs, elapsed time: %.2fs", count, time.Since(start).Seconds())
+		}
+		category := matchPrefix(hex.EncodeToString(iter.Key()))
+		if _, exists := dedupStats[category]; !exists {
+			dedupStats[category] = newDedupStats()
+		}
+		dedupStats[category].add(iter.Key(), iter.Value(), hashSample, false)
+	}
+	fmt.Printf("\rProcessed %d KV pairs... Done!\n", count)
+	return dedupStats, iter.Error()
+}
+
+// scanTrace hashes the written values in the block range of the trace
+func scanTrace(filePath string, progressInterval, startBlockNumber, endBlockNumber, hashSample uint64) map[string]*DedupStats {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open file: %s", filePath))
+	}
+	defer file.Close()
+
+	dedupStats := make(map[string]*DedupStats)
+	reader := bufio.NewReader(file)
+	var currentBlockID, lineCount uint64
+	foundStartBlock := false
+	start := time.Now()
+
+	for {
+		line, err := reader.ReadString('\n') // Read until newline
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println("\nEnd of file reached")
+				break
+			}
+			fmt.Println("Error reading file:", err)
+			break
+		}
+
+		lineCount++
+		if lineCount%progressInterval == 0 {
+			elapsed := time.Since(start).Seconds()
+			fmt.Printf("\rProcessed %d lines, current block ID: %d, elapsed time: %.2fs", lineCount, currentBlockID, elapsed)
+		}
+
+		if matches := blockStartRegex.FindStringSubmatch(line); matches != nil {
+			id, err := strconv.ParseUint(matches[1], 10, 64)
+			if err != nil {
+				fmt.Println("Error converting ID to integer:", err)
+				continue
+			}
+			if id > endBlockNumber {
+				fmt.Println("\nFound the last block that is larger than (", endBlockNumber, "), stop processing")
+				break
+			}
+			if id >= startBlockNumber {
+				foundStartBlock = true
+			}
+			currentBlockID = id
+			continue
+		}
+		if !foundStartBlock {
+			continue
+		}
+
+		matches := valueLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		switch matches[1] {
+		case "Put", "BatchPut", "Update":
+		default:
+			continue
+		}
+		key, err := hex.DecodeString(matches[2])
+		if err != nil {
+			fmt.Println("Error decoding hex key:", err)
+			continue
+		}
+		value, err := hex.DecodeString(matches[3])
+		if err != nil {
+			fmt.Println("Error decoding hex value:", err)
+			continue
+		}
+		category := matchPrefix(matches[2])
+		if _, exists := dedupStats[category]; !exists {
+			dedupStats[category] = newDedupStats()
+		}
+		dedupStats[category].add(key, value, hashSample, true)
+	}
+	return dedupStats
+}
+
+// sortedCategories returns the categories sorted by their duplicate bytes in descending order
+func sortedCategories(dedupStats map[string]*DedupStats) []string {
+	duplicateBytes := make(map[string]uint64)
+	var categories []string
+	for category, ds := range dedupStats {
+		categories = append(categories, category)
+		duplicateBytes[category] = ds.Bytes - ds.distinctBytes()
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if duplicateBytes[categories[i]] != duplicateBytes[categories[j]] {
+			return duplicateBytes[categories[i]] > duplicateBytes[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+func newDedupRecord(category string, values, totalBytes, distinctValues, distinctBytes uint64) DedupRecord {
+	record := DedupRecord{
+		Category:           category,
+		Values:             values,
+		Bytes:              totalBytes,
+		DistinctValues:     distinctValues,
+		DistinctBytes:      distinctBytes,
+		DuplicateBytes:     totalBytes - distinctBytes,
+		DuplicateByteRatio: math.NaN(),
+	}
+	if totalBytes > 0 {
+		record.DuplicateByteRatio = float64(record.DuplicateBytes) / float64(totalBytes)
+	}
+	return record
+}
+
+// dedupRecords returns the duplicate bytes of each category, where a value in two categories is distinct in both
+func dedupRecords(dedupStats map[string]*DedupStats) []DedupRecord {
+	var records []DedupRecord
+	var values, totalBytes, distinctValues, distinctBytes uint64
+	for _, category := range sortedCategories(dedupStats) {
+		ds := dedupStats[category]
+		record := newDedupRecord(category, ds.Values, ds.Bytes, uint64(len(ds.Entries)), ds.distinctBytes())
+		records = append(records, record)
+		values += record.Values
+		totalBytes += record.Bytes
+		distinctValues += record.DistinctValues
+		distinctBytes += record.DistinctBytes
+	}
+	return append(records, newDedupRecord("All", values, totalBytes, distinctValues, distinctBytes))
+}
+
+// topRecords returns the topN duplicated values of each category by their duplicate bytes
+func topRecords(dedupStats map[string]*DedupStats, topN int) []TopValueRecord {
+	var records []TopValueRecord
+	for _, category := range sortedCategories(dedupStats) {
+		var top []TopValueRecord
+		for h, entry := range dedupStats[category].Entries {
+			if entry.Copies < 2 {
+				continue
+			}
+			top = append(top, TopValueRecord{
+				Category:       category,
+				Hash:           hex.EncodeToString(h[:]),
+				Size:           entry.Size,
+				Copies:         entry.Copies,
+				DistinctKeys:   entry.DistinctKeys,
+				DuplicateBytes: uint64(entry.Copies-1) * uint64(entry.Size),
+				ExampleKey:     hex.EncodeToString(entry.ExampleKey),
+				ValuePrefix:    hex.EncodeToString(entry.ValuePrefix),
+			})
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].DuplicateBytes != top[j].DuplicateBytes {
+				return top[i].DuplicateBytes > top[j].DuplicateBytes
+			}
+			return top[i].Hash < top[j].Hash
+		})
+		for i := range top {
+			if i >= topN {
+				break
+			}
+			top[i].Rank = i + 1
+			records = append(records, top[i])
+		}
+	}
+	return records
+}
+
+// powerOfTwoBucket returns the smallest power of two that is not smaller than the count
+func powerOfTwoBucket(count uint64) uint64 {
+	if count <= 1 {
+		return count
+	}
+	return 1 << bits.Len64(count-1)
+}
+
+// sharingRecords counts the distinct values of each category by the distinct keys sharing them
+func sharingRecords(dedupStats map[string]*DedupStats) []SharingRecord {
+	var records []SharingRecord
+	for _, category := range sortedCategories(dedupStats) {
+		buckets := make(map[uint64]*SharingRecord)
+		for _, entry := range dedupStats[category].Entries {
+			bucket := powerOfTwoBucket(uint64(entry.DistinctKeys))
+			if _, exists := buckets[bucket]; !exists {
+				buckets[bucket] = &SharingRecord{Category: category, DistinctKeys: bucket}
+			}
+			buckets[bucket].Values++
+			buckets[bucket].Bytes += uint64(entry.Size)
+		}
+		var sorted []SharingRecord
+		for _, record := range buckets {
+			sorted = append(sorted, *record)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].DistinctKeys < sorted[j].DistinctKeys
+		})
+		records = append(records, sorted...)
+	}
+	return records
+}
+
+// printStats writes the duplicate bytes, the sharing, and the top duplicated values of each category
+func printStats(outputFile *os.File, records []DedupRecord, sharing []SharingRecord, top []TopValueRecord, hashSample uint64) {
+	if hashSample > 1 {
+		fmt.Fprintf(outputFile, "Hash sampling: 1/%d of the distinct values\n\n", hashSample)
+	}
+	for _, record := range records {
+		fmt.Fprintf(outputFile, "Category: %s\n", record.Category)
+		fmt.Fprintf(outputFile, "  Values: %d, bytes: %d\n", record.Values, record.Bytes)
+		fmt.Fprintf(outputFile, "  Distinct values: %d, distinct bytes: %d\n", record.DistinctValues, record.DistinctBytes)
+		fmt.Fprintf(outputFile, "  Duplicate bytes: %d (%.2f%%)\n", record.DuplicateBytes, record.DuplicateByteRatio*100)
+		if record.Category == "All" {
+			continue
+		}
+		fmt.Fprintln(outputFile, "  Distinct values by the distinct keys sharing them:")
+		for _, sr := range sharing {
+			if sr.Category == record.Category {
+				fmt.Fprintf(outputFile, "    <= %d keys: %d values, %d bytes\n", sr.DistinctKeys, sr.Values, sr.Bytes)
+			}
+		}
+		fmt.Fprintln(outputFile, "  Top duplicated values:")
+		for _, tr := range top {
+			if tr.Category == record.Category {
+				fmt.Fprintf(outputFile, "    %d. hash: %s, size: %d, copies: %d, distinct keys: %d, duplicate bytes: %d, example key: %s, value: %s\n",
+					tr.Rank, tr.Hash, tr.Size, tr.Copies, tr.DistinctKeys, tr.DuplicateBytes, tr.ExampleKey, tr.ValuePrefix)
+			}
+		}
+	}
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	topN := flag.Int("top", 20, "number of top duplicated values of each category")
+	hashSample := flag.Uint64("hash-sample", 1, "keep 1/N of the distinct values (by their hash) to bound the memory")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	validMode := len(args) >= 3 && ((args[1] == "db" && len(args) <= 4) || (args[1] == "trace" && len(args) >= 5))
+	if !validMode || (*format != "text" && *format != "json" && *format != "csv") || *topN < 0 || *hashSample < 1 {
+		fmt.Println("Usage: program [--format=json|csv|text] [--top=N] [--hash-sample=N] db <pebble_database_path> [print_progress_interval]")
+		fmt.Println("       program [--format=json|csv|text] [--top=N] [--hash-sample=N] trace <log_file_path> <start_block_number> <end_block_number> [print_progress_interval]")
+		return
+	}
+
+	var dedupStats map[string]*DedupStats
+	var source string
+	if args[1] == "db" {
+		progressInterval := uint64(1000000)
+		if len(args) > 3 {
+			progressInterval, _ = strconv.ParseUint(args[3], 10, 64)
+		}
+		if progressInterval == 0 {
+			progressInterval = 1000000
+		}
+		db, err := pebble.Open(args[2], &pebble.Options{ReadOnly: true})
+		if err != nil {
+			log.Fatalf("Cannot open target database, err: %v\n", err)
+		}
+		dedupStats, err = scanDB(db, *hashSample, progressInterval)
+		db.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		source = "db"
+	} else {
+		startBlockNumber, _ := strconv.ParseUint(args[3], 10, 64)
+		endBlockNumber, _ := strconv.ParseUint(args[4], 10, 64)
+		progressInterval := uint64(1000)
+		if len(args) > 5 {
+			progressInterval, _ = strconv.ParseUint(args[5], 10, 64)
+		}
+		if progressInterval == 0 {
+			progressInterval = 1000
+		}
+		dedupStats = scanTrace(args[2], progressInterval, startBlockNumber, endBlockNumber, *hashSample)
+		source = strconv.FormatUint(startBlockNumber, 10) + "_" + strconv.FormatUint(endBlockNumber, 10)
+	}
+
+	records := dedupRecords(dedupStats)
+	sharing := sharingRecords(dedupStats)
+	top := topRecords(dedupStats, *topN)
+	outputFilePath := "valueDedup-" + source + ".txt"
+	if *format != "text" {
+		if err := writeRecords(outputPath(outputFilePath, *format), *format, records); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("valueDedup-sharing-"+source+".txt", *format), *format, sharing); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeRecords(outputPath("valueDedup-top-"+source+".txt", *format), *format, top); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		log.Fatalf("Cannot create the output file %s: %v", outputFilePath, err)
+	}
+	defer outputFile.Close()
+	printStats(outputFile, records, sharing, top, *hashSample)
+	fmt.Printf("Statistics are stored to: %s\n", outputFilePath)
+}
@@ -15,6 +15,7 @@ if [ "$ShouldInstall" == "install" ]; then
     go get github.com/golang/protobuf/proto
     go get github.com/prometheus/client_model/go@v0.2.1-0.20210607210712-147c58e9608a
     go get github.com/golang/snappy
+    go get github.com/klauspost/compress/zstd
     go get gonum.org/v1/plot
     go get gonum.org/v1/plot/plotter
     go get gonum.org/v1/plot/plotutil
@@ -27,16 +28,49 @@ else
 fi
 
 # for KV size and operation distribution
-go build -o bin/countKVSizeDistribution analysisKVStoragePebble.go
-go build -o bin/countOpDistribution analysisOpDistributionByBatch.go
-go build -o bin/mergeOpDist analysisOpDistributionMergeDistribution.go
-go build -o bin/mergeOpCount analysisOpDistributionMergeCount.go
+go build -o bin/countKVSizeDistribution analysisKVStoragePebble.go recordWriter.go
+go build -o bin/lsmLevels analysisLSMLevels.go recordWriter.go
+go build -o bin/compressibility analysisCompressibility.go recordWriter.go
+go build -o bin/valueDedup analysisValueDedup.go recordWriter.go
+go build -o bin/countOpDistribution analysisOpDistributionByBatch.go recordWriter.go
+go build -o bin/mergeOpDist analysisOpDistributionMergeDistribution.go recordWriter.go
+go build -o bin/mergeOpCount analysisOpDistributionMergeCount.go recordWriter.go
 # for read correlation
-go build -o bin/collectReadCorrelation collectReadCorrelation.go
-go build -o bin/analysisReadCorrelation analysisReadCorrelation.go
-go build -o bin/categoryPearson analysisCategoryPearson.go
+go build -o bin/collectReadCorrelation collectReadCorrelation.go recordWriter.go
+go build -o bin/analysisReadCorrelation analysisReadCorrelation.go recordWriter.go
+go build -o bin/categoryPearson analysisCategoryPearson.go recordWriter.go
 # for update correlation
-go build -o bin/collectUpdateCorrelation collectUpdateCorrelation.go
-go build -o bin/analysisUpdateCorrelation analysisUpdateCorrelation.go
+go build -o bin/collectUpdateCorrelation collectUpdateCorrelation.go recordWriter.go
+go build -o bin/analysisUpdateCorrelation analysisUpdateCorrelation.go recordWriter.go
 # for filter updates from the original KV traces
-go build -o bin/filterUpdate filterUpdate.go
\ No newline at end of file
+go build -o bin/filterUpdate filterUpdate.go
+# for key lifetime and overwrite intervals
+go build -o bin/keyLifetime analysisKeyLifetime.go recordWriter.go
+# for delete patterns and range-delete candidacy
+go build -o bin/deletePattern analysisDeletePattern.go recordWriter.go
+# for read-after-write locality
+go build -o bin/readAfterWrite analysisReadAfterWrite.go recordWriter.go
+# for fitting the access frequency distributions
+go build -o bin/fitDistribution analysisDistributionFit.go recordWriter.go
+# for hot-set churn across block windows
+go build -o bin/hotSetChurn analysisHotSetChurn.go recordWriter.go
+# for windowed co-access and frequent itemsets
+go build -o bin/collectWindowCorrelation collectWindowCorrelation.go recordWriter.go
+# for inter-block co-access
+go build -o bin/interBlock analysisInterBlock.go recordWriter.go
+# for read-modify-write patterns
+go build -o bin/readModifyWrite analysisReadModifyWrite.go recordWriter.go
+# for evaluating correlation-based prefetching
+go build -o bin/prefetchOracle analysisPrefetchOracle.go recordWriter.go
+# for trie-structure-aware access analysis
+go build -o bin/trieStructure analysisTrieStructure.go recordWriter.go
+# for per-contract storage I/O attribution
+go build -o bin/contractStorage analysisContractStorage.go recordWriter.go
+# for BareTrace vs CacheTrace differential analysis
+go build -o bin/traceDiff analysisTraceDiff.go recordWriter.go
+# for block processing timeline and throughput
+go build -o bin/blockTimeline analysisBlockTimeline.go recordWriter.go
+# for plotting the results
+go build -o bin/plotResults plotResults.go recordWriter.go
+# for the consolidated report
+go build -o bin/report generateReport.go recordWriter.go
@@ -2,20 +2,467 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // PairInfo stores the frequency and the list of BlockIDs where the pair appears
 type PairInfo struct {
-	Frequency int
-	BlockIDs  string // BlockIDs are stored as a semicolon-separated string
+	Frequency uint32
+	BlockIDs  []uint32 // BlockIDs are stored in ascending order without duplicates
 }
 
-func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs []int, outputPathPrefix string) error {
+// KeyPairRecord is a record of rawFreq-<start>-<end>-Dist<distance>-<logname>.{json,csv}, the keys are hex strings
+type KeyPairRecord struct {
+	Key1      string   `json:"key1"`
+	Size1     uint64   `json:"size1"`
+	Key2      string   `json:"key2"`
+	Size2     uint64   `json:"size2"`
+	Frequency uint32   `json:"frequency"`
+	BlockIDs  []uint32 `json:"block_ids"`
+}
+
+const (
+	// Estimated memory usage of a pair in the map (map entry, PairInfo, and slice header), without block IDs
+	pairEntryBytes = 96
+	// Estimated memory usage of an interned key (map entry and name), without the key itself
+	keyEntryBytes = 64
+	// The pairs get at least this share of the memory limit, the interned keys beyond it are an error, as spilling
+	// cannot free them
+	minPairBudgetShare = 16
+	// Maximum number of spilled runs merged at once
+	maxMergeFanIn = 64
+)
+
+// PairCollector accumulates the co-accessed pairs of a batch. Keys are interned to integer IDs and a pair is
+// packed into a uint64 (smaller ID in the high 32 bits). When the estimated memory usage reaches the memory
+// limit, the pairs are spilled to disk as a run sorted by the packed pair, and the runs are k-way merged at the end,
+// at most maxMergeFanIn at a time.
+type PairCollector struct {
+	keyIDs         map[string]uint32
+	keyNames       []string
+	pairs          map[uint64]*PairInfo
+	memLimit       uint64 // Memory limit in bytes, 0 means unlimited
+	keyBytes       uint64 // Estimated memory usage of the interned keys (never spilled)
+	pairBytes      uint64 // Estimated memory usage of the pairs in memory
+	spillPrefix    string
+	runFiles       []string
+	spilledRecords uint64
+	format         string // Output format, one of text, json, and csv
+}
+
+func NewPairCollector(memLimit uint64, spillPrefix, format string) *PairCollector {
+	return &PairCollector{
+		keyIDs:      make(map[string]uint32),
+		pairs:       make(map[uint64]*PairInfo),
+		memLimit:    memLimit,
+		spillPrefix: spillPrefix,
+		format:      format,
+	}
+}
+
+func (pc *PairCollector) intern(key string) uint32 {
+	if id, exists := pc.keyIDs[key]; exists {
+		return id
+	}
+	id := uint32(len(pc.keyNames))
+	pc.keyIDs[key] = id
+	pc.keyNames = append(pc.keyNames, key)
+	pc.keyBytes += uint64(len(key)) + keyEntryBytes
+	return id
+}
+
+// EstimatedMemory returns the estimated memory usage of the collector in bytes
+func (pc *PairCollector) EstimatedMemory() uint64 {
+	return pc.keyBytes + pc.pairBytes
+}
+
+// Add counts one co-access of the two keys (formatted as key-size) in the block
+func (pc *PairCollector) Add(key1, key2 string, blockID uint32) error {
+	id1, id2 := pc.intern(key1), pc.intern(key2)
+	if id1 > id2 {
+		id1, id2 = id2, id1
+	}
+	packed := uint64(id1)<<32 | uint64(id2)
+	pairInfo, exists := pc.pairs[packed]
+	if !exists {
+		pairInfo = &PairInfo{}
+		pc.pairs[packed] = pairInfo
+		pc.pairBytes += pairEntryBytes
+	}
+	pairInfo.Frequency++
+	if len(pairInfo.BlockIDs) == 0 || pairInfo.BlockIDs[len(pairInfo.BlockIDs)-1] != blockID {
+		pairInfo.BlockIDs = append(pairInfo.BlockIDs, blockID)
+		pc.pairBytes += 4
+	}
+	if pc.memLimit == 0 {
+		return nil
+	}
+	// Only the pairs are spilled, so they are compared with the memory left after the interned keys
+	if pc.keyBytes >= pc.memLimit-pc.memLimit/minPairBudgetShare {
+		return fmt.Errorf("the interned keys (estimated %.2f GiB) leave less than 1/%d of the memory limit (%.2f GiB) to the pairs, increase --mem-limit",
+			float64(pc.keyBytes)/1024/1024/1024, minPairBudgetShare, float64(pc.memLimit)/1024/1024/1024)
+	}
+	if pc.pairBytes >= pc.memLimit-pc.keyBytes {
+		return pc.spill()
+	}
+	return nil
+}
+
+func (pc *PairCollector) sortedPairs() []uint64 {
+	packedPairs := make([]uint64, 0, len(pc.pairs))
+	for packed := range pc.pairs {
+		packedPairs = append(packedPairs, packed)
+	}
+	sort.Slice(packedPairs, func(i, j int) bool {
+		return packedPairs[i] < packedPairs[j]
+	})
+	return packedPairs
+}
+
+// writeRunRecord writes a pair to a run. Record format (little endian): pair (uint64), frequency (uint32), block count
+// (uint32), block IDs (uint32 each)
+func writeRunRecord(writer *bufio.Writer, packed uint64, pairInfo *PairInfo) error {
+	buffer := make([]byte, 16)
+	binary.LittleEndian.PutUint64(buffer[0:8], packed)
+	binary.LittleEndian.PutUint32(buffer[8:12], pairInfo.Frequency)
+	binary.LittleEndian.PutUint32(buffer[12:16], uint32(len(pairInfo.BlockIDs)))
+	if _, err := writer.Write(buffer); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	for _, blockID := range pairInfo.BlockIDs {
+		binary.LittleEndian.PutUint32(buffer[0:4], blockID)
+		if _, err := writer.Write(buffer[0:4]); err != nil {
+			return fmt.Errorf("failed to write spill file: %v", err)
+		}
+	}
+	return nil
+}
+
+// spill writes the pairs in memory to a sorted run on disk and releases the memory
+func (pc *PairCollector) spill() error {
+	if len(pc.pairs) == 0 {
+		return nil
+	}
+	runFileName := fmt.Sprintf("%s-run%d.bin", pc.spillPrefix, len(pc.runFiles))
+	fmt.Printf("\nSpilling %d pairs (estimated %.2f GiB) to %s\n", len(pc.pairs), float64(pc.EstimatedMemory())/1024/1024/1024, runFileName)
+	runFile, err := os.Create(runFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %v", err)
+	}
+	defer runFile.Close()
+	writer := bufio.NewWriter(runFile)
+
+	for _, packed := range pc.sortedPairs() {
+		if err := writeRunRecord(writer, packed, pc.pairs[packed]); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	pc.spilledRecords += uint64(len(pc.pairs))
+	pc.runFiles = append(pc.runFiles, runFileName)
+	pc.pairs = make(map[uint64]*PairInfo)
+	pc.pairBytes = 0
+	// Return the memory of the dropped map to the OS
+	debug.FreeOSMemory()
+	return nil
+}
+
+// runReader reads the records of a spilled run one by one
+type runReader struct {
+	file     *os.File
+	reader   *bufio.Reader
+	runIndex int
+	pair     uint64
+	pairInfo PairInfo
+}
+
+func (rr *runReader) next() (bool, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(rr.reader, header); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read spill file: %v", err)
+	}
+	rr.pair = binary.LittleEndian.Uint64(header[0:8])
+	rr.pairInfo.Frequency = binary.LittleEndian.Uint32(header[8:12])
+	blockCount := binary.LittleEndian.Uint32(header[12:16])
+	blocks := make([]byte, 4*blockCount)
+	if _, err := io.ReadFull(rr.reader, blocks); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %v", err)
+	}
+	rr.pairInfo.BlockIDs = make([]uint32, blockCount)
+	for i := range rr.pairInfo.BlockIDs {
+		rr.pairInfo.BlockIDs[i] = binary.LittleEndian.Uint32(blocks[4*i : 4*i+4])
+	}
+	return true, nil
+}
+
+// runHeap orders the run readers by the current pair, and by the run index for the same pair
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if h[i].pair != h[j].pair {
+		return h[i].pair < h[j].pair
+	}
+	return h[i].runIndex < h[j].runIndex
+}
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)   { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	reader := old[n-1]
+	*h = old[:n-1]
+	return reader
+}
+
+// PairOutput is the output file of a batch, in text or as records
+type PairOutput struct {
+	file    *os.File
+	writer  *bufio.Writer
+	records *RecordWriter
+	closed  bool
+}
+
+func newPairOutput(outputFileName, format string) (*PairOutput, error) {
+	if format != "text" {
+		records, err := newRecordWriter(outputFileName, format, KeyPairRecord{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %v", err)
+		}
+		return &PairOutput{records: records}, nil
+	}
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %v", err)
+	}
+	return &PairOutput{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Close flushes and closes the output file, it is a no-op if the file is already closed
+func (po *PairOutput) Close() error {
+	if po.closed {
+		return nil
+	}
+	po.closed = true
+	if po.records != nil {
+		return po.records.Close()
+	}
+	if err := po.writer.Flush(); err != nil {
+		po.file.Close()
+		return err
+	}
+	return po.file.Close()
+}
+
+// writePair writes a pair in the same format as before: keys in string order, frequency, and block IDs
+func (pc *PairCollector) writePair(output *PairOutput, packed uint64, pairInfo *PairInfo) error {
+	// Only write the log if the frequency is greater than 1
+	if pairInfo.Frequency <= 1 {
+		return nil
+	}
+	// Keys are ordered by the key itself (without the size), the same as the order before interning
+	key1, key2 := pc.keyNames[packed>>32], pc.keyNames[uint32(packed)]
+	if key1[:strings.LastIndex(key1, "-")] > key2[:strings.LastIndex(key2, "-")] {
+		key1, key2 = key2, key1
+	}
+	if output.records != nil {
+		separator1, separator2 := strings.LastIndex(key1, "-"), strings.LastIndex(key2, "-")
+		size1, _ := strconv.ParseUint(key1[separator1+1:], 10, 64)
+		size2, _ := strconv.ParseUint(key2[separator2+1:], 10, 64)
+		return output.records.Write(KeyPairRecord{
+			Key1:      key1[:separator1],
+			Size1:     size1,
+			Key2:      key2[:separator2],
+			Size2:     size2,
+			Frequency: pairInfo.Frequency,
+			BlockIDs:  pairInfo.BlockIDs,
+		})
+	}
+	blockIDs := make([]string, len(pairInfo.BlockIDs))
+	for i, blockID := range pairInfo.BlockIDs {
+		blockIDs[i] = strconv.FormatUint(uint64(blockID), 10)
+	}
+	_, err := output.writer.WriteString(fmt.Sprintf("key: %s;%s; Freq: %d; Blocks: %s\n", key1, key2, pairInfo.Frequency, strings.Join(blockIDs, ";")))
+	if err != nil {
+		return fmt.Errorf("failed to write to output file: %v", err)
+	}
+	return nil
+}
+
+// Finish writes all pairs of the batch to the output file, merging the spilled runs if any, and resets the collector
+func (pc *PairCollector) Finish(outputFileName string) error {
+	output, err := newPairOutput(outputFileName, pc.format)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	defer func() {
+		for _, runFileName := range pc.runFiles {
+			os.Remove(runFileName)
+		}
+		pc.keyIDs = make(map[string]uint32)
+		pc.keyNames = nil
+		pc.pairs = make(map[uint64]*PairInfo)
+		pc.keyBytes, pc.pairBytes, pc.spilledRecords = 0, 0, 0
+		pc.runFiles = nil
+	}()
+
+	if len(pc.runFiles) == 0 {
+		for _, packed := range pc.sortedPairs() {
+			if err := pc.writePair(output, packed, pc.pairs[packed]); err != nil {
+				return err
+			}
+		}
+		return output.Close()
+	}
+
+	if err := pc.spill(); err != nil {
+		return err
+	}
+	// Merge the runs in passes of at most maxMergeFanIn runs, keeping the time order of the runs
+	for pass := 0; len(pc.runFiles) > maxMergeFanIn; pass++ {
+		var merged []string
+		for start := 0; start < len(pc.runFiles); start += maxMergeFanIn {
+			group := pc.runFiles[start:min(start+maxMergeFanIn, len(pc.runFiles))]
+			runFileName := fmt.Sprintf("%s-pass%d-run%d.bin", pc.spillPrefix, pass, len(merged))
+			fmt.Printf("Merging %d spilled runs into %s\n", len(group), runFileName)
+			if err := mergeRunsToFile(group, runFileName); err != nil {
+				return err
+			}
+			for _, runFileName := range group {
+				os.Remove(runFileName)
+			}
+			merged = append(merged, runFileName)
+		}
+		pc.runFiles = merged
+	}
+	fmt.Printf("Merging %d spilled runs (%d records)\n", len(pc.runFiles), pc.spilledRecords)
+	if err := mergeRuns(pc.runFiles, func(packed uint64, pairInfo *PairInfo) error {
+		return pc.writePair(output, packed, pairInfo)
+	}); err != nil {
+		return err
+	}
+	return output.Close()
+}
+
+// mergeRunsToFile merges the runs into a new run
+func mergeRunsToFile(runFiles []string, runFileName string) error {
+	runFile, err := os.Create(runFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %v", err)
+	}
+	defer runFile.Close()
+	writer := bufio.NewWriter(runFile)
+	if err := mergeRuns(runFiles, func(packed uint64, pairInfo *PairInfo) error {
+		return writeRunRecord(writer, packed, pairInfo)
+	}); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	return runFile.Close()
+}
+
+// mergeRuns k-way merges the runs (in time order), and emits each pair once with the sum of its frequencies
+func mergeRuns(runFiles []string, emit func(packed uint64, pairInfo *PairInfo) error) error {
+	readers := &runHeap{}
+	for i, runFileName := range runFiles {
+		file, err := os.Open(runFileName)
+		if err != nil {
+			return fmt.Errorf("failed to open spill file: %v", err)
+		}
+		defer file.Close()
+		reader := &runReader{file: file, reader: bufio.NewReader(file), runIndex: i}
+		ok, err := reader.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Push(readers, reader)
+		}
+	}
+
+	var current *PairInfo
+	var currentPair uint64
+	for readers.Len() > 0 {
+		reader := (*readers)[0]
+		if current != nil && reader.pair != currentPair {
+			if err := emit(currentPair, current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			currentPair = reader.pair
+			current = &PairInfo{}
+		}
+		// Runs are spilled in time order, so the block IDs only overlap at the boundary
+		current.Frequency += reader.pairInfo.Frequency
+		for _, blockID := range reader.pairInfo.BlockIDs {
+			if len(current.BlockIDs) == 0 || current.BlockIDs[len(current.BlockIDs)-1] != blockID {
+				current.BlockIDs = append(current.BlockIDs, blockID)
+			}
+		}
+		ok, err := reader.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(readers, 0)
+		} else {
+			heap.Pop(readers)
+		}
+	}
+	if current != nil {
+		return emit(currentPair, current)
+	}
+	return nil
+}
+
+// parseMemLimit parses a memory size such as 512M, 16G, or a plain number of bytes
+func parseMemLimit(value string) (uint64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := uint64(1)
+	if len(value) > 0 {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit: %v", err)
+	}
+	return number * multiplier, nil
+}
+
+func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs []int, outputPathPrefix string, memLimit uint64, crossBlock bool, format string) error {
 
 	fmt.Printf("Processing %s, distance=%d\n", inputFile, distance)
 
@@ -34,14 +481,15 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 	// Variables to track the current block
 	var currentBlockID string
 	var endBlockID string
-	var outputFile *os.File
 
-	if outputFile != nil {
-		outputFile.Close()
+	// Collector to store the pairs across all blocks of a batch
+	logname := strings.ReplaceAll(inputFile, "/", "")
+	// In cross-block mode, the access window is kept across block boundaries, and the output is marked with "Cross"
+	distanceName := fmt.Sprintf("Dist%d", distance)
+	if crossBlock {
+		distanceName += "-Cross"
 	}
-
-	// Global frequency map to store results across all blocks
-	globalFrequencyMap := make(map[string]PairInfo)
+	collector := NewPairCollector(memLimit, fmt.Sprintf("%sspill-%s-%s", outputPathPrefix, distanceName, logname), format)
 	var opGetLines []string // Slice to store "OPType: Get" lines within the current block
 
 	// Create a buffered reader
@@ -63,6 +511,14 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 		if lineCount%10000 == 0 {
 			fmt.Printf("\rProcessed %d lines", lineCount)
 		}
+		if lineCount%10000000 == 0 {
+			memoryUsage, err := GetMemoryUsage()
+			if err != nil {
+				fmt.Println("Get memory usage Error:", err)
+			}
+			fmt.Printf("\rProcessed %d lines, current block ID: %s, memory usage: %.2f GiB (estimated pairs and keys: %.2f GiB), spilled runs: %d\n",
+				lineCount, currentBlockID, float64(memoryUsage)/1024/1024/1024, float64(collector.EstimatedMemory())/1024/1024/1024, len(collector.runFiles))
+		}
 
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -75,7 +531,9 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 			// Extract the block ID
 			currentBlockID = matches[1]
 
-			opGetLines = nil // Reset the slice for the new block
+			if !crossBlock {
+				opGetLines = nil // Reset the slice for the new block
+			}
 
 			// Convert block ID to integer
 			blockIDInt, err := strconv.Atoi(currentBlockID)
@@ -100,6 +558,10 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 		// If inside a block, check for "OPType: Get" lines
 		if opGetRegex.MatchString(line) {
 			opGetLines = append(opGetLines, line) // Store the line for frequency calculation
+			// Only the last distance+2 lines are needed, drop the older ones so the window stays small across blocks
+			if len(opGetLines) > distance+2 {
+				opGetLines = opGetLines[len(opGetLines)-distance-2:]
+			}
 
 			// Update the global frequency map
 			if len(opGetLines) > distance+1 {
@@ -123,26 +585,14 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 				key2 := matches2[1]
 				size2 := matches2[2]
 
-				// Create a unique key for the pair (order-independent)
-				pairKey := fmt.Sprintf("%s-%s;%s-%s", key1, size1, key2, size2)
-				if key1 > key2 {
-					pairKey = fmt.Sprintf("%s-%s;%s-%s", key2, size2, key1, size1)
+				// Update the frequency and BlockID list for this pair (order-independent), a pair spanning two blocks
+				// is recorded in the block of the later access
+				blockIDInt, err := strconv.ParseUint(currentBlockID, 10, 32)
+				if err != nil {
+					return fmt.Errorf("failed to parse block ID: %v", err)
 				}
-
-				// Update the frequency and BlockID list for this pair
-				if pairInfo, exists := globalFrequencyMap[pairKey]; exists {
-					// If the pair already exists, update the frequency and BlockID list
-					pairInfo.Frequency++
-					if !strings.Contains(pairInfo.BlockIDs, currentBlockID) {
-						pairInfo.BlockIDs += ";" + currentBlockID
-					}
-					globalFrequencyMap[pairKey] = pairInfo
-				} else {
-					// If the pair does not exist, create a new entry
-					globalFrequencyMap[pairKey] = PairInfo{
-						Frequency: 1,
-						BlockIDs:  currentBlockID,
-					}
+				if err := collector.Add(key1+"-"+size1, key2+"-"+size2, uint32(blockIDInt)); err != nil {
+					return err
 				}
 			}
 		}
@@ -166,29 +616,15 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 			if endIDInt == batchEndIDs[batchIndex] {
 
 				foundStartID = false
+				// Do not carry the window into the next batch
+				opGetLines = nil
 
-				if outputFile != nil {
-					outputFile.Close()
-				}
-
-				logname := strings.ReplaceAll(inputFile, "/", "")
 				// outputPathPrefix := "/mnt/16T/"
-				outputFileName := fmt.Sprintf("%srawFreq-%d-%d-Dist%d-%s.log", outputPathPrefix, batchStartIDs[batchIndex], endIDInt, distance, logname)
-				outputFile, err = os.Create(outputFileName)
-				if err != nil {
-					return fmt.Errorf("failed to create output file: %v", err)
-				}
-				defer outputFile.Close()
-
-				// meet the batch end, dump current map to log file
-				for pairKey, pairInfo := range globalFrequencyMap {
-					// Only write the log if the frequency is greater than 1
-					if pairInfo.Frequency > 1 {
-						_, err := outputFile.WriteString(fmt.Sprintf("key: %s; Freq: %d; Blocks: %s\n", pairKey, pairInfo.Frequency, pairInfo.BlockIDs))
-						if err != nil {
-							return fmt.Errorf("failed to write to output file: %v", err)
-						}
-					}
+				outputFileName := fmt.Sprintf("%srawFreq-%d-%d-%s-%s.log", outputPathPrefix, batchStartIDs[batchIndex], endIDInt, distanceName, logname)
+
+				// meet the batch end, dump current pairs (and merge the spilled runs) to log file, then clear the collector
+				if err := collector.Finish(outputPath(outputFileName, format)); err != nil {
+					return err
 				}
 
 				// Print the final block ID in this batch process
@@ -206,9 +642,6 @@ func ProcessLogBatch(inputFile string, distance int, batchStartIDs, batchEndIDs
 
 				fmt.Printf("Current memory usage: %d bytes (%.2f GiB)\n", memoryUsage, float64(memoryUsage)/1024/1024/1024)
 
-				// clear the globalmap
-				globalFrequencyMap = make(map[string]PairInfo)
-
 				if endIDInt == batchEndIDs[len(batchEndIDs)-1] {
 					return nil
 				}
@@ -264,6 +697,20 @@ func findIndex(target int, batchStartIDs []int) int {
 // output log name: endBlockID-rawFreqWithCache-DistX-inputlogname.log
 // distance param: 0 1 4 16 64 256 1024
 func main() {
+	memLimitFlag := flag.String("mem-limit", "0", "memory budget of the co-accessed pairs in each batch before spilling to disk, e.g., 16G (0 means unlimited)")
+	crossBlock := flag.Bool("cross-block", false, "keep the access window across block boundaries, so that pairs spanning consecutive blocks are counted")
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	if *format != "text" && *format != "json" && *format != "csv" {
+		fmt.Println("Usage: program [--mem-limit=16G] [--cross-block] [--format=json|csv|text]")
+		return
+	}
+	memLimit, err := parseMemLimit(*memLimitFlag)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
 	// input log files
 	logFiles := []string{
 		"./geth-trace-2025-02-11-19-18-38",
@@ -280,7 +727,7 @@ func main() {
 
 	for _, logFile := range logFiles {
 		for _, distance := range distanceParams {
-			err := ProcessLogBatch(logFile, distance, batchStartIDs, batchEndIDs, outputPathPrefix)
+			err := ProcessLogBatch(logFile, distance, batchStartIDs, batchEndIDs, outputPathPrefix, memLimit, *crossBlock, *format)
 			if err != nil {
 				fmt.Println("Error:", err)
 			}
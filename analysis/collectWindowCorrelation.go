@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WindowPairInfo stores the frequency, the decayed weight, and the list of BlockIDs where the pair appears
+type WindowPairInfo struct {
+	Frequency int
+	Weight    float64
+	BlockIDs  string // BlockIDs are stored as a semicolon-separated string
+	LastBlock string // The latest block ID appended to BlockIDs
+}
+
+// KeyPairRecord is a record of rawWindowFreq-<start>-<end>-<op>-Win<window>-<logname>.{json,csv}, the same as the
+// records of the rawFreq outputs of collectReadCorrelation
+type KeyPairRecord struct {
+	Key1      string   `json:"key1"`
+	Size1     uint64   `json:"size1"`
+	Key2      string   `json:"key2"`
+	Size2     uint64   `json:"size2"`
+	Frequency int      `json:"frequency"`
+	BlockIDs  []uint64 `json:"block_ids"`
+}
+
+// PairWeightRecord is a record of rawWindowWeight-<start>-<end>-<op>-Win<window>-Decay<decay>-<logname>.{json,csv}
+type PairWeightRecord struct {
+	Key1   string  `json:"key1"`
+	Size1  uint64  `json:"size1"`
+	Key2   string  `json:"key2"`
+	Size2  uint64  `json:"size2"`
+	Weight float64 `json:"weight"`
+}
+
+// ItemsetRecord is a record of itemsets-<start>-<end>-<op>-Sup<min_support>-<logname>.{json,csv}, the keys are
+// formatted as <key>-<size>
+type ItemsetRecord struct {
+	Keys    []string `json:"keys"`
+	Size    int      `json:"size"`
+	Support int      `json:"support"`
+}
+
+// fpNode is a node of the FP-tree
+type fpNode struct {
+	item     int32
+	count    int
+	parent   *fpNode
+	children map[int32]*fpNode
+	next     *fpNode // Next node with the same item
+}
+
+// fpTree is an FP-tree with a header table linking the nodes of each item
+type fpTree struct {
+	root    *fpNode
+	headers map[int32]*fpNode
+	support map[int32]int
+	items   []int32 // Frequent items in ascending order of support
+}
+
+// Itemset is a group of co-accessed keys and the number of blocks that contain all of them
+type Itemset struct {
+	Items   []int32
+	Support int
+}
+
+// buildFPTree builds the FP-tree from the weighted transactions, keeping only the items with support >= minSupport
+func buildFPTree(transactions [][]int32, weights []int, minSupport int) *fpTree {
+	support := make(map[int32]int)
+	for i, transaction := range transactions {
+		for _, item := range transaction {
+			support[item] += weights[i]
+		}
+	}
+	tree := &fpTree{
+		root:    &fpNode{item: -1, children: make(map[int32]*fpNode)},
+		headers: make(map[int32]*fpNode),
+		support: make(map[int32]int),
+	}
+	for item, count := range support {
+		if count >= minSupport {
+			tree.support[item] = count
+			tree.items = append(tree.items, item)
+		}
+	}
+	sort.Slice(tree.items, func(i, j int) bool {
+		if tree.support[tree.items[i]] != tree.support[tree.items[j]] {
+			return tree.support[tree.items[i]] < tree.support[tree.items[j]]
+		}
+		return tree.items[i] < tree.items[j]
+	})
+	rank := make(map[int32]int, len(tree.items))
+	for i, item := range tree.items {
+		rank[item] = len(tree.items) - i // Larger support, smaller rank
+	}
+
+	for i, transaction := range transactions {
+		filtered := make([]int32, 0, len(transaction))
+		for _, item := range transaction {
+			if _, frequent := rank[item]; frequent {
+				filtered = append(filtered, item)
+			}
+		}
+		sort.Slice(filtered, func(a, b int) bool {
+			return rank[filtered[a]] < rank[filtered[b]]
+		})
+		node := tree.root
+		for _, item := range filtered {
+			child, exists := node.children[item]
+			if !exists {
+				child = &fpNode{item: item, parent: node, children: make(map[int32]*fpNode)}
+				node.children[item] = child
+				child.next = tree.headers[item]
+				tree.headers[item] = child
+			}
+			child.count += weights[i]
+			node = child
+		}
+	}
+	return tree
+}
+
+// mineFPTree finds all itemsets with support >= minSupport and minSize <= size <= maxSize
+func mineFPTree(tree *fpTree, suffix []int32, minSupport, minSize, maxSize int, results *[]Itemset) {
+	for _, item := range tree.items {
+		itemset := make([]int32, 0, len(suffix)+1)
+		itemset = append(itemset, item)
+		itemset = append(itemset, suffix...)
+		if len(itemset) >= minSize {
+			*results = append(*results, Itemset{Items: itemset, Support: tree.support[item]})
+		}
+		if len(itemset) >= maxSize {
+			continue
+		}
+		// Conditional pattern base: the prefix paths of all nodes of the item
+		var paths [][]int32
+		var weights []int
+		for node := tree.headers[item]; node != nil; node = node.next {
+			var path []int32
+			for parent := node.parent; parent != nil && parent.item >= 0; parent = parent.parent {
+				path = append(path, parent.item)
+			}
+			if len(path) > 0 {
+				paths = append(paths, path)
+				weights = append(weights, node.count)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		conditionalTree := buildFPTree(paths, weights, minSupport)
+		if len(conditionalTree.items) > 0 {
+			mineFPTree(conditionalTree, itemset, minSupport, minSize, maxSize, results)
+		}
+	}
+}
+
+// splitKey splits a "<key>-<size>" key
+func splitKey(key string) (string, uint64) {
+	separator := strings.LastIndex(key, "-")
+	size, _ := strconv.ParseUint(key[separator+1:], 10, 64)
+	return key[:separator], size
+}
+
+// writePairRecords writes the pairs with frequency greater than 1 as records, sorted by the pair
+func writePairRecords(globalFrequencyMap map[string]WindowPairInfo, outputFileName, weightFileName, format string) error {
+	var frequencies []KeyPairRecord
+	var weights []PairWeightRecord
+	pairKeys := make([]string, 0, len(globalFrequencyMap))
+	for pairKey, pairInfo := range globalFrequencyMap {
+		if pairInfo.Frequency > 1 {
+			pairKeys = append(pairKeys, pairKey)
+		}
+	}
+	sort.Strings(pairKeys)
+	for _, pairKey := range pairKeys {
+		pairInfo := globalFrequencyMap[pairKey]
+		first, second, _ := strings.Cut(pairKey, ";")
+		key1, size1 := splitKey(first)
+		key2, size2 := splitKey(second)
+		var blockIDs []uint64
+		for _, blockID := range strings.Split(pairInfo.BlockIDs, ";") {
+			id, _ := strconv.ParseUint(blockID, 10, 64)
+			blockIDs = append(blockIDs, id)
+		}
+		frequencies = append(frequencies, KeyPairRecord{Key1: key1, Size1: size1, Key2: key2, Size2: size2, Frequency: pairInfo.Frequency, BlockIDs: blockIDs})
+		if weightFileName != "" {
+			weights = append(weights, PairWeightRecord{Key1: key1, Size1: size1, Key2: key2, Size2: size2, Weight: pairInfo.Weight})
+		}
+	}
+	if err := writeRecords(outputFileName, format, frequencies); err != nil {
+		return err
+	}
+	if weightFileName != "" {
+		return writeRecords(weightFileName, format, weights)
+	}
+	return nil
+}
+
+func ProcessLogWindow(inputFile, opType string, window int, decay float64, minSupport, maxItemsetSize, startID, endID int, outputPathPrefix, format string) error {
+
+	fmt.Printf("Processing %s, op=%s, window=%d, decay=%.3f\n", inputFile, opType, window, decay)
+
+	// Open the input log file
+	file, err := os.Open(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %v", err)
+	}
+	defer file.Close()
+
+	// Regex to match block start and end lines
+	startRegex := regexp.MustCompile(`Processing block \(start\), ID: (\d+)`)
+	endRegex := regexp.MustCompile(`Processing block \(end\), ID: (\d+)`)
+	opRegex := regexp.MustCompile(`OPType: ` + opType + `, key: ([0-9a-fA-F]+), size: (\d+)`)
+
+	var currentBlockID string
+	var foundStartID bool
+
+	// Global frequency map of all pairs within the window
+	globalFrequencyMap := make(map[string]WindowPairInfo)
+	var windowKeys []string // The accessed keys (key-size) within the current block
+
+	// Interned key IDs and the per-block transactions for the frequent itemset mining
+	keyIDs := make(map[string]int32)
+	var keyNames []string
+	var transactions [][]int32
+	blockItems := make(map[int32]struct{})
+
+	reader := bufio.NewReader(file)
+	var lineCount uint64
+
+	for {
+		lineCount++
+		if lineCount%10000 == 0 {
+			fmt.Printf("\rProcessed %d lines", lineCount)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// If we reach the end of the file, break the loop
+			break
+		}
+
+		// Check if the line is the start of a block
+		if matches := startRegex.FindStringSubmatch(line); matches != nil {
+			currentBlockID = matches[1]
+			windowKeys = nil // Reset the window for the new block
+			blockIDInt, err := strconv.Atoi(currentBlockID)
+			if err != nil {
+				fmt.Println("Error converting current block ID to integer:", err)
+				continue
+			}
+			if blockIDInt >= startID {
+				foundStartID = true
+			}
+			continue
+		}
+
+		// Skip all lines until we have found the line with startID
+		if !foundStartID {
+			continue
+		}
+
+		if matches := opRegex.FindStringSubmatch(line); matches != nil {
+			key := matches[1] + "-" + matches[2]
+
+			// Pair the key with every key within the window, distance 0 means adjacent accesses
+			for distance := 0; distance < window && distance < len(windowKeys); distance++ {
+				previousKey := windowKeys[len(windowKeys)-1-distance]
+				pairKey := previousKey + ";" + key
+				if previousKey > key {
+					pairKey = key + ";" + previousKey
+				}
+				pairInfo, exists := globalFrequencyMap[pairKey]
+				if !exists {
+					pairInfo.BlockIDs = currentBlockID
+					pairInfo.LastBlock = currentBlockID
+				} else if pairInfo.LastBlock != currentBlockID {
+					pairInfo.BlockIDs += ";" + currentBlockID
+					pairInfo.LastBlock = currentBlockID
+				}
+				pairInfo.Frequency++
+				pairInfo.Weight += math.Pow(decay, float64(distance))
+				globalFrequencyMap[pairKey] = pairInfo
+			}
+			windowKeys = append(windowKeys, key)
+			if len(windowKeys) > window {
+				windowKeys = windowKeys[1:]
+			}
+
+			if minSupport > 0 {
+				id, exists := keyIDs[key]
+				if !exists {
+					id = int32(len(keyNames))
+					keyIDs[key] = id
+					keyNames = append(keyNames, key)
+				}
+				blockItems[id] = struct{}{}
+			}
+			continue
+		}
+
+		// Check if the line is the end of the block
+		if matches := endRegex.FindStringSubmatch(line); matches != nil {
+			endBlockID := matches[1]
+			if endBlockID != currentBlockID {
+				return fmt.Errorf("block ID mismatch: start ID %s, end ID %s", currentBlockID, endBlockID)
+			}
+			if len(blockItems) > 0 {
+				transaction := make([]int32, 0, len(blockItems))
+				for id := range blockItems {
+					transaction = append(transaction, id)
+				}
+				transactions = append(transactions, transaction)
+				blockItems = make(map[int32]struct{})
+			}
+			endIDInt, err := strconv.Atoi(endBlockID)
+			if err != nil {
+				fmt.Println("Error converting end block ID to integer:", err)
+				continue
+			}
+			if endIDInt >= endID {
+				break
+			}
+		}
+	}
+	fmt.Printf("\nThe final processed block ID is %s\n", currentBlockID)
+
+	logname := strings.ReplaceAll(inputFile, "/", "")
+	outputFileName := fmt.Sprintf("%srawWindowFreq-%d-%d-%s-Win%d-%s.log", outputPathPrefix, startID, endID, opType, window, logname)
+	weightFileName := fmt.Sprintf("%srawWindowWeight-%d-%d-%s-Win%d-Decay%g-%s.log", outputPathPrefix, startID, endID, opType, window, decay, logname)
+	if format != "text" {
+		if decay >= 1 {
+			weightFileName = ""
+		} else {
+			weightFileName = outputPath(weightFileName, format)
+		}
+		if err := writePairRecords(globalFrequencyMap, outputPath(outputFileName, format), weightFileName, format); err != nil {
+			return err
+		}
+		return mineItemsets(transactions, keyNames, minSupport, maxItemsetSize, outputPath(fmt.Sprintf("%sitemsets-%d-%d-%s-Sup%d-%s.log", outputPathPrefix, startID, endID, opType, minSupport, logname), format), format)
+	}
+	outputFile, err := os.Create(outputFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outputFile.Close()
+	writer := bufio.NewWriter(outputFile)
+
+	// The weighted results are only useful with decay, they are written to a separate log to keep the
+	// rawWindowFreq log in the same format as the rawFreq logs (i.e., it can be merged by analysisReadCorrelation)
+	var weightWriter *bufio.Writer
+	if decay < 1 {
+		weightFile, err := os.Create(weightFileName)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer weightFile.Close()
+		weightWriter = bufio.NewWriter(weightFile)
+	}
+
+	for pairKey, pairInfo := range globalFrequencyMap {
+		// Only write the log if the frequency is greater than 1
+		if pairInfo.Frequency <= 1 {
+			continue
+		}
+		if _, err := writer.WriteString(fmt.Sprintf("key: %s; Freq: %d; Blocks: %s\n", pairKey, pairInfo.Frequency, pairInfo.BlockIDs)); err != nil {
+			return fmt.Errorf("failed to write to output file: %v", err)
+		}
+		if weightWriter != nil {
+			if _, err := weightWriter.WriteString(fmt.Sprintf("key: %s; Weight: %.6f\n", pairKey, pairInfo.Weight)); err != nil {
+				return fmt.Errorf("failed to write to output file: %v", err)
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write to output file: %v", err)
+	}
+	if weightWriter != nil {
+		if err := weightWriter.Flush(); err != nil {
+			return fmt.Errorf("failed to write to output file: %v", err)
+		}
+	}
+	fmt.Printf("Pairs within the window are written to %s\n", outputFileName)
+
+	itemsetFileName := fmt.Sprintf("%sitemsets-%d-%d-%s-Sup%d-%s.log", outputPathPrefix, startID, endID, opType, minSupport, logname)
+	return mineItemsets(transactions, keyNames, minSupport, maxItemsetSize, itemsetFileName, format)
+}
+
+// mineItemsets mines the groups of at least 3 keys that are accessed in the same blocks, and writes them to the itemset file
+func mineItemsets(transactions [][]int32, keyNames []string, minSupport, maxItemsetSize int, itemsetFileName, format string) error {
+	if minSupport <= 0 {
+		return nil
+	}
+
+	// Mine the groups of at least 3 keys that are accessed in the same blocks
+	fmt.Printf("Mining frequent itemsets from %d blocks, min support: %d\n", len(transactions), minSupport)
+	weights := make([]int, len(transactions))
+	for i := range weights {
+		weights[i] = 1
+	}
+	tree := buildFPTree(transactions, weights, minSupport)
+	var itemsets []Itemset
+	mineFPTree(tree, nil, minSupport, 3, maxItemsetSize, &itemsets)
+	sort.Slice(itemsets, func(i, j int) bool {
+		if itemsets[i].Support != itemsets[j].Support {
+			return itemsets[i].Support > itemsets[j].Support
+		}
+		return len(itemsets[i].Items) > len(itemsets[j].Items)
+	})
+
+	if format != "text" {
+		records := make([]ItemsetRecord, 0, len(itemsets))
+		for _, itemset := range itemsets {
+			keys := make([]string, 0, len(itemset.Items))
+			for _, id := range itemset.Items {
+				keys = append(keys, keyNames[id])
+			}
+			sort.Strings(keys)
+			records = append(records, ItemsetRecord{Keys: keys, Size: len(keys), Support: itemset.Support})
+		}
+		return writeRecords(itemsetFileName, format, records)
+	}
+	itemsetFile, err := os.Create(itemsetFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer itemsetFile.Close()
+	itemsetWriter := bufio.NewWriter(itemsetFile)
+	for _, itemset := range itemsets {
+		keys := make([]string, 0, len(itemset.Items))
+		for _, id := range itemset.Items {
+			keys = append(keys, keyNames[id])
+		}
+		sort.Strings(keys)
+		if _, err := itemsetWriter.WriteString(fmt.Sprintf("keys: %s; Size: %d; Support: %d\n", strings.Join(keys, ";"), len(keys), itemset.Support)); err != nil {
+			return fmt.Errorf("failed to write to output file: %v", err)
+		}
+	}
+	if err := itemsetWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to write to output file: %v", err)
+	}
+	fmt.Printf("%d frequent itemsets are written to %s\n", len(itemsets), itemsetFileName)
+	return nil
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or csv")
+	flag.Parse()
+	args := append([]string{os.Args[0]}, flag.Args()...)
+	if len(args) < 10 || (*format != "text" && *format != "json" && *format != "csv") {
+		fmt.Println("Usage: program [--format=json|csv|text] <log_file_path> <output_path_prefix> <op_type(Get|Update)> <window_size> <decay> <min_support> <max_itemset_size> <start_block_id> <end_block_id>")
+		fmt.Println("  decay: the weight of a pair at distance d is decay^d, 1 disables the decay")
+		fmt.Println("  min_support: the minimum number of blocks for a frequent itemset, 0 disables the itemset mining")
+		return
+	}
+	logFile := args[1]
+	outputPathPrefix := args[2]
+	opType := args[3]
+	window, _ := strconv.Atoi(args[4])
+	decay, _ := strconv.ParseFloat(args[5], 64)
+	minSupport, _ := strconv.Atoi(args[6])
+	maxItemsetSize, _ := strconv.Atoi(args[7])
+	startID, _ := strconv.Atoi(args[8])
+	endID, _ := strconv.Atoi(args[9])
+	if window <= 0 {
+		fmt.Println("Invalid window size:", args[4])
+		return
+	}
+	if decay <= 0 || decay > 1 {
+		decay = 1
+	}
+	if maxItemsetSize < 3 {
+		maxItemsetSize = 3
+	}
+
+	err := ProcessLogWindow(logFile, opType, window, decay, minSupport, maxItemsetSize, startID, endID, outputPathPrefix, *format)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
@@ -0,0 +1,1045 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Table is a summary table of the report
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Figure is a plot embedded in the report
+type Figure struct {
+	Caption string
+	PNG     []byte
+}
+
+// ReportItem is a note, a table, or a figure of a section
+type ReportItem struct {
+	Note   string
+	Table  *Table
+	Figure *Figure
+}
+
+// Section is a section of the report, whose items are rendered in order
+type Section struct {
+	Title string
+	Items []ReportItem
+}
+
+// ResultFile is an input file of the report
+type ResultFile struct {
+	Path       string
+	Name       string
+	Size       int64
+	Parameters string // Parameters inferred from the file name
+}
+
+// StorageStats are the statistics of a data type in pebble-database-KV-count.{txt,json,csv}
+type StorageStats struct {
+	DataType     string
+	Count        uint64
+	AverageSize  float64
+	MinKeySize   uint64
+	MaxKeySize   uint64
+	MinValueSize uint64
+	MaxValueSize uint64
+	Directory    string // Where the size histograms are
+}
+
+// ReportInputs are the result files found in the results directories, grouped by the tool that produced them
+type ReportInputs struct {
+	Files             []*ResultFile
+	Parameters        [][2]string // From the manifests, in order
+	StorageFiles      []string    // pebble-database-KV-count.{txt,json,csv}
+	OpCountFiles      []string    // countKVDist-<start>_<end>.{txt,json,csv}
+	FitFiles          []string    // distribution-fit.{txt,json,csv}
+	DistributionFiles []string    // distribution-<start>_<end>_<category>_<op>_dis.{txt,json,csv}
+	CategoryPairFiles []string    // [cache-]freq-category-<distance>.{log,json,csv} or category-sorted-<distance>.log
+	KeyPairFiles      []string    // [cache-]freq-sorted-<distance>.{log,json,csv}
+	PearsonFiles      []string    // categoryPearson-lag<lag>.csv
+}
+
+var (
+	blockRangeRegex   = regexp.MustCompile(`(\d+)_(\d+)`)
+	distanceRegex     = regexp.MustCompile(`(?:Dist|sorted-|category-)(\d+)`)
+	lagRegex          = regexp.MustCompile(`lag(\d+)`)
+	storageFileRegex  = regexp.MustCompile(`^pebble-database-KV-count\.(?:txt|json|csv)$`)
+	opCountFileRegex  = regexp.MustCompile(`^countKVDist-(\d+)_(\d+)\.(?:txt|json|csv)$`)
+	fitFileRegex      = regexp.MustCompile(`^distribution-fit\.(?:txt|json|csv)$`)
+	disFileRegex      = regexp.MustCompile(`^distribution-(\d+)_(\d+)_([A-Za-z0-9]+)_([a-z]+)_dis\.(?:txt|json|csv)$`)
+	categoryFileRegex = regexp.MustCompile(`(?:freq-category-\d+\.(?:log|json|csv)|category-sorted-\d+\.log)$`)
+	keyPairFileRegex  = regexp.MustCompile(`freq-sorted-\d+\.(?:log|json|csv)$`)
+	pearsonFileRegex  = regexp.MustCompile(`^categoryPearson-lag\d+\.csv$`)
+	categoryLineRegex = regexp.MustCompile(`^Category: (\S+)`)
+	opCountLineRegex  = regexp.MustCompile(`^\s+OPType: (\w+), Count: (\d+)`)
+	pairFreqLineRegex = regexp.MustCompile(`^([^;]+);([^:]+): (\d+)$`)
+	keyPairLineRegex  = regexp.MustCompile(`^key: (.*?); Freq: (\d+)`)
+)
+
+// topKeyFractions are the fractions of the hottest keys whose share of accesses are reported, as in analysisDistributionFit.go
+var topKeyFractions = []float64{0.001, 0.01, 0.1}
+
+// inferParameters returns the parameters that the tools encode in their output file names
+func inferParameters(name string) string {
+	var parameters []string
+	if matches := blockRangeRegex.FindStringSubmatch(name); matches != nil {
+		parameters = append(parameters, fmt.Sprintf("blocks %s-%s", matches[1], matches[2]))
+	}
+	if matches := distanceRegex.FindStringSubmatch(name); matches != nil {
+		parameters = append(parameters, "distance "+matches[1])
+	}
+	if matches := lagRegex.FindStringSubmatch(name); matches != nil {
+		parameters = append(parameters, "lag "+matches[1])
+	}
+	if strings.Contains(name, "cache-") {
+		parameters = append(parameters, "with cache")
+	}
+	return strings.Join(parameters, ", ")
+}
+
+// addPath adds a results directory (walked recursively) or a single result file
+func (inputs *ReportInputs) addPath(path string) error {
+	return filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		switch {
+		case storageFileRegex.MatchString(name):
+			inputs.StorageFiles = append(inputs.StorageFiles, filePath)
+		case opCountFileRegex.MatchString(name):
+			inputs.OpCountFiles = append(inputs.OpCountFiles, filePath)
+		case fitFileRegex.MatchString(name):
+			inputs.FitFiles = append(inputs.FitFiles, filePath)
+		case disFileRegex.MatchString(name):
+			inputs.DistributionFiles = append(inputs.DistributionFiles, filePath)
+		case categoryFileRegex.MatchString(name):
+			inputs.CategoryPairFiles = append(inputs.CategoryPairFiles, filePath)
+		case keyPairFileRegex.MatchString(name):
+			inputs.KeyPairFiles = append(inputs.KeyPairFiles, filePath)
+		case pearsonFileRegex.MatchString(name):
+			inputs.PearsonFiles = append(inputs.PearsonFiles, filePath)
+		default:
+			return nil
+		}
+		inputs.Files = append(inputs.Files, &ResultFile{
+			Path:       filePath,
+			Name:       name,
+			Size:       info.Size(),
+			Parameters: inferParameters(name),
+		})
+		return nil
+	})
+}
+
+// readManifest reads a manifest, where each line is a results directory or file (relative to the manifest), or a
+// parameter formatted as "name = value", lines starting with "#" are comments
+func (inputs *ReportInputs) readManifest(manifestPath string) error {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest %s: %v", manifestPath, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, value, found := strings.Cut(line, "="); found {
+			inputs.Parameters = append(inputs.Parameters, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(manifestPath), line)
+		}
+		if err := inputs.addPath(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// outermostRanges keeps the files whose block range is not covered by the range of another file, so that the per-batch
+// outputs are not counted twice together with the output of the whole range
+func outermostRanges(paths []string, nameRegex *regexp.Regexp, groupOf func(matches []string) string) []string {
+	type rangeFile struct {
+		path       string
+		group      string
+		start, end uint64
+	}
+	var files []rangeFile
+	for _, path := range paths {
+		matches := nameRegex.FindStringSubmatch(filepath.Base(path))
+		start, _ := strconv.ParseUint(matches[1], 10, 64)
+		end, _ := strconv.ParseUint(matches[2], 10, 64)
+		files = append(files, rangeFile{path: path, group: groupOf(matches), start: start, end: end})
+	}
+	var kept []string
+	for i, file := range files {
+		covered := false
+		for j, other := range files {
+			if i == j || file.group != other.group || other.start > file.start || other.end < file.end {
+				continue
+			}
+			// Of two files with the same range, keep the first one
+			if other.start != file.start || other.end != file.end || j < i {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, file.path)
+		}
+	}
+	return kept
+}
+
+func readStorageStats(path string) ([]*StorageStats, error) {
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		var results []*StorageStats
+		for _, record := range records {
+			results = append(results, &StorageStats{
+				DataType:     record["data_type"],
+				Count:        recordUint(record, "kv_pairs"),
+				AverageSize:  recordFloat(record, "average_kv_size"),
+				MinKeySize:   recordUint(record, "min_key_size"),
+				MaxKeySize:   recordUint(record, "max_key_size"),
+				MinValueSize: recordUint(record, "min_value_size"),
+				MaxValueSize: recordUint(record, "max_value_size"),
+				Directory:    filepath.Dir(path),
+			})
+		}
+		return results, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var results []*StorageStats
+	var current *StorageStats
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "DataType: ") {
+			current = &StorageStats{DataType: strings.TrimPrefix(line, "DataType: "), Directory: filepath.Dir(path)}
+			results = append(results, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		name, value, found := strings.Cut(strings.TrimSpace(line), ": ")
+		if !found {
+			continue
+		}
+		switch name {
+		case "KV pair number":
+			current.Count, _ = strconv.ParseUint(value, 10, 64)
+		case "Average KV size":
+			current.AverageSize, _ = strconv.ParseFloat(value, 64)
+		case "Min size for keys":
+			current.MinKeySize, _ = strconv.ParseUint(value, 10, 64)
+		case "Max size for keys":
+			current.MaxKeySize, _ = strconv.ParseUint(value, 10, 64)
+		case "Min size for values":
+			current.MinValueSize, _ = strconv.ParseUint(value, 10, 64)
+		case "Max size for values":
+			current.MaxValueSize, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// readOpCounts adds the operation counts of a countKVDist-* file, the heavy hitter section reports totals instead of
+// counts and is skipped. The .json and .csv outputs hold the counts only
+func readOpCounts(path string, opCounts map[string]map[string]uint64) error {
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if _, exists := opCounts[record["category"]]; !exists {
+				opCounts[record["category"]] = make(map[string]uint64)
+			}
+			opCounts[record["category"]][record["op_type"]] += recordUint(record, "count")
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	category := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := categoryLineRegex.FindStringSubmatch(line); matches != nil {
+			category = matches[1]
+			continue
+		}
+		if matches := opCountLineRegex.FindStringSubmatch(line); matches != nil && category != "" {
+			count, _ := strconv.ParseUint(matches[2], 10, 64)
+			if _, exists := opCounts[category]; !exists {
+				opCounts[category] = make(map[string]uint64)
+			}
+			opCounts[category][matches[1]] += count
+		}
+	}
+	return scanner.Err()
+}
+
+// readTable reads a tab-separated file with a header
+func readTable(path string) (Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Table{}, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var table Table
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if table.Header == nil {
+			table.Header = fields
+			continue
+		}
+		table.Rows = append(table.Rows, fields)
+	}
+	return table, scanner.Err()
+}
+
+// readDistributionSkew returns the number of keys, the accesses, and the share of the accesses of the hottest keys of
+// a *_dis.txt file, whose last column is the access count, or of a *_dis.json or *_dis.csv file
+func readDistributionSkew(path string) (uint64, uint64, []float64, error) {
+	var counts []uint64
+	var total uint64
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		for _, record := range records {
+			count := recordUint(record, "count")
+			counts = append(counts, count)
+			total += count
+		}
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), "\t")
+			countIndex := len(fields) - 1
+			// The streaming distributions end with an error column
+			if len(fields) == 4 {
+				countIndex = 2
+			}
+			count, err := strconv.ParseUint(fields[countIndex], 10, 64)
+			if err != nil {
+				continue
+			}
+			counts = append(counts, count)
+			total += count
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i] > counts[j]
+	})
+	shares := make([]float64, len(topKeyFractions))
+	for i, fraction := range topKeyFractions {
+		topKeys := max(int(float64(len(counts))*fraction), 1)
+		var topTotal uint64
+		for _, count := range counts[:min(topKeys, len(counts))] {
+			topTotal += count
+		}
+		if total > 0 {
+			shares[i] = float64(topTotal) / float64(total)
+		}
+	}
+	return uint64(len(counts)), total, shares, nil
+}
+
+// readTopLines returns the first n lines matching the regex, and the total frequency if the file reports it at the end
+func readTopLines(path string, lineRegex *regexp.Regexp, n int, hasTotal bool) ([][]string, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var results [][]string
+	var totalFrequency uint64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Total frequency: ") {
+			totalFrequency, _ = strconv.ParseUint(strings.TrimPrefix(line, "Total frequency: "), 10, 64)
+			continue
+		}
+		if len(results) >= n {
+			if !hasTotal {
+				break
+			}
+			continue
+		}
+		if matches := lineRegex.FindStringSubmatch(line); matches != nil {
+			results = append(results, matches[1:])
+		}
+	}
+	return results, totalFrequency, scanner.Err()
+}
+
+// readTopRecords returns the fields of the first n records of a .json or .csv output, and the total frequency if the
+// records report it
+func readTopRecords(path string, n int, fields func(record map[string]string) []string) ([][]string, uint64, error) {
+	records, err := readFirstRecords(path, n)
+	if err != nil {
+		return nil, 0, err
+	}
+	var results [][]string
+	var totalFrequency uint64
+	for _, record := range records {
+		results = append(results, fields(record))
+		totalFrequency = recordUint(record, "total_frequency")
+	}
+	return results, totalFrequency, nil
+}
+
+// readFitTable reads distribution-fit.txt, or converts the records of distribution-fit.{json,csv} to the same table
+func readFitTable(path string) (Table, error) {
+	if !isStructured(path) {
+		return readTable(path)
+	}
+	records, err := readRecords(path)
+	if err != nil {
+		return Table{}, err
+	}
+	table := Table{Header: []string{"Category", "OPType", "Keys", "Accesses", "ZipfS", "PLAlpha"}}
+	for _, fraction := range topKeyFractions {
+		table.Header = append(table.Header, fmt.Sprintf("Top%g%%", fraction*100))
+	}
+	for _, record := range records {
+		row := []string{record["category"], record["op_type"], record["keys"], record["accesses"],
+			formatRecordFloat(record["zipf_s"]), formatRecordFloat(record["pl_alpha"])}
+		for _, share := range strings.Fields(strings.Trim(record["top_share"], "[]")) {
+			row = append(row, formatRecordFloat(share))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// recordUint parses an integer field of a record, which JSON may write in exponent form, a missing field is 0
+func recordUint(record map[string]string, name string) uint64 {
+	return uint64(recordFloat(record, name))
+}
+
+// recordFloat parses a float field of a record, a missing field is 0
+func recordFloat(record map[string]string, name string) float64 {
+	value, _ := strconv.ParseFloat(record[name], 64)
+	return value
+}
+
+// formatRecordFloat formats a float field as the text outputs do, "-" if it is missing
+func formatRecordFloat(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) {
+		return "-"
+	}
+	return fmt.Sprintf("%.6f", f)
+}
+
+// dropOtherFormats keeps one file of each output written in several formats, e.g. the text log next to its .json copy
+func dropOtherFormats(paths []string) []string {
+	seen := make(map[string]bool)
+	var kept []string
+	for _, path := range paths {
+		stem := strings.TrimSuffix(path, filepath.Ext(path))
+		if !seen[stem] {
+			seen[stem] = true
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.2f %s", bytes, units[unit])
+}
+
+func formatShare(part, total float64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", part/total*100)
+}
+
+// printableDataType quotes the raw key prefixes that are not printable
+func printableDataType(dataType string) string {
+	if strconv.CanBackquote(dataType) {
+		return dataType
+	}
+	return strconv.Quote(dataType)
+}
+
+// Plotter renders the figures with the plotResults tool
+type Plotter struct {
+	Path    string
+	WorkDir string
+	count   int
+}
+
+// Plot runs a plotResults subcommand and returns the PNG, nil if the figure cannot be drawn
+func (p *Plotter) Plot(arguments ...string) []byte {
+	if p == nil {
+		return nil
+	}
+	p.count++
+	output := filepath.Join(p.WorkDir, fmt.Sprintf("figure-%d.png", p.count))
+	command := exec.Command(p.Path, append([]string{arguments[0], "-o", output}, arguments[1:]...)...)
+	if combined, err := command.CombinedOutput(); err != nil {
+		fmt.Printf("Failed to plot %v: %v\n%s", arguments, err, combined)
+		return nil
+	}
+	figure, err := os.ReadFile(output)
+	if err != nil {
+		fmt.Println("Error reading figure:", err)
+		return nil
+	}
+	return figure
+}
+
+// findPlotter looks for plotResults next to this executable, then in PATH
+func findPlotter(path string) string {
+	if path != "" {
+		return path
+	}
+	if executable, err := os.Executable(); err == nil {
+		candidate := filepath.Join(filepath.Dir(executable), "plotResults")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	if candidate, err := exec.LookPath("plotResults"); err == nil {
+		return candidate
+	}
+	return ""
+}
+
+func (section *Section) addNote(note string) {
+	section.Items = append(section.Items, ReportItem{Note: note})
+}
+
+func (section *Section) addTable(table Table) {
+	section.Items = append(section.Items, ReportItem{Table: &table})
+}
+
+// addFigure adds a figure, which is skipped if it cannot be drawn
+func (section *Section) addFigure(caption string, png []byte) {
+	if png != nil {
+		section.Items = append(section.Items, ReportItem{Figure: &Figure{Caption: caption, PNG: png}})
+	}
+}
+
+func storageSection(inputs *ReportInputs, plotter *Plotter, top int) (*Section, error) {
+	section := &Section{Title: "Storage per category"}
+	var stats []*StorageStats
+	storageFiles := dropOtherFormats(inputs.StorageFiles)
+	for _, path := range storageFiles {
+		fileStats, err := readStorageStats(path)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, fileStats...)
+	}
+	var totalSize float64
+	for _, s := range stats {
+		totalSize += float64(s.Count) * s.AverageSize
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		sizeI, sizeJ := float64(stats[i].Count)*stats[i].AverageSize, float64(stats[j].Count)*stats[j].AverageSize
+		if sizeI != sizeJ {
+			return sizeI > sizeJ
+		}
+		return stats[i].DataType < stats[j].DataType
+	})
+	if len(storageFiles) > 1 {
+		section.addNote(fmt.Sprintf("The data types of %d databases are listed together.", len(storageFiles)))
+	}
+	section.addNote(fmt.Sprintf("%d data types, %s of keys and values in total.", len(stats), formatBytes(totalSize)))
+	table := Table{Header: []string{"Data type", "KV pairs", "Average KV size (B)", "Total size", "Share", "Key size (B)", "Value size (B)"}}
+	for _, s := range stats {
+		size := float64(s.Count) * s.AverageSize
+		table.Rows = append(table.Rows, []string{
+			printableDataType(s.DataType),
+			strconv.FormatUint(s.Count, 10),
+			fmt.Sprintf("%.2f", s.AverageSize),
+			formatBytes(size),
+			formatShare(size, totalSize),
+			fmt.Sprintf("%d-%d", s.MinKeySize, s.MaxKeySize),
+			fmt.Sprintf("%d-%d", s.MinValueSize, s.MaxValueSize),
+		})
+	}
+	section.addTable(table)
+
+	// The value size CDF of the largest data types
+	var histograms []string
+	for _, s := range stats {
+		if len(histograms) >= top {
+			break
+		}
+		for _, extension := range []string{".txt", ".json", ".csv"} {
+			histogram := filepath.Join(s.Directory, s.DataType+"_value_histogram"+extension)
+			if _, err := os.Stat(histogram); err == nil {
+				histograms = append(histograms, histogram)
+				break
+			}
+		}
+	}
+	if len(histograms) > 0 {
+		section.addFigure("Value size CDF of the largest data types",
+			plotter.Plot(append([]string{"cdf", "-logx", "-title", "Value size CDF"}, histograms...)...))
+	}
+	return section, nil
+}
+
+func opMixSection(inputs *ReportInputs, plotter *Plotter, top int) (*Section, error) {
+	section := &Section{Title: "Operation mix"}
+	files := outermostRanges(inputs.OpCountFiles, opCountFileRegex, func([]string) string { return "" })
+	opCounts := make(map[string]map[string]uint64)
+	for _, path := range files {
+		if err := readOpCounts(path, opCounts); err != nil {
+			return nil, err
+		}
+	}
+	section.addNote(fmt.Sprintf("Summed over %d countKVDist files; the files covered by the block range of another file are skipped.", len(files)))
+
+	opTypeSet := make(map[string]struct{})
+	categoryTotals := make(map[string]uint64)
+	var total uint64
+	for category, counts := range opCounts {
+		for opType, count := range counts {
+			opTypeSet[opType] = struct{}{}
+			categoryTotals[category] += count
+			total += count
+		}
+	}
+	opTypes := make([]string, 0, len(opTypeSet))
+	for opType := range opTypeSet {
+		opTypes = append(opTypes, opType)
+	}
+	sort.Strings(opTypes)
+	categories := make([]string, 0, len(categoryTotals))
+	for category := range categoryTotals {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categoryTotals[categories[i]] != categoryTotals[categories[j]] {
+			return categoryTotals[categories[i]] > categoryTotals[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	table := Table{Header: append(append([]string{"Category"}, opTypes...), "Total", "Share")}
+	for _, category := range categories {
+		row := []string{category}
+		for _, opType := range opTypes {
+			row = append(row, strconv.FormatUint(opCounts[category][opType], 10))
+		}
+		row = append(row, strconv.FormatUint(categoryTotals[category], 10), formatShare(float64(categoryTotals[category]), float64(total)))
+		table.Rows = append(table.Rows, row)
+	}
+	section.addTable(table)
+
+	if plotter != nil && len(categories) > 0 {
+		// The summed counts are written in the countKVDist format for plotResults
+		mergedPath := filepath.Join(plotter.WorkDir, "countKVDist-merged.txt")
+		mergedFile, err := os.Create(mergedPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", mergedPath, err)
+		}
+		for _, category := range categories {
+			fmt.Fprintf(mergedFile, "Category: %s\n", category)
+			for _, opType := range opTypes {
+				if count, exists := opCounts[category][opType]; exists {
+					fmt.Fprintf(mergedFile, "  OPType: %s, Count: %d\n", opType, count)
+				}
+			}
+		}
+		mergedFile.Close()
+		topArgument := strconv.Itoa(top)
+		section.addFigure("Operations of the busiest categories",
+			plotter.Plot("opmix", "-top", topArgument, "-title", "Operations per category", mergedPath))
+		section.addFigure("Operation type share of the busiest categories",
+			plotter.Plot("opmix", "-top", topArgument, "-share", "-title", "Operation type share per category", mergedPath))
+	}
+	return section, nil
+}
+
+func skewSection(inputs *ReportInputs, plotter *Plotter, top int) (*Section, error) {
+	section := &Section{Title: "Access skew"}
+	topHeaders := make([]string, len(topKeyFractions))
+	for i, fraction := range topKeyFractions {
+		topHeaders[i] = fmt.Sprintf("Top%g%%", fraction*100)
+	}
+
+	if len(inputs.FitFiles) > 0 {
+		section.addNote("From distribution-fit; the share of the accesses of the hottest keys, and the fitted Zipf exponent and power-law alpha.")
+		for _, path := range dropOtherFormats(inputs.FitFiles) {
+			fit, err := readFitTable(path)
+			if err != nil {
+				return nil, err
+			}
+			columns := append([]string{"Category", "OPType", "Keys", "Accesses", "ZipfS", "PLAlpha"}, topHeaders...)
+			indexes := make([]int, len(columns))
+			for i, column := range columns {
+				indexes[i] = -1
+				for j, header := range fit.Header {
+					if header == column {
+						indexes[i] = j
+					}
+				}
+			}
+			table := Table{Header: columns}
+			for _, row := range fit.Rows {
+				selected := make([]string, len(columns))
+				for i, index := range indexes {
+					selected[i] = "-"
+					if index >= 0 && index < len(row) {
+						selected[i] = row[index]
+					}
+				}
+				table.Rows = append(table.Rows, selected)
+			}
+			section.addTable(table)
+		}
+	}
+
+	files := outermostRanges(inputs.DistributionFiles, disFileRegex, func(matches []string) string {
+		return matches[3] + "_" + matches[4]
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return filepath.Base(files[i]) < filepath.Base(files[j])
+	})
+	type skewRow struct {
+		path     string
+		row      []string
+		accesses uint64
+	}
+	var skewRows []skewRow
+	if len(inputs.FitFiles) == 0 && len(files) > 0 {
+		section.addNote("From the distribution-*_dis files; the share of the accesses of the hottest keys.")
+		table := Table{Header: append([]string{"Category", "OPType", "Blocks", "Keys", "Accesses"}, topHeaders...)}
+		for _, path := range files {
+			matches := disFileRegex.FindStringSubmatch(filepath.Base(path))
+			keys, accesses, shares, err := readDistributionSkew(path)
+			if err != nil {
+				return nil, err
+			}
+			row := []string{matches[3], matches[4], matches[1] + "-" + matches[2], strconv.FormatUint(keys, 10), strconv.FormatUint(accesses, 10)}
+			for _, share := range shares {
+				row = append(row, fmt.Sprintf("%.2f%%", share*100))
+			}
+			table.Rows = append(table.Rows, row)
+			skewRows = append(skewRows, skewRow{path: path, row: row, accesses: accesses})
+		}
+		section.addTable(table)
+	}
+
+	// The rank-frequency of the most accessed distributions
+	if len(files) > 0 {
+		if skewRows == nil {
+			for _, path := range files {
+				skewRows = append(skewRows, skewRow{path: path})
+			}
+		}
+		sort.SliceStable(skewRows, func(i, j int) bool {
+			return skewRows[i].accesses > skewRows[j].accesses
+		})
+		var plotted []string
+		for _, row := range skewRows[:min(top, len(skewRows))] {
+			plotted = append(plotted, row.path)
+		}
+		section.addFigure("Rank-frequency of the key accesses",
+			plotter.Plot(append([]string{"rankfreq", "-title", "Rank-frequency"}, plotted...)...))
+	}
+	return section, nil
+}
+
+func correlationSection(inputs *ReportInputs, plotter *Plotter, top int) (*Section, error) {
+	section := &Section{Title: "Correlation"}
+	for _, path := range dropOtherFormats(inputs.CategoryPairFiles) {
+		var pairs [][]string
+		var totalFrequency uint64
+		var err error
+		if isStructured(path) {
+			pairs, totalFrequency, err = readTopRecords(path, top, func(record map[string]string) []string {
+				return []string{record["category1"], record["category2"], record["frequency"]}
+			})
+		} else {
+			pairs, totalFrequency, err = readTopLines(path, pairFreqLineRegex, top, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		section.addNote(fmt.Sprintf("Top %d category pairs of %s (%s), total frequency %d.", len(pairs), filepath.Base(path), inferParameters(filepath.Base(path)), totalFrequency))
+		table := Table{Header: []string{"Category", "Category", "Frequency", "Share"}}
+		for _, pair := range pairs {
+			frequency, _ := strconv.ParseFloat(pair[2], 64)
+			table.Rows = append(table.Rows, []string{pair[0], pair[1], pair[2], formatShare(frequency, float64(totalFrequency))})
+		}
+		section.addTable(table)
+		section.addFigure("Category pair frequencies of "+filepath.Base(path),
+			plotter.Plot("heatmap", "-width", "7", "-height", "7", "-title", "Co-accessed category pairs", path))
+	}
+	for _, path := range dropOtherFormats(inputs.KeyPairFiles) {
+		var pairs [][]string
+		var err error
+		if isStructured(path) {
+			pairs, _, err = readTopRecords(path, top, func(record map[string]string) []string {
+				return []string{fmt.Sprintf("%s-%s;%s-%s", record["key1"], record["size1"], record["key2"], record["size2"]), record["frequency"]}
+			})
+		} else {
+			pairs, _, err = readTopLines(path, keyPairLineRegex, top, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		section.addNote(fmt.Sprintf("Top %d key pairs of %s (%s).", len(pairs), filepath.Base(path), inferParameters(filepath.Base(path))))
+		table := Table{Header: []string{"Key pair", "Frequency"}}
+		for _, pair := range pairs {
+			table.Rows = append(table.Rows, []string{pair[0], pair[1]})
+		}
+		section.addTable(table)
+	}
+	for _, path := range inputs.PearsonFiles {
+		section.addFigure("Pearson correlation of the category time series, "+inferParameters(filepath.Base(path)),
+			plotter.Plot("heatmap", "-width", "7", "-height", "7", "-title", "Category Pearson correlation", path))
+	}
+	return section, nil
+}
+
+func parametersSection(inputs *ReportInputs, commandLine string) *Section {
+	section := &Section{Title: "Parameters"}
+	section.addNote("Report command: `" + commandLine + "`")
+	if len(inputs.Parameters) > 0 {
+		table := Table{Header: []string{"Parameter", "Value"}}
+		for _, parameter := range inputs.Parameters {
+			table.Rows = append(table.Rows, []string{parameter[0], parameter[1]})
+		}
+		section.addTable(table)
+	}
+	table := Table{Header: []string{"Input file", "Size", "Parameters from the file name"}}
+	for _, file := range inputs.Files {
+		table.Rows = append(table.Rows, []string{file.Path, formatBytes(float64(file.Size)), file.Parameters})
+	}
+	section.addTable(table)
+	return section
+}
+
+func escapeMarkdown(cell string) string {
+	return strings.ReplaceAll(cell, "|", "\\|")
+}
+
+func renderMarkdown(output *os.File, title string, sections []*Section) {
+	fmt.Fprintf(output, "# %s\n\n", title)
+	for _, section := range sections {
+		fmt.Fprintf(output, "## %s\n\n", section.Title)
+		for _, item := range section.Items {
+			switch {
+			case item.Table != nil:
+				header := make([]string, len(item.Table.Header))
+				separator := make([]string, len(item.Table.Header))
+				for i, cell := range item.Table.Header {
+					header[i] = escapeMarkdown(cell)
+					separator[i] = "---"
+				}
+				fmt.Fprintf(output, "| %s |\n| %s |\n", strings.Join(header, " | "), strings.Join(separator, " | "))
+				for _, row := range item.Table.Rows {
+					cells := make([]string, len(row))
+					for i, cell := range row {
+						cells[i] = escapeMarkdown(cell)
+					}
+					fmt.Fprintf(output, "| %s |\n", strings.Join(cells, " | "))
+				}
+				fmt.Fprintln(output)
+			case item.Figure != nil:
+				fmt.Fprintf(output, "![%s](data:image/png;base64,%s)\n\n", item.Figure.Caption, base64.StdEncoding.EncodeToString(item.Figure.PNG))
+			default:
+				fmt.Fprintf(output, "%s\n\n", item.Note)
+			}
+		}
+	}
+}
+
+func renderHTML(output *os.File, title string, sections []*Section) {
+	fmt.Fprintf(output, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintln(output, "<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:1em}th,td{border:1px solid #ccc;padding:2px 8px;text-align:right}th{background:#eee}td:first-child{text-align:left}figure{margin:0 0 1em 0}</style>")
+	fmt.Fprintf(output, "</head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title))
+	for _, section := range sections {
+		fmt.Fprintf(output, "<h2>%s</h2>\n", html.EscapeString(section.Title))
+		for _, item := range section.Items {
+			switch {
+			case item.Table != nil:
+				fmt.Fprintln(output, "<table>")
+				fmt.Fprint(output, "<tr>")
+				for _, cell := range item.Table.Header {
+					fmt.Fprintf(output, "<th>%s</th>", html.EscapeString(cell))
+				}
+				fmt.Fprintln(output, "</tr>")
+				for _, row := range item.Table.Rows {
+					fmt.Fprint(output, "<tr>")
+					for _, cell := range row {
+						fmt.Fprintf(output, "<td>%s</td>", html.EscapeString(cell))
+					}
+					fmt.Fprintln(output, "</tr>")
+				}
+				fmt.Fprintln(output, "</table>")
+			case item.Figure != nil:
+				fmt.Fprintf(output, "<figure><img src=\"data:image/png;base64,%s\" alt=\"%s\"><figcaption>%s</figcaption></figure>\n",
+					base64.StdEncoding.EncodeToString(item.Figure.PNG), html.EscapeString(item.Figure.Caption), html.EscapeString(item.Figure.Caption))
+			default:
+				// The inline code marks of the Markdown notes are dropped
+				fmt.Fprintf(output, "<p>%s</p>\n", html.EscapeString(strings.ReplaceAll(item.Note, "`", "")))
+			}
+		}
+	}
+	fmt.Fprintln(output, "</body>\n</html>")
+}
+
+func main() {
+	outputPath := flag.String("o", "report.md", "output report path, an .html extension gives an HTML report, otherwise Markdown")
+	title := flag.String("title", "Trace analysis report", "report title")
+	top := flag.Int("top", 10, "number of rows of the top-N tables and series of the figures")
+	plotterPath := flag.String("plotter", "", "path of the plotResults tool (default: next to this executable, then in PATH)")
+	noPlots := flag.Bool("no-plots", false, "do not embed figures")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Println("Usage: program [-o report.md|report.html] [-title title] [-top N] [-plotter path] [-no-plots] <results_dir_or_manifest>...")
+		fmt.Println("A manifest lists one results directory or file per line (relative to the manifest), and parameters as \"name = value\"")
+		return
+	}
+
+	inputs := &ReportInputs{}
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if info.IsDir() {
+			err = inputs.addPath(path)
+		} else {
+			err = inputs.readManifest(path)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	fmt.Printf("Found %d result files\n", len(inputs.Files))
+
+	var plotter *Plotter
+	if !*noPlots {
+		if path := findPlotter(*plotterPath); path == "" {
+			fmt.Println("plotResults is not found, the report is generated without figures")
+		} else {
+			workDir, err := os.MkdirTemp("", "report-figures-")
+			if err != nil {
+				fmt.Println("Error creating temporary directory:", err)
+				return
+			}
+			defer os.RemoveAll(workDir)
+			plotter = &Plotter{Path: path, WorkDir: workDir}
+		}
+	}
+
+	var sections []*Section
+	type sectionBuilder struct {
+		present bool
+		build   func(*ReportInputs, *Plotter, int) (*Section, error)
+	}
+	for _, builder := range []sectionBuilder{
+		{len(inputs.StorageFiles) > 0, storageSection},
+		{len(inputs.OpCountFiles) > 0, opMixSection},
+		{len(inputs.FitFiles)+len(inputs.DistributionFiles) > 0, skewSection},
+		{len(inputs.CategoryPairFiles)+len(inputs.KeyPairFiles)+len(inputs.PearsonFiles) > 0, correlationSection},
+	} {
+		if !builder.present {
+			continue
+		}
+		section, err := builder.build(inputs, plotter, *top)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		sections = append(sections, section)
+	}
+	sections = append(sections, parametersSection(inputs, strings.Join(os.Args, " ")))
+
+	output, err := os.Create(*outputPath)
+	if err != nil {
+		fmt.Println("Error creating output file:", *outputPath)
+		return
+	}
+	defer output.Close()
+	if strings.HasSuffix(strings.ToLower(*outputPath), ".html") {
+		renderHTML(output, *title, sections)
+	} else {
+		renderMarkdown(output, *title, sections)
+	}
+	fmt.Printf("Report is stored to: %s\n", *outputPath)
+}
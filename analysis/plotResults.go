@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// PlotOptions are the options shared by all subcommands
+type PlotOptions struct {
+	Output string
+	Title  string
+	Width  float64 // In inches
+	Height float64 // In inches
+}
+
+// matrixGrid implements plotter.GridXYZ for a square matrix, row 0 is drawn at the top
+type matrixGrid struct {
+	values [][]float64
+}
+
+var (
+	categoryLineRegex = regexp.MustCompile(`^Category: (\S+)`)
+	opCountLineRegex  = regexp.MustCompile(`^\s+OPType: (\w+), Count: (\d+)`)
+	pairFreqLineRegex = regexp.MustCompile(`^([^;]+);([^:]+): (\d+)$`)
+)
+
+const (
+	titleFontSize = 12
+	labelFontSize = 10
+	tickFontSize  = 8
+	lineWidth     = 1.2
+)
+
+func (g matrixGrid) Dims() (c, r int)   { return len(g.values), len(g.values) }
+func (g matrixGrid) Z(c, r int) float64 { return g.values[len(g.values)-1-r][c] }
+func (g matrixGrid) X(c int) float64    { return float64(c) }
+func (g matrixGrid) Y(r int) float64    { return float64(r) }
+
+// newPlot creates a plot with the styling shared by all figures
+func newPlot(title, xLabel, yLabel string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.Title.TextStyle.Font.Size = vg.Points(titleFontSize)
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = yLabel
+	for _, axis := range []*plot.Axis{&p.X, &p.Y} {
+		axis.Label.TextStyle.Font.Size = vg.Points(labelFontSize)
+		axis.Tick.Label.Font.Size = vg.Points(tickFontSize)
+	}
+	p.Legend.Top = true
+	p.Legend.TextStyle.Font.Size = vg.Points(tickFontSize)
+	grid := plotter.NewGrid()
+	grid.Vertical.Color = color.Gray{Y: 220}
+	grid.Horizontal.Color = color.Gray{Y: 220}
+	p.Add(grid)
+	return p
+}
+
+// useLogScale switches the axis to a logarithmic scale
+func useLogScale(axis *plot.Axis) {
+	axis.Scale = plot.LogScale{}
+	axis.Tick.Marker = plot.LogTicks{Prec: -1}
+}
+
+// addLine adds a line with the color and dashes of the index, so that the same input order gives the same styles
+func addLine(p *plot.Plot, name string, index int, points plotter.XYs) error {
+	line, err := plotter.NewLine(points)
+	if err != nil {
+		return err
+	}
+	line.Color = plotutil.Color(index)
+	line.Dashes = plotutil.Dashes(index)
+	line.Width = vg.Points(lineWidth)
+	p.Add(line)
+	p.Legend.Add(name, line)
+	return nil
+}
+
+func savePlot(p *plot.Plot, options PlotOptions) error {
+	if err := p.Save(vg.Length(options.Width)*vg.Inch, vg.Length(options.Height)*vg.Inch, options.Output); err != nil {
+		return fmt.Errorf("failed to save %s: %v", options.Output, err)
+	}
+	fmt.Println("Figure is stored to:", options.Output)
+	return nil
+}
+
+// seriesName returns the file name without the directory and the extension, used as the legend
+func seriesName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// readTwoColumns reads the first two numeric columns of a tab-separated file, skipping the header, or the size and
+// count fields of a .json or .csv histogram
+func readTwoColumns(path string) (plotter.XYs, error) {
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		var points plotter.XYs
+		for _, record := range records {
+			x, errX := strconv.ParseFloat(record["size"], 64)
+			y, errY := strconv.ParseFloat(record["count"], 64)
+			if errX != nil || errY != nil {
+				continue
+			}
+			points = append(points, plotter.XY{X: x, Y: y})
+		}
+		return points, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var points plotter.XYs
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		x, errX := strconv.ParseFloat(fields[0], 64)
+		y, errY := strconv.ParseFloat(fields[1], 64)
+		if errX != nil || errY != nil {
+			continue
+		}
+		points = append(points, plotter.XY{X: x, Y: y})
+	}
+	return points, scanner.Err()
+}
+
+// readCountColumn reads the "Count" column of a distribution file (ID\tCount, ID\tKey\tCount, or ID\tKey\tCount\tError),
+// or the count field of a .json or .csv distribution
+func readCountColumn(path string) ([]float64, error) {
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		var counts []float64
+		for _, record := range records {
+			if count, err := strconv.ParseFloat(record["count"], 64); err == nil {
+				counts = append(counts, count)
+			}
+		}
+		return counts, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	countIndex := -1
+	var counts []float64
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if countIndex < 0 {
+			for i, field := range fields {
+				if field == "Count" {
+					countIndex = i
+				}
+			}
+			if countIndex < 0 {
+				return nil, fmt.Errorf("no Count column in the header of %s", path)
+			}
+			continue
+		}
+		if len(fields) <= countIndex {
+			continue
+		}
+		count, err := strconv.ParseFloat(fields[countIndex], 64)
+		if err != nil {
+			continue
+		}
+		counts = append(counts, count)
+	}
+	return counts, scanner.Err()
+}
+
+// readOpCounts reads the operation counts of a countKVDist-* file, the heavy hitter section is skipped since it
+// reports totals instead of counts. The .json and .csv outputs hold the counts only
+func readOpCounts(path string) (map[string]map[string]float64, error) {
+	opCounts := make(map[string]map[string]float64)
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			count, _ := strconv.ParseFloat(record["count"], 64)
+			if _, exists := opCounts[record["category"]]; !exists {
+				opCounts[record["category"]] = make(map[string]float64)
+			}
+			opCounts[record["category"]][record["op_type"]] += count
+		}
+		return opCounts, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	category := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := categoryLineRegex.FindStringSubmatch(line); matches != nil {
+			category = matches[1]
+			continue
+		}
+		if matches := opCountLineRegex.FindStringSubmatch(line); matches != nil && category != "" {
+			count, _ := strconv.ParseFloat(matches[2], 64)
+			if _, exists := opCounts[category]; !exists {
+				opCounts[category] = make(map[string]float64)
+			}
+			opCounts[category][matches[1]] += count
+		}
+	}
+	return opCounts, scanner.Err()
+}
+
+// readMatrix reads a correlation matrix CSV (categoryPearson-lag*.csv), or the category pair frequencies of a
+// freq-category-* file (.log, .json, or .csv), which are converted to a symmetric matrix. It also returns whether the
+// matrix holds correlations instead of frequencies
+func readMatrix(path string) ([]string, [][]float64, bool, error) {
+	if isStructured(path) {
+		records, err := readRecords(path)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		// The matrix CSV has no category1 field
+		if strings.HasSuffix(path, ".json") || (len(records) > 0 && records[0]["category1"] != "") {
+			pairs := make(map[[2]string]float64)
+			for _, record := range records {
+				frequency, _ := strconv.ParseFloat(record["frequency"], 64)
+				pairs[[2]string{record["category1"], record["category2"]}] += frequency
+			}
+			names, values := pairMatrix(pairs)
+			return names, values, false, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	if strings.HasSuffix(path, ".csv") {
+		var names []string
+		var values [][]float64
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), ",")
+			if names == nil {
+				names = fields[1:]
+				continue
+			}
+			row := make([]float64, len(names))
+			for i := range row {
+				row[i] = math.NaN()
+				if i+1 < len(fields) {
+					if value, err := strconv.ParseFloat(fields[i+1], 64); err == nil {
+						row[i] = value
+					}
+				}
+			}
+			values = append(values, row)
+		}
+		if len(values) != len(names) {
+			return nil, nil, false, fmt.Errorf("the matrix in %s is not square", path)
+		}
+		return names, values, true, scanner.Err()
+	}
+
+	pairs := make(map[[2]string]float64)
+	for scanner.Scan() {
+		matches := pairFreqLineRegex.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if matches == nil {
+			continue
+		}
+		frequency, _ := strconv.ParseFloat(matches[3], 64)
+		pairs[[2]string{matches[1], matches[2]}] += frequency
+	}
+	names, values := pairMatrix(pairs)
+	return names, values, false, scanner.Err()
+}
+
+// pairMatrix converts the category pair frequencies to a symmetric matrix over the sorted categories
+func pairMatrix(pairs map[[2]string]float64) ([]string, [][]float64) {
+	nameSet := make(map[string]struct{})
+	for pair := range pairs {
+		nameSet[pair[0]] = struct{}{}
+		nameSet[pair[1]] = struct{}{}
+	}
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	index := make(map[string]int)
+	for i, name := range names {
+		index[name] = i
+	}
+	values := make([][]float64, len(names))
+	for i := range values {
+		values[i] = make([]float64, len(names))
+	}
+	for pair, frequency := range pairs {
+		i, j := index[pair[0]], index[pair[1]]
+		values[i][j] += frequency
+		if i != j {
+			values[j][i] += frequency
+		}
+	}
+	return names, values
+}
+
+func plotHistogram(options PlotOptions, bins int, logY bool, path string) error {
+	points, err := readTwoColumns(path)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no data in %s", path)
+	}
+	if bins <= 0 {
+		bins = min(len(points), 100)
+	}
+	// The counts are used as the weights of the sizes
+	histogram, err := plotter.NewHistogram(points, bins)
+	if err != nil {
+		return err
+	}
+	histogram.FillColor = plotutil.Color(0)
+	histogram.LineStyle.Width = vg.Points(0.3)
+	// Empty bins are left out of the data range on a log scale
+	histogram.LogY = logY
+	p := newPlot(options.Title, "Size (bytes)", "Count")
+	p.Add(histogram)
+	if logY {
+		useLogScale(&p.Y)
+	}
+	return savePlot(p, options)
+}
+
+func plotCDF(options PlotOptions, logX bool, paths []string) error {
+	p := newPlot(options.Title, "Size (bytes)", "CDF")
+	p.Y.Min, p.Y.Max = 0, 1
+	for i, path := range paths {
+		points, err := readTwoColumns(path)
+		if err != nil {
+			return err
+		}
+		sort.Slice(points, func(a, b int) bool {
+			return points[a].X < points[b].X
+		})
+		var total float64
+		for _, point := range points {
+			total += point.Y
+		}
+		if total == 0 {
+			return fmt.Errorf("no data in %s", path)
+		}
+		cdf := make(plotter.XYs, 0, len(points))
+		var accumulated float64
+		for _, point := range points {
+			accumulated += point.Y
+			if logX && point.X <= 0 {
+				continue
+			}
+			cdf = append(cdf, plotter.XY{X: point.X, Y: accumulated / total})
+		}
+		if err := addLine(p, seriesName(path), i, cdf); err != nil {
+			return err
+		}
+	}
+	if logX {
+		useLogScale(&p.X)
+	}
+	p.Legend.Left = false
+	p.Legend.Top = false
+	return savePlot(p, options)
+}
+
+func plotRankFrequency(options PlotOptions, pointsPerDecade int, paths []string) error {
+	p := newPlot(options.Title, "Rank", "Frequency")
+	for i, path := range paths {
+		counts, err := readCountColumn(path)
+		if err != nil {
+			return err
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(counts)))
+		// Keep one point per 1/pointsPerDecade of a decade of ranks, so that the figure size does not grow with the keys
+		var points plotter.XYs
+		lastStep := -1
+		for index, count := range counts {
+			if count <= 0 {
+				break
+			}
+			rank := index + 1
+			step := int(math.Log10(float64(rank)) * float64(pointsPerDecade))
+			if step == lastStep && index != len(counts)-1 {
+				continue
+			}
+			lastStep = step
+			points = append(points, plotter.XY{X: float64(rank), Y: count})
+		}
+		if len(points) == 0 {
+			return fmt.Errorf("no data in %s", path)
+		}
+		if err := addLine(p, seriesName(path), i, points); err != nil {
+			return err
+		}
+	}
+	useLogScale(&p.X)
+	useLogScale(&p.Y)
+	return savePlot(p, options)
+}
+
+func plotOpMix(options PlotOptions, top int, share bool, path string) error {
+	opCounts, err := readOpCounts(path)
+	if err != nil {
+		return err
+	}
+	categoryTotals := make(map[string]float64)
+	opTypeSet := make(map[string]struct{})
+	for category, counts := range opCounts {
+		for opType, count := range counts {
+			categoryTotals[category] += count
+			opTypeSet[opType] = struct{}{}
+		}
+	}
+	categories := make([]string, 0, len(categoryTotals))
+	for category := range categoryTotals {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categoryTotals[categories[i]] != categoryTotals[categories[j]] {
+			return categoryTotals[categories[i]] > categoryTotals[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	if top > 0 && len(categories) > top {
+		categories = categories[:top]
+	}
+	if len(categories) == 0 {
+		return fmt.Errorf("no operation counts in %s", path)
+	}
+	opTypes := make([]string, 0, len(opTypeSet))
+	for opType := range opTypeSet {
+		opTypes = append(opTypes, opType)
+	}
+	sort.Strings(opTypes)
+
+	yLabel := "Operations"
+	if share {
+		yLabel = "Share of operations"
+	}
+	p := newPlot(options.Title, "", yLabel)
+	var below *plotter.BarChart
+	for i, opType := range opTypes {
+		values := make(plotter.Values, len(categories))
+		for j, category := range categories {
+			values[j] = opCounts[category][opType]
+			if share {
+				values[j] /= categoryTotals[category]
+			}
+		}
+		bars, err := plotter.NewBarChart(values, vg.Length(options.Width)*vg.Inch*0.6/vg.Length(len(categories)))
+		if err != nil {
+			return err
+		}
+		bars.Color = plotutil.Color(i)
+		bars.LineStyle.Width = 0
+		if below != nil {
+			bars.StackOn(below)
+		}
+		below = bars
+		p.Add(bars)
+		p.Legend.Add(opType, bars)
+	}
+	p.NominalX(categories...)
+	p.X.Min, p.X.Max = -0.5, float64(len(categories))-0.5
+	p.X.Tick.Label.Rotation = math.Pi / 4
+	p.X.Tick.Label.XAlign = draw.XRight
+	p.X.Tick.Label.YAlign = draw.YCenter
+	return savePlot(p, options)
+}
+
+func plotHeatmap(options PlotOptions, path string) error {
+	names, values, correlation, err := readMatrix(path)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no data in %s", path)
+	}
+	colorMap := moreland.SmoothBlueRed()
+	minValue, maxValue := -1.0, 1.0
+	if !correlation {
+		// Frequencies span several orders of magnitude, so they are drawn in log10
+		minValue, maxValue = math.Inf(1), math.Inf(-1)
+		for _, row := range values {
+			for j, value := range row {
+				if value > 0 {
+					row[j] = math.Log10(value)
+					minValue, maxValue = math.Min(minValue, row[j]), math.Max(maxValue, row[j])
+				} else {
+					row[j] = math.NaN()
+				}
+			}
+		}
+		if math.IsInf(minValue, 0) {
+			minValue, maxValue = 0, 1
+		}
+	}
+	colorMap.SetMin(minValue)
+	colorMap.SetMax(math.Max(maxValue, minValue+1e-9))
+
+	heatMap := plotter.NewHeatMap(matrixGrid{values: values}, colorMap.Palette(255))
+	heatMap.Min, heatMap.Max = minValue, math.Max(maxValue, minValue+1e-9)
+	heatMap.NaN = color.Gray{Y: 235}
+	p := newPlot(options.Title, "", "")
+	p.Add(heatMap)
+
+	xTicks := make([]plot.Tick, len(names))
+	yTicks := make([]plot.Tick, len(names))
+	for i, name := range names {
+		xTicks[i] = plot.Tick{Value: float64(i), Label: name}
+		yTicks[i] = plot.Tick{Value: float64(len(names) - 1 - i), Label: name}
+	}
+	p.X.Tick.Marker = plot.ConstantTicks(xTicks)
+	p.Y.Tick.Marker = plot.ConstantTicks(yTicks)
+	p.X.Tick.Label.Rotation = math.Pi / 2
+	p.X.Tick.Label.XAlign = draw.XRight
+	p.X.Tick.Label.YAlign = draw.YCenter
+	p.X.Tick.Label.Font.Size = vg.Points(6)
+	p.Y.Tick.Label.Font.Size = vg.Points(6)
+	p.X.Min, p.X.Max = -0.5, float64(len(names))-0.5
+	p.Y.Min, p.Y.Max = -0.5, float64(len(names))-0.5
+	if !correlation {
+		p.Title.Text += " (log10)"
+	}
+	p.Title.Text = strings.TrimSpace(p.Title.Text + fmt.Sprintf(" range [%.2f, %.2f]", minValue, maxValue))
+	return savePlot(p, options)
+}
+
+func usage() {
+	fmt.Println("Usage: program <subcommand> [options] <input_files>")
+	fmt.Println("Subcommands:")
+	fmt.Println("  hist [-bins N] [-logy] <histogram_file>         KV size histogram (<data_type>_{key,value,kv}_histogram.{txt,json,csv})")
+	fmt.Println("  cdf [-logx] <histogram_file>...                 CDF of one or more histograms")
+	fmt.Println("  rankfreq [-points N] <distribution_file>...     Rank-frequency log-log plot (*_dis.{txt,json,csv})")
+	fmt.Println("  opmix [-top N] [-share] <countKVDist_file>      Operation mix per category (stacked bars)")
+	fmt.Println("  heatmap <matrix_file>                           Correlation heatmap (categoryPearson-lag*.csv or freq-category-*.{log,json,csv})")
+	fmt.Println("Common options: -o <output.svg|png|pdf> -title <title> -width <inches> -height <inches>")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		return
+	}
+	subcommand := os.Args[1]
+	flags := flag.NewFlagSet(subcommand, flag.ExitOnError)
+	options := PlotOptions{}
+	flags.StringVar(&options.Output, "o", "", "output figure path, the format is chosen by the extension (default: <first input>.svg)")
+	flags.StringVar(&options.Title, "title", "", "figure title")
+	flags.Float64Var(&options.Width, "width", 6, "figure width in inches")
+	flags.Float64Var(&options.Height, "height", 4, "figure height in inches")
+	bins := flags.Int("bins", 0, "number of histogram bins (default: one per size, at most 100)")
+	logY := flags.Bool("logy", false, "log scale for the counts")
+	logX := flags.Bool("logx", false, "log scale for the sizes")
+	pointsPerDecade := flags.Int("points", 50, "points per decade of ranks")
+	top := flags.Int("top", 20, "number of categories with the most operations to show (0 for all)")
+	share := flags.Bool("share", false, "show the share of each operation type instead of the counts")
+	flags.Parse(os.Args[2:])
+
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		usage()
+		return
+	}
+	if options.Output == "" {
+		options.Output = seriesName(inputs[0]) + ".svg"
+	}
+
+	var err error
+	switch subcommand {
+	case "hist":
+		err = plotHistogram(options, *bins, *logY, inputs[0])
+	case "cdf":
+		err = plotCDF(options, *logX, inputs)
+	case "rankfreq":
+		err = plotRankFrequency(options, max(*pointsPerDecade, 1), inputs)
+	case "opmix":
+		err = plotOpMix(options, *top, *share, inputs[0])
+	case "heatmap":
+		err = plotHeatmap(options, inputs[0])
+	default:
+		usage()
+		return
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// RecordWriter writes the results as a JSON array (one record per line) or CSV. The records are structs, whose json
+// tags give the JSON fields and the CSV header
+type RecordWriter struct {
+	format    string
+	fields    []string
+	file      *os.File
+	writer    *bufio.Writer
+	csvWriter *csv.Writer
+	count     int
+}
+
+// outputPath replaces the extension of the text output path with the format
+func outputPath(path, format string) string {
+	if format == "text" {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + format
+}
+
+// newRecordWriter creates the output file of the records, the empty record gives the fields
+func newRecordWriter(path, format string, emptyRecord any) (*RecordWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &RecordWriter{format: format, file: file, writer: bufio.NewWriter(file)}
+	recordType := reflect.TypeOf(emptyRecord)
+	for i := 0; i < recordType.NumField(); i++ {
+		w.fields = append(w.fields, strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0])
+	}
+	if format == "csv" {
+		w.csvWriter = csv.NewWriter(w.writer)
+		return w, w.csvWriter.Write(w.fields)
+	}
+	_, err = w.writer.WriteString("[")
+	return w, err
+}
+
+// Write writes a record, the slices are space-separated in CSV, and NaN or infinite floats are null in JSON
+func (w *RecordWriter) Write(record any) error {
+	value := reflect.ValueOf(record)
+	if w.csvWriter != nil {
+		row := make([]string, len(w.fields))
+		for i := range row {
+			row[i] = fmt.Sprint(value.Field(i).Interface())
+			if value.Field(i).Kind() == reflect.Slice {
+				row[i] = strings.Trim(row[i], "[]")
+			}
+		}
+		return w.csvWriter.Write(row)
+	}
+	if w.count > 0 {
+		w.writer.WriteString(",")
+	}
+	w.writer.WriteString("\n  {")
+	for i, name := range w.fields {
+		field := value.Field(i).Interface()
+		if f, ok := field.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			field = nil
+		}
+		data, err := json.Marshal(field)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.writer.WriteString(", ")
+		}
+		fmt.Fprintf(w.writer, "\"%s\": %s", name, data)
+	}
+	w.count++
+	_, err := w.writer.WriteString("}")
+	return err
+}
+
+func (w *RecordWriter) Close() error {
+	if w.csvWriter != nil {
+		w.csvWriter.Flush()
+		if err := w.csvWriter.Error(); err != nil {
+			w.file.Close()
+			return err
+		}
+	} else {
+		w.writer.WriteString("\n]\n")
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// writeRecords writes all records to the output file
+func writeRecords[T any](path, format string, records []T) error {
+	var emptyRecord T
+	w, err := newRecordWriter(path, format, emptyRecord)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	fmt.Println("Records are stored to:", path)
+	return nil
+}
+
+// readRecords reads the records of a .json or .csv output, with the fields as strings
+func readRecords(path string) ([]map[string]string, error) {
+	return readFirstRecords(path, -1)
+}
+
+// readFirstRecords reads the first n records of a .json or .csv output (all of them if n is negative), the rest of the
+// file is not decoded
+func readFirstRecords(path string, n int) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var records []map[string]string
+	if strings.HasSuffix(path, ".json") {
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		decoder.UseNumber()
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+		}
+		for decoder.More() && (n < 0 || len(records) < n) {
+			var row map[string]any
+			if err := decoder.Decode(&row); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+			}
+			record := make(map[string]string, len(row))
+			for name, value := range row {
+				if value != nil {
+					record[name] = fmt.Sprint(value)
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	reader := csv.NewReader(bufio.NewReader(file))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of %s: %v", path, err)
+	}
+	for n < 0 || len(records) < n {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			record[name] = fields[i]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// isStructured returns whether the file is a .json or .csv output
+func isStructured(path string) bool {
+	return strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".csv")
+}